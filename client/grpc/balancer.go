@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/selector/factory"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const balancerPrefix = "selector_"
+
+// BalancerName is the name of balancer registered for selector type t,
+// used by WithSelector of Conn or loadBalancingConfig of service config.
+func BalancerName(t string) string {
+	return balancerPrefix + t
+}
+
+type selectorBuilder struct {
+	name string
+	t    string
+}
+
+var _ balancer.Builder = (*selectorBuilder)(nil)
+
+// NewSelectorBuilder build balancer which delegate picking to selector of type t,
+// every ClientConn has its own selector, so the state such as dynamic weight is kept across address updates.
+func NewSelectorBuilder(t string) balancer.Builder {
+	return &selectorBuilder{name: BalancerName(t), t: t}
+}
+
+func (b *selectorBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	pb := &selectorPickerBuilder{
+		selector: factory.New(opts.Target.Endpoint(), b.t),
+		nodes:    make(map[string]servicer.Node),
+	}
+	return &selectorBalancer{
+		Balancer: base.NewBalancerBuilder(b.name, pb, base.Config{HealthCheck: true}).Build(cc, opts),
+		selector: pb.selector,
+	}
+}
+
+func (b *selectorBuilder) Name() string {
+	return b.name
+}
+
+// selectorBalancer close the selector when the balancer is closed by ClientConn closing or switching balancer,
+// so the background work of selector such as icmp probing is stopped.
+type selectorBalancer struct {
+	balancer.Balancer
+	selector selector.Selector
+}
+
+func (b *selectorBalancer) Close() {
+	b.Balancer.Close()
+	_ = selector.Close(b.selector)
+}
+
+type selectorPickerBuilder struct {
+	lock     sync.Mutex
+	selector selector.Selector
+	nodes    map[string]servicer.Node // address to node added to selector
+}
+
+var _ base.PickerBuilder = (*selectorPickerBuilder)(nil)
+
+// Build sync the ready SubConns to selector, node with changed attributes is updated in place.
+func (pb *selectorPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+
+	subConns := make(map[string]balancer.SubConn, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		node := addressToNode(sci.Address)
+		address := node.Address()
+		subConns[address] = sc
+
+		old, ok := pb.nodes[address]
+		switch {
+		case !ok:
+			_ = pb.selector.AddNode(node)
+		case !servicer.SameNode(old, node):
+			_ = selector.UpdateNode(pb.selector, node)
+		default:
+			continue
+		}
+		pb.nodes[address] = node
+	}
+
+	for address, node := range pb.nodes {
+		if _, ok := subConns[address]; ok {
+			continue
+		}
+		_ = pb.selector.DeleteNode(node)
+		delete(pb.nodes, address)
+	}
+
+	if len(subConns) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	return &selectorPicker{selector: pb.selector, subConns: subConns}
+}
+
+type selectorPicker struct {
+	selector selector.Selector
+	subConns map[string]balancer.SubConn
+}
+
+var _ balancer.Picker = (*selectorPicker)(nil)
+
+func (p *selectorPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	ctx := info.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	node, err := p.selector.Select(ctx)
+	if err != nil {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+
+	// the selector may be ahead of this picker, wait for the new one,
+	// the picked node must be done, otherwise the inflight of selector leaks, the race is not the fault of node
+	sc, ok := p.subConns[node.Address()]
+	if !ok {
+		p.selector.AfterHandle(selector.HandleInfo{Node: node, NotSent: true})
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+
+	start := time.Now()
+	return balancer.PickResult{
+		SubConn: sc,
+		Done: func(di balancer.DoneInfo) {
+			p.selector.AfterHandle(selector.HandleInfo{Node: node, Err: nodeErr(di.Err), Cost: time.Since(start)})
+		},
+	}, nil
+}
+
+// nodeErr return err only if it is the failure of node, such as transport error and Unavailable,
+// the application status such as NotFound and InvalidArgument means the node is healthy.
+func nodeErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return err
+	}
+	return nil
+}
+
+func init() {
+	for _, t := range []string{
+		selector.TypeWR,
+		selector.TypeWrr,
+		selector.TypeDwrr,
+		selector.TypeP2C,
+		selector.TypeICMP,
+		selector.TypeHash,
+	} {
+		balancer.Register(NewSelectorBuilder(t))
+	}
+}
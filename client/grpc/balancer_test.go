@@ -0,0 +1,234 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/selector/wrr"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	address string
+}
+
+type recordSelector struct {
+	selector.Selector
+	infos []selector.HandleInfo
+}
+
+func (s *recordSelector) AfterHandle(info selector.HandleInfo) {
+	s.infos = append(s.infos, info)
+	s.Selector.AfterHandle(info)
+}
+
+func buildInfo(nodes ...servicer.Node) (base.PickerBuildInfo, map[string]balancer.SubConn) {
+	info := base.PickerBuildInfo{ReadySCs: make(map[balancer.SubConn]base.SubConnInfo)}
+	subConns := make(map[string]balancer.SubConn)
+	for _, node := range nodes {
+		sc := &fakeSubConn{address: node.Address()}
+		info.ReadySCs[sc] = base.SubConnInfo{Address: nodeToAddress(node)}
+		subConns[node.Address()] = sc
+	}
+	return info, subConns
+}
+
+func TestSelectorPickerBuilder(t *testing.T) {
+	sel := &recordSelector{Selector: wrr.NewSelector("svc")}
+	pb := &selectorPickerBuilder{selector: sel, nodes: make(map[string]servicer.Node)}
+
+	_, err := pb.Build(base.PickerBuildInfo{}).Pick(balancer.PickInfo{})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+
+	info, subConns := buildInfo(
+		servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(1)),
+		servicer.NewNode("127.0.0.2", 80, servicer.WithWeight(3)),
+	)
+	picker := pb.Build(info)
+	count := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		res, err := picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+		assert.Nil(t, err)
+		count[res.SubConn.(*fakeSubConn).address]++
+		res.Done(balancer.DoneInfo{})
+	}
+	assert.Equal(t, 100, count["127.0.0.1:80"])
+	assert.Equal(t, 300, count["127.0.0.2:80"])
+	assert.Equal(t, 400, len(sel.infos))
+
+	res, err := picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+	assert.Nil(t, err)
+	res.Done(balancer.DoneInfo{Err: errors.New("fail")})
+	assert.NotNil(t, sel.infos[len(sel.infos)-1].Err)
+
+	// the application status is not the failure of node
+	for _, err := range []error{status.Error(codes.NotFound, "x"), status.Error(codes.InvalidArgument, "x")} {
+		res, _ = picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+		res.Done(balancer.DoneInfo{Err: err})
+		assert.Nil(t, sel.infos[len(sel.infos)-1].Err)
+	}
+	res, _ = picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+	res.Done(balancer.DoneInfo{Err: status.Error(codes.Unavailable, "x")})
+	assert.NotNil(t, sel.infos[len(sel.infos)-1].Err)
+
+	// weight changed node is replaced, removed node is deleted
+	info, subConns = buildInfo(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(5)))
+	picker = pb.Build(info)
+	nodes, _ := sel.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, 5, nodes[0].Weight())
+	res, err = picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+	assert.Nil(t, err)
+	assert.Equal(t, subConns["127.0.0.1:80"], res.SubConn)
+
+	// zone and meta changed node is replaced too
+	info, _ = buildInfo(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(5), servicer.WithZone("bj"),
+		servicer.WithMeta(map[string]string{"lane": "blue"})))
+	_ = pb.Build(info)
+	nodes, _ = sel.GetNodes()
+	assert.Equal(t, "bj", nodes[0].Zone())
+	assert.Equal(t, "blue", nodes[0].Meta()["lane"])
+
+	// the stale picker picks the node unknown to it, the node is still done as not sent
+	info, _ = buildInfo(servicer.NewNode("127.0.0.2", 80))
+	_ = pb.Build(info)
+	handled := len(sel.infos)
+	_, err = picker.Pick(balancer.PickInfo{Ctx: context.Background()})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+	assert.Equal(t, handled+1, len(sel.infos))
+	assert.Equal(t, "127.0.0.2:80", sel.infos[handled].Node.Address())
+	assert.True(t, sel.infos[handled].NotSent)
+	assert.Nil(t, sel.infos[handled].Err)
+
+	// no ready SubConn, the nodes are deleted
+	_, err = pb.Build(base.PickerBuildInfo{}).Pick(balancer.PickInfo{})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+	nodes, _ = sel.GetNodes()
+	assert.Equal(t, 0, len(nodes))
+}
+
+type fakeBalancer struct {
+	balancer.Balancer
+	closed bool
+}
+
+func (b *fakeBalancer) Close() { b.closed = true }
+
+type closeSelector struct {
+	recordSelector
+	closed bool
+}
+
+func (s *closeSelector) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestSelectorBalancer_Close(t *testing.T) {
+	b := &fakeBalancer{}
+	sel := &closeSelector{recordSelector: recordSelector{Selector: wrr.NewSelector("svc")}}
+	(&selectorBalancer{Balancer: b, selector: sel}).Close()
+	assert.True(t, b.closed)
+	assert.True(t, sel.closed)
+
+	// selector without Close
+	b = &fakeBalancer{}
+	(&selectorBalancer{Balancer: b, selector: wrr.NewSelector("svc")}).Close()
+	assert.True(t, b.closed)
+}
+
+type countServer struct {
+	lock  sync.Mutex
+	count map[string]int
+}
+
+func (s *countServer) interceptor(address string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		s.lock.Lock()
+		s.count[address]++
+		s.lock.Unlock()
+		return handler(ctx, req)
+	}
+}
+
+func startServer(t *testing.T, cs *countServer) servicer.Node {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	address := lis.Addr().String()
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(cs.interceptor(address)))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	host, port := servicer.ExtractAddress(address)
+	return servicer.NewNode(host, port)
+}
+
+func TestSelectorBalancer(t *testing.T) {
+	cs := &countServer{count: make(map[string]int)}
+	n1 := startServer(t, cs)
+	n2 := startServer(t, cs)
+	n3 := startServer(t, cs)
+
+	srv := &watchServicer{name: "balancer_selector", watchers: servicer.NewWatchers()}
+	srv.watchers.Notify([]servicer.Node{
+		servicer.NewNode(n1.Host(), n1.Port(), servicer.WithWeight(1)),
+		servicer.NewNode(n2.Host(), n2.Port(), servicer.WithWeight(3)),
+	})
+	servicer.UpdateServicer(srv)
+	defer servicer.DelServicer(srv)
+
+	cc, err := Conn(context.Background(), srv.name, WithSelector(selector.TypeWrr))
+	assert.Nil(t, err)
+	defer cc.Close()
+
+	client := healthpb.NewHealthClient(cc)
+	check := func(times int) {
+		for i := 0; i < times; i++ {
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+			assert.Nil(t, err)
+		}
+	}
+
+	// wait for both SubConns ready
+	assert.Eventually(t, func() bool {
+		check(1)
+		cs.lock.Lock()
+		defer cs.lock.Unlock()
+		return cs.count[n1.Address()] > 0 && cs.count[n2.Address()] > 0
+	}, time.Second*5, time.Millisecond*10)
+
+	cs.lock.Lock()
+	cs.count = make(map[string]int)
+	cs.lock.Unlock()
+	check(400)
+	cs.lock.Lock()
+	assert.Equal(t, 100, cs.count[n1.Address()])
+	assert.Equal(t, 300, cs.count[n2.Address()])
+	cs.lock.Unlock()
+
+	// changes of servicer are pushed to ClientConn
+	srv.watchers.Notify([]servicer.Node{n3})
+	assert.Eventually(t, func() bool {
+		check(1)
+		cs.lock.Lock()
+		defer cs.lock.Unlock()
+		return cs.count[n3.Address()] > 0
+	}, time.Second*5, time.Millisecond*10)
+}
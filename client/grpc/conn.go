@@ -9,11 +9,30 @@ import (
 	serverGRPC "github.com/air-go/rpc/server/grpc"
 )
 
-func Conn(ctx context.Context, serviceName string) (cc *grpc.ClientConn, err error) {
-	if cc, err = grpc.Dial(
-		fmt.Sprintf("%s:///%s", scheme, serviceName),
-		serverGRPC.NewDialOption(serverGRPC.DialOptionResolver(NewRegistryBuilder(serviceName)))...,
-	); err != nil {
+type connOptions struct {
+	selector string
+}
+
+type ConnOption func(*connOptions)
+
+// WithSelector pick SubConn by the selector type such as wr, wrr and p2c, default is pick_first of grpc
+func WithSelector(t string) ConnOption {
+	return func(o *connOptions) { o.selector = t }
+}
+
+func Conn(ctx context.Context, serviceName string, opts ...ConnOption) (cc *grpc.ClientConn, err error) {
+	opt := &connOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	dialOptions := serverGRPC.NewDialOption(serverGRPC.DialOptionResolver(NewRegistryBuilder(serviceName)))
+	if opt.selector != "" {
+		dialOptions = append(dialOptions, grpc.WithDefaultServiceConfig(
+			fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, BalancerName(opt.selector))))
+	}
+
+	if cc, err = grpc.Dial(fmt.Sprintf("%s:///%s", scheme, serviceName), dialOptions...); err != nil {
 		return
 	}
 
@@ -2,20 +2,81 @@ package grpc
 
 import (
 	"context"
+	"reflect"
 
+	"google.golang.org/grpc/attributes"
 	"google.golang.org/grpc/resolver"
 
 	"github.com/air-go/rpc/library/servicer"
 )
 
+type attributeKey string
+
+const (
+	attributeWeight      attributeKey = "weight"
+	attributeFloatWeight attributeKey = "float_weight"
+	attributeZone        attributeKey = "zone"
+	attributeMeta        attributeKey = "meta"
+)
+
+// meta is comparable by attributes.Attributes.Equal
+type meta map[string]string
+
+func (m meta) Equal(o any) bool {
+	om, ok := o.(meta)
+	return ok && reflect.DeepEqual(m, om)
+}
+
+// nodeToAddress carry the weight, zone and meta of node in Attributes
+func nodeToAddress(node servicer.Node) resolver.Address {
+	return resolver.Address{
+		Addr: node.Address(),
+		Attributes: attributes.New(attributeWeight, node.Weight()).
+			WithValue(attributeFloatWeight, node.FloatWeight()).
+			WithValue(attributeZone, node.Zone()).
+			WithValue(attributeMeta, meta(node.Meta())),
+	}
+}
+
+// addressToNode is the reverse of nodeToAddress
+func addressToNode(a resolver.Address) servicer.Node {
+	host, port := servicer.ExtractAddress(a.Addr)
+	opts := []servicer.Option{}
+	if w, ok := a.Attributes.Value(attributeWeight).(int); ok {
+		opts = append(opts, servicer.WithWeight(w))
+	}
+	if w, ok := a.Attributes.Value(attributeFloatWeight).(float64); ok {
+		opts = append(opts, servicer.WithFloatWeight(w))
+	}
+	if z, ok := a.Attributes.Value(attributeZone).(string); ok {
+		opts = append(opts, servicer.WithZone(z))
+	}
+	if m, ok := a.Attributes.Value(attributeMeta).(meta); ok {
+		opts = append(opts, servicer.WithMeta(m))
+	}
+	return servicer.NewNode(host, port, opts...)
+}
+
 type registryResolver struct {
 	serviceName string
 	target      resolver.Target
 	cc          resolver.ClientConn
+	cancel      func()
 }
 
+// start watch the servicer if it can push changes, otherwise resolve once.
 func (r *registryResolver) start() {
-	r.ResolveNow(resolver.ResolveNowOptions{})
+	srv, has := servicer.GetServicer(r.serviceName)
+	if !has {
+		return
+	}
+
+	w, ok := srv.(servicer.Watcher)
+	if !ok {
+		r.ResolveNow(resolver.ResolveNowOptions{})
+		return
+	}
+	r.cancel = w.Watch(r.update)
 }
 
 func (r *registryResolver) ResolveNow(o resolver.ResolveNowOptions) {
@@ -29,14 +90,22 @@ func (r *registryResolver) ResolveNow(o resolver.ResolveNowOptions) {
 		return
 	}
 
+	r.update(nodes)
+}
+
+func (r *registryResolver) update(nodes []servicer.Node) {
 	address := make([]resolver.Address, len(nodes))
 	for i, node := range nodes {
-		address[i] = resolver.Address{Addr: node.Address()}
+		address[i] = nodeToAddress(node)
 	}
-	r.cc.UpdateState(resolver.State{Addresses: address})
+	_ = r.cc.UpdateState(resolver.State{Addresses: address})
 }
 
-func (r *registryResolver) Close() {}
+func (r *registryResolver) Close() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
 
 func init() {
 	resolver.Register(&registryBuilder{})
@@ -0,0 +1,81 @@
+package grpc
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type fakeClientConn struct {
+	resolver.ClientConn
+	states []resolver.State
+}
+
+func (cc *fakeClientConn) UpdateState(s resolver.State) error {
+	cc.states = append(cc.states, s)
+	return nil
+}
+
+func (cc *fakeClientConn) addresses() []string {
+	res := []string{}
+	for _, a := range cc.states[len(cc.states)-1].Addresses {
+		res = append(res, a.Addr)
+	}
+	sort.Strings(res)
+	return res
+}
+
+type watchServicer struct {
+	name     string
+	watchers *servicer.Watchers
+}
+
+func (s *watchServicer) Name() string         { return s.name }
+func (s *watchServicer) RegistryName() string { return s.name }
+func (s *watchServicer) Pick(ctx context.Context) (servicer.Node, error) {
+	return nil, nil
+}
+
+func (s *watchServicer) All(ctx context.Context) ([]servicer.Node, error) {
+	return []servicer.Node{servicer.NewNode("127.0.0.9", 80)}, nil
+}
+
+func (s *watchServicer) Done(ctx context.Context, node servicer.Node, err error, cost time.Duration) error {
+	return nil
+}
+func (s *watchServicer) GetCaCrt() []byte     { return nil }
+func (s *watchServicer) GetClientPem() []byte { return nil }
+func (s *watchServicer) GetClientKey() []byte { return nil }
+
+func (s *watchServicer) Watch(f func(nodes []servicer.Node)) (cancel func()) {
+	return s.watchers.Watch(f)
+}
+
+func TestRegistryResolver_Watch(t *testing.T) {
+	srv := &watchServicer{name: "resolver_watch", watchers: servicer.NewWatchers()}
+	srv.watchers.Notify([]servicer.Node{servicer.NewNode("127.0.0.1", 80)})
+	servicer.UpdateServicer(srv)
+	defer servicer.DelServicer(srv)
+
+	cc := &fakeClientConn{}
+	r, err := NewRegistryBuilder(srv.name).Build(resolver.Target{}, cc, resolver.BuildOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:80"}, cc.addresses())
+
+	srv.watchers.Notify([]servicer.Node{servicer.NewNode("127.0.0.1", 80), servicer.NewNode("127.0.0.2", 80)})
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80"}, cc.addresses())
+
+	// ResolveNow still works by All
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	assert.Equal(t, []string{"127.0.0.9:80"}, cc.addresses())
+
+	r.Close()
+	srv.watchers.Notify(nil)
+	assert.Equal(t, 3, len(cc.states))
+}
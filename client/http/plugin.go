@@ -11,6 +11,7 @@ import (
 	"github.com/air-go/rpc/library/logger"
 	jaeger "github.com/air-go/rpc/library/opentracing/http"
 	libraryOtel "github.com/air-go/rpc/library/otel"
+	"github.com/air-go/rpc/server/http/middleware/tag"
 	"github.com/air-go/rpc/server/http/middleware/timeout"
 )
 
@@ -91,3 +92,16 @@ func (*TimeoutBeforePlugin) Handle(ctx context.Context, req *http.Request) (cont
 func (*TimeoutBeforePlugin) Name() string {
 	return "TimeoutBeforePlugin"
 }
+
+type TagBeforePlugin struct{}
+
+var _ BeforeRequestPlugin = (*TagBeforePlugin)(nil)
+
+func (*TagBeforePlugin) Handle(ctx context.Context, req *http.Request) (context.Context, error) {
+	tag.SetHeader(ctx, req.Header)
+	return ctx, nil
+}
+
+func (*TagBeforePlugin) Name() string {
+	return "TagBeforePlugin"
+}
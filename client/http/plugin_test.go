@@ -9,6 +9,8 @@ import (
 
 	"github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/assert"
+
+	lc "github.com/air-go/rpc/library/context"
 )
 
 func TestJaegerBeforePlugin_Handle(t *testing.T) {
@@ -20,3 +22,16 @@ func TestJaegerBeforePlugin_Handle(t *testing.T) {
 		})
 	})
 }
+
+func TestTagBeforePlugin_Handle(t *testing.T) {
+	p := &TagBeforePlugin{}
+	convey.Convey("TestTagBeforePlugin_Handle", t, func() {
+		convey.Convey("success", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", strings.NewReader(``))
+			ctx := lc.WithTags(context.Background(), map[string]string{"lane": "dev"})
+			_, err := p.Handle(ctx, req)
+			assert.Nil(t, err)
+			assert.Equal(t, "dev", req.Header.Get("X-Lane"))
+		})
+	})
+}
@@ -93,18 +93,21 @@ func (r *RPC) Send(ctx context.Context, request client.Request, response client.
 	if err != nil {
 		return
 	}
-	logger.AddField(ctx,
-		logger.Reflect(logger.ServerIP, node.Host()),
-		logger.Reflect(logger.ServerPort, node.Port()))
 
 	if assert.IsNil(node) {
 		err = errors.New("node nil")
 		return
 	}
+	logger.AddField(ctx,
+		logger.Reflect(logger.ServerIP, node.Host()),
+		logger.Reflect(logger.ServerPort, node.Port()))
 
 	// build url
 	uu, err := r.buildURL(request, node)
 	if err != nil {
+		// the picked node must be done, otherwise the inflight of selector leaks,
+		// the error before send is not the fault of node
+		_ = service.Done(ctx, node, servicer.ErrNotSent, 0)
 		return
 	}
 
@@ -114,6 +117,7 @@ func (r *RPC) Send(ctx context.Context, request client.Request, response client.
 	// build http request
 	req, err := r.buildRequest(ctx, request, uu)
 	if err != nil {
+		_ = service.Done(ctx, node, servicer.ErrNotSent, 0)
 		return
 	}
 
@@ -219,15 +223,27 @@ func (r *RPC) send(ctx context.Context, cli *http.Client, service servicer.Servi
 		logger.AddField(ctx, logger.Reflect(logger.RequestHeader, req.Header))
 	}()
 
+	// the node is done on every path, the transport error and 5xx are failures of node,
+	// the error before send, such as canceled by caller, is not
+	var (
+		cost    time.Duration
+		doneErr error
+	)
+	defer func() {
+		_ = service.Done(ctx, node, doneErr, cost)
+	}()
+
 	if err = r.beforeSend(ctx, req); err != nil {
+		doneErr = servicer.ErrNotSent
 		return
 	}
 
 	start := time.Now()
 	resp, err = cli.Do(req)
+	cost = time.Since(start)
+	doneErr = err
 
-	logger.AddField(ctx, logger.Reflect(logger.Cost, time.Since(start).Milliseconds()))
-	_ = service.Done(ctx, node, err)
+	logger.AddField(ctx, logger.Reflect(logger.Cost, cost.Milliseconds()))
 	_ = r.afterSend(ctx, req, resp)
 
 	if err != nil {
@@ -240,6 +256,9 @@ func (r *RPC) send(ctx context.Context, cli *http.Client, service servicer.Servi
 
 	if resp.StatusCode != http.StatusOK {
 		err = fmt.Errorf("http code is %d", resp.StatusCode)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			doneErr = err
+		}
 		return
 	}
 
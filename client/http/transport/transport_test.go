@@ -139,7 +139,7 @@ func TestDefaultRequest(t *testing.T) {
 			// s.EXPECT().GetCaCrt().Times(1).Return([]byte(""))
 			// s.EXPECT().GetClientPem().Times(1).Return([]byte(""))
 			// s.EXPECT().GetClientKey().Times(1).Return([]byte(""))
-			s.EXPECT().Done(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			s.EXPECT().Done(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
 			_ = servicer.SetServicer(s)
 
 			req := &httpClient.DefaultRequest{
@@ -159,5 +159,76 @@ func TestDefaultRequest(t *testing.T) {
 			assert.Nil(t, err)
 			assert.Equal(t, &respBody, resp.Body)
 		})
+		convey.Convey("canceled before send is done as not sent", func() {
+			l := New()
+			ctx, cancel := context.WithCancel(logger.InitFieldsContainer(context.Background()))
+			cancel()
+
+			ctl := gomock.NewController(t)
+			defer ctl.Finish()
+			s := mock.NewMockServicer(ctl)
+			s.EXPECT().Name().AnyTimes().Return("test_canceled")
+			s.EXPECT().Pick(gomock.Any()).Times(1).Return(servicer.NewNode("127.0.0.1", 80), nil)
+			s.EXPECT().Done(gomock.Any(), gomock.Any(), servicer.ErrNotSent, gomock.Any()).Times(1).Return(nil)
+			_ = servicer.SetServicer(s)
+
+			req := &httpClient.DefaultRequest{
+				ServiceName: "test_canceled",
+				Path:        "/test",
+				Method:      http.MethodGet,
+				Body:        map[string]string{},
+				Codec:       jsonCodec.JSONCodec{},
+			}
+			resp := &httpClient.DataResponse{
+				Body:  new(map[string]string),
+				Codec: jsonCodec.JSONCodec{},
+			}
+			err := l.Send(ctx, req, resp)
+			assert.Equal(t, context.Canceled, err)
+		})
+		convey.Convey("5xx is done with error", func() {
+			l := New()
+			ctx := logger.InitFieldsContainer(context.Background())
+
+			srv, err := server.NewHTTP(func(server *gin.Engine) {
+				server.GET("/test", func(c *gin.Context) {
+					c.JSON(http.StatusBadGateway, nil)
+					c.Abort()
+				})
+			})
+			assert.Nil(t, err)
+			go func() {
+				_ = srv.Start()
+			}()
+			time.Sleep(time.Second * 1)
+			defer func() {
+				_ = srv.Stop()
+			}()
+
+			arr := strings.Split(srv.Addr(), ":")
+			port, _ := strconv.Atoi(arr[1])
+
+			ctl := gomock.NewController(t)
+			defer ctl.Finish()
+			s := mock.NewMockServicer(ctl)
+			s.EXPECT().Name().AnyTimes().Return("test_5xx")
+			s.EXPECT().Pick(gomock.Any()).Times(1).Return(servicer.NewNode(arr[0], port), nil)
+			s.EXPECT().Done(gomock.Any(), gomock.Any(), gomock.Not(gomock.Nil()), gomock.Any()).Times(1).Return(nil)
+			_ = servicer.SetServicer(s)
+
+			req := &httpClient.DefaultRequest{
+				ServiceName: "test_5xx",
+				Path:        "/test",
+				Method:      http.MethodGet,
+				Body:        map[string]string{},
+				Codec:       jsonCodec.JSONCodec{},
+			}
+			resp := &httpClient.DataResponse{
+				Body:  new(map[string]string),
+				Codec: jsonCodec.JSONCodec{},
+			}
+			err = l.Send(ctx, req, resp)
+			assert.NotNil(t, err)
+		})
 	})
 }
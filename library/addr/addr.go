@@ -25,3 +25,29 @@ func (a *TCPAddr) Network() string {
 func (a *TCPAddr) String() string {
 	return net.JoinHostPort(a.IP.String(), strconv.Itoa(a.Port))
 }
+
+// WeightedTCPAddr is TCPAddr with priority and weight, such as the target of dns srv record.
+type WeightedTCPAddr struct {
+	TCPAddr
+	priority int
+	weight   int64
+}
+
+var _ WeightedAddr = (*WeightedTCPAddr)(nil)
+
+// NewWeightedTCPAddr the lower priority value is preferred.
+func NewWeightedTCPAddr(ip net.IP, port, priority int, weight int64) *WeightedTCPAddr {
+	return &WeightedTCPAddr{
+		TCPAddr:  TCPAddr{IP: ip, Port: port},
+		priority: priority,
+		weight:   weight,
+	}
+}
+
+func (a *WeightedTCPAddr) Priority() int {
+	return a.priority
+}
+
+func (a *WeightedTCPAddr) Weight() int64 {
+	return a.weight
+}
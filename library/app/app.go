@@ -12,6 +12,7 @@ var app struct {
 	AppName        string
 	RegistryName   string
 	LocalIP        string
+	Zone           string
 	AppPort        int
 	Pprof          bool
 	IsDebug        bool
@@ -39,6 +40,11 @@ func LocalIP() string {
 	return app.LocalIP
 }
 
+// Zone is the zone or idc where app deployed
+func Zone() string {
+	return app.Zone
+}
+
 func Port() int {
 	return app.AppPort
 }
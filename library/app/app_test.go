@@ -14,6 +14,8 @@ func TestApp(t *testing.T) {
 
 		assert.Equal(t, "", RegistryName())
 
+		assert.Equal(t, "", Zone())
+
 		assert.Equal(t, 0, Port())
 
 		assert.Equal(t, false, Pprof())
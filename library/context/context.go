@@ -11,6 +11,8 @@ const (
 	contextLogContainer
 	contextTraceID
 	contextResponseWriter
+	contextHashKey
+	contextTags
 )
 
 // WithLogID inject log id to context
@@ -62,3 +64,33 @@ func WithResponseWriter(ctx context.Context, val interface{}) context.Context {
 func ValueResponseWriter(ctx context.Context) interface{} {
 	return ctx.Value(contextResponseWriter)
 }
+
+// WithHashKey inject selector hash key to context
+func WithHashKey(ctx context.Context, val string) context.Context {
+	return context.WithValue(ctx, contextHashKey, val)
+}
+
+// ValueHashKey extract selector hash key from context
+func ValueHashKey(ctx context.Context) string {
+	val := ctx.Value(contextHashKey)
+	key, ok := val.(string)
+	if !ok {
+		return ""
+	}
+	return key
+}
+
+// WithTags inject route tags to context, such as lane and version
+func WithTags(ctx context.Context, val map[string]string) context.Context {
+	return context.WithValue(ctx, contextTags, val)
+}
+
+// ValueTags extract route tags from context
+func ValueTags(ctx context.Context) map[string]string {
+	val := ctx.Value(contextTags)
+	tags, ok := val.(map[string]string)
+	if !ok {
+		return nil
+	}
+	return tags
+}
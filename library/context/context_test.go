@@ -67,3 +67,63 @@ func TestValueTraceID(t *testing.T) {
 		})
 	})
 }
+
+func TestWithHashKey(t *testing.T) {
+	convey.Convey("TestWithHashKey", t, func() {
+		convey.Convey("success", func() {
+			ctx := context.TODO()
+			val := "user_id"
+			ctx = WithHashKey(ctx, val)
+			key, ok := ctx.Value(contextHashKey).(string)
+			assert.Equal(t, ok, true)
+			assert.Equal(t, key, val)
+		})
+	})
+}
+
+func TestValueHashKey(t *testing.T) {
+	convey.Convey("TestValueHashKey", t, func() {
+		convey.Convey("success", func() {
+			ctx := context.TODO()
+			val := "user_id"
+			ctx = context.WithValue(ctx, contextHashKey, val)
+			key := ValueHashKey(ctx)
+			assert.Equal(t, key, val)
+		})
+		convey.Convey("empty", func() {
+			ctx := context.TODO()
+			key := ValueHashKey(ctx)
+			assert.Equal(t, key, "")
+		})
+	})
+}
+
+func TestWithTags(t *testing.T) {
+	convey.Convey("TestWithTags", t, func() {
+		convey.Convey("success", func() {
+			ctx := context.TODO()
+			val := map[string]string{"lane": "dev"}
+			ctx = WithTags(ctx, val)
+			tags, ok := ctx.Value(contextTags).(map[string]string)
+			assert.Equal(t, ok, true)
+			assert.Equal(t, tags, val)
+		})
+	})
+}
+
+func TestValueTags(t *testing.T) {
+	convey.Convey("TestValueTags", t, func() {
+		convey.Convey("success", func() {
+			ctx := context.TODO()
+			val := map[string]string{"lane": "dev"}
+			ctx = context.WithValue(ctx, contextTags, val)
+			tags := ValueTags(ctx)
+			assert.Equal(t, tags, val)
+		})
+		convey.Convey("empty", func() {
+			ctx := context.TODO()
+			tags := ValueTags(ctx)
+			assert.Nil(t, tags)
+		})
+	})
+}
@@ -3,6 +3,7 @@ package dns
 import (
 	"context"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,16 +23,25 @@ type Node struct {
 	Host    string
 	Port    int
 	Network string // ip/ipv4/ipv6
+	// SRV means Host is the full srv name such as _http._tcp.example.com,
+	// the port, priority and weight come from srv records, and Port is ignored.
+	SRV bool
 }
 
 type options struct {
 	logger        logger.Logger
-	refreshWindow time.Duration
+	resolver      Resolver
+	refreshWindow time.Duration // used when ttl unknown
+	minRefresh    time.Duration
+	maxRefresh    time.Duration
 }
 
 func defaultOptions() *options {
 	return &options{
+		resolver:      NewNetResolver(nil),
 		refreshWindow: 3 * time.Second,
+		minRefresh:    time.Second,
+		maxRefresh:    5 * time.Minute,
 	}
 }
 
@@ -53,10 +63,25 @@ func WithLogger(l logger.Logger) optionFunc {
 	return func(o *options) { o.logger = l }
 }
 
+// WithRefreshWindow set the refresh interval when ttl is unknown
 func WithRefreshWindow(t time.Duration) optionFunc {
 	return func(o *options) { o.refreshWindow = t }
 }
 
+// WithRefreshLimit limit the refresh interval decided by ttl
+func WithRefreshLimit(min, max time.Duration) optionFunc {
+	return func(o *options) {
+		o.minRefresh = min
+		o.maxRefresh = max
+	}
+}
+
+// WithResolver set the resolver, the default is net.DefaultResolver which does not know ttl,
+// NewTTLResolver honours ttl.
+func WithResolver(r Resolver) optionFunc {
+	return func(o *options) { o.resolver = r }
+}
+
 func NewDNSDiscoverer(serviceName string, lb loadbalancer.LoadBalancer, nodes []Node, opts ...optionFunc) (*dnsDiscoverer, error) {
 	if assert.IsNil(lb) {
 		return nil, errors.New("new dns discoverer loadbalancer nil")
@@ -66,6 +91,9 @@ func NewDNSDiscoverer(serviceName string, lb loadbalancer.LoadBalancer, nodes []
 	for _, o := range opts {
 		o(opt)
 	}
+	if assert.IsNil(opt.resolver) {
+		opt.resolver = NewNetResolver(nil)
+	}
 
 	dd := &dnsDiscoverer{
 		options:     opt,
@@ -79,15 +107,17 @@ func NewDNSDiscoverer(serviceName string, lb loadbalancer.LoadBalancer, nodes []
 	return dd, nil
 }
 
+// Start resolve the nodes and refresh them in the background until Stop,
+// return error if the first discovery failed.
 func (dd *dnsDiscoverer) Start(ctx context.Context) (err error) {
 	dd.startOnce.Do(func() {
 		ctx, dd.stop = context.WithCancel(ucontext.RemoveDeadline(ctx))
 
-		if err = dd.discover(ctx, false); err != nil {
-			return
-		}
-
-		dd.loop(ctx)
+		// keep resolving in the background even if the first discovery failed,
+		// the domain may become resolvable later
+		var ttl time.Duration
+		ttl, err = dd.discover(ctx, false)
+		dd.loop(ctx, ttl)
 	})
 
 	return
@@ -98,14 +128,15 @@ func (dd *dnsDiscoverer) Stop() error {
 	return nil
 }
 
-// discover force get newest and notify loadbalancer to update nodes.
-func (dd *dnsDiscoverer) discover(ctx context.Context, allowError bool) error {
-	addrs, err := dd.getAddrs(ctx, allowError)
+// discover force get newest and notify loadbalancer to update nodes,
+// return the min ttl of records.
+func (dd *dnsDiscoverer) discover(ctx context.Context, allowError bool) (time.Duration, error) {
+	addrs, ttl, err := dd.getAddrs(ctx, allowError)
 	if err != nil {
-		return err
+		return ttl, err
 	}
 	if len(addrs) == 0 {
-		return nil
+		return ttl, nil
 	}
 
 	if err = dd.lb.SetAddrs(addrs); err != nil {
@@ -113,39 +144,113 @@ func (dd *dnsDiscoverer) discover(ctx context.Context, allowError bool) error {
 			logger.Reflect(logger.ServiceName, dd.serviceName),
 			logger.Error(err),
 		)
-		return err
+		return ttl, err
 	}
 
-	return nil
+	return ttl, nil
 }
 
-func (dd *dnsDiscoverer) getAddrs(ctx context.Context, allowError bool) ([]net.Addr, error) {
-	addrs := []net.Addr{}
+func (dd *dnsDiscoverer) getAddrs(ctx context.Context, allowError bool) (addrs []net.Addr, ttl time.Duration, err error) {
+	addrs = []net.Addr{}
 	for _, n := range dd.nodes {
-		ips, err := lookupIP(ctx, n.Network, n.Host)
+		var (
+			nodeAddrs []net.Addr
+			nodeTTL   time.Duration
+		)
+		if n.SRV {
+			nodeAddrs, nodeTTL, err = dd.lookupSRV(ctx, n)
+		} else {
+			nodeAddrs, nodeTTL, err = dd.lookupHost(ctx, n)
+		}
 		if err != nil {
 			dd.AutoLogger().Error(ctx, "dnsDiscoverLookupIPErr",
 				logger.Reflect(logger.ServiceName, dd.serviceName),
 				logger.Error(err),
 			)
 			if allowError {
+				err = nil
 				continue
 			}
-			return addrs, err
+			return
 		}
+		addrs = append(addrs, nodeAddrs...)
+		ttl = shorterTTL(ttl, nodeTTL)
+	}
+	return
+}
+
+func (dd *dnsDiscoverer) lookupHost(ctx context.Context, n Node) ([]net.Addr, time.Duration, error) {
+	ips, ttl, err := dd.lookupIP(ctx, n.Network, n.Host)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	addrs := make([]net.Addr, 0, len(ips))
+	for _, i := range ips {
+		addrs = append(addrs, &addr.TCPAddr{
+			IP:   i,
+			Port: n.Port,
+		})
+	}
+	return addrs, ttl, nil
+}
+
+// lookupSRV lookup srv records and then the ips of each target.
+func (dd *dnsDiscoverer) lookupSRV(ctx context.Context, n Node) ([]net.Addr, time.Duration, error) {
+	srvs, ttl, err := dd.resolver.LookupSRV(ctx, n.Host)
+	if err != nil {
+		return nil, 0, asDNSError(err)
+	}
+
+	addrs := make([]net.Addr, 0, len(srvs))
+	for _, srv := range srvs {
+		ips, ipTTL, err := dd.lookupIP(ctx, n.Network, strings.TrimSuffix(srv.Target, "."))
+		if err != nil {
+			return nil, 0, err
+		}
+		ttl = shorterTTL(ttl, ipTTL)
+
 		for _, i := range ips {
-			addrs = append(addrs, &addr.TCPAddr{
-				IP:   i,
-				Port: n.Port,
-			})
+			addrs = append(addrs, addr.NewWeightedTCPAddr(i, int(srv.Port), int(srv.Priority), int64(srv.Weight)))
 		}
 	}
-	return addrs, nil
+	return addrs, ttl, nil
+}
+
+func (dd *dnsDiscoverer) lookupIP(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, 0, nil
+	}
+
+	if network == "" {
+		network = "ip"
+	}
+
+	ips, ttl, err := dd.resolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return ips, 0, asDNSError(err)
+	}
+
+	return ips, ttl, nil
+}
+
+// nextRefresh is the ttl limited by minRefresh and maxRefresh, or refreshWindow if ttl unknown.
+func (dd *dnsDiscoverer) nextRefresh(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return dd.refreshWindow
+	}
+	if dd.minRefresh > 0 && ttl < dd.minRefresh {
+		return dd.minRefresh
+	}
+	if dd.maxRefresh > 0 && ttl > dd.maxRefresh {
+		return dd.maxRefresh
+	}
+	return ttl
 }
 
-func (dd *dnsDiscoverer) loop(ctx context.Context) {
+func (dd *dnsDiscoverer) loop(ctx context.Context, ttl time.Duration) {
 	go nopanic.GoVoid(ctx, func() {
-		timer := time.NewTimer(dd.refreshWindow)
+		timer := time.NewTimer(dd.nextRefresh(ttl))
 		defer timer.Stop()
 
 		for {
@@ -157,28 +262,30 @@ func (dd *dnsDiscoverer) loop(ctx context.Context) {
 				)
 				return
 			case <-timer.C:
-				_ = dd.discover(ctx, true)
+				ttl, _ = dd.discover(ctx, true)
+				timer.Reset(dd.nextRefresh(ttl))
 			}
 		}
 	})
 }
 
-func lookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
-	if ip := net.ParseIP(host); ip != nil {
-		return []net.IP{ip}, nil
-	}
-
-	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
-	if err != nil {
-		return ips, asDNSError(err)
+// asDNSError return *net.DNSError if err wraps it, otherwise err itself.
+func asDNSError(err error) error {
+	var de *net.DNSError
+	if errors.As(err, &de) {
+		// de.IsNotFound
+		return de
 	}
-
-	return ips, nil
+	return err
 }
 
-func asDNSError(err error) *net.DNSError {
-	var de *net.DNSError
-	errors.As(err, &de)
-	// de.IsNotFound
-	return de
+// shorterTTL return the shorter known ttl of a and b.
+func shorterTTL(a, b time.Duration) time.Duration {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
 }
@@ -2,13 +2,19 @@ package dns
 
 import (
 	"context"
+	"errors"
+	"net"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/air-go/rpc/library/addr"
 	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/loadbalancer/priority"
+	"github.com/air-go/rpc/library/loadbalancer/roundrobin"
 	"github.com/air-go/rpc/library/logger/nop"
 )
 
@@ -51,5 +57,163 @@ func TestDNSDiscoverer(t *testing.T) {
 
 		err = dd.Start(context.Background())
 		assert.NotNil(t, err)
+		dd.Stop()
 	}()
 }
+
+// stubResolver answer by maps, count the lookups.
+type stubResolver struct {
+	lock  sync.Mutex
+	ips   map[string][]net.IP
+	srvs  map[string][]*net.SRV
+	ttl   time.Duration
+	times int
+}
+
+func (r *stubResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.times++
+
+	ips, ok := r.ips[host]
+	if !ok {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, r.ttl, nil
+}
+
+func (r *stubResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	srvs, ok := r.srvs[name]
+	if !ok {
+		return nil, 0, errors.New("no srv")
+	}
+	return srvs, r.ttl * 2, nil
+}
+
+func (r *stubResolver) Times() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.times
+}
+
+func TestDNSDiscoverer_SRV(t *testing.T) {
+	resolver := &stubResolver{
+		ips: map[string][]net.IP{
+			"a.example.com": {net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+			"b.example.com": {net.ParseIP("10.0.0.3")},
+		},
+		srvs: map[string][]*net.SRV{
+			"_http._tcp.example.com": {
+				{Target: "a.example.com.", Port: 8080, Priority: 0, Weight: 10},
+				{Target: "b.example.com.", Port: 8081, Priority: 1, Weight: 20},
+			},
+		},
+	}
+
+	lb := priority.New()
+	dd, err := NewDNSDiscoverer("serviceName", lb, []Node{
+		{Host: "_http._tcp.example.com", Port: 1, SRV: true},
+		{Host: "127.0.0.1", Port: 80},
+	}, WithResolver(resolver))
+	assert.Nil(t, err)
+
+	addrs, _, err := dd.getAddrs(context.Background(), false)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(addrs))
+
+	expect := []struct {
+		address  string
+		priority int
+		weight   int64
+	}{
+		{"10.0.0.1:8080", 0, 10},
+		{"10.0.0.2:8080", 0, 10},
+		{"10.0.0.3:8081", 1, 20},
+	}
+	for idx, e := range expect {
+		wa, ok := addrs[idx].(addr.WeightedAddr)
+		assert.True(t, ok)
+		assert.Equal(t, e.address, wa.String())
+		assert.Equal(t, e.priority, wa.Priority())
+		assert.Equal(t, e.weight, wa.Weight())
+	}
+	assert.Equal(t, "127.0.0.1:80", addrs[3].String())
+
+	// srv not found
+	dd, _ = NewDNSDiscoverer("serviceName", lb, []Node{{Host: "_grpc._tcp.example.com", SRV: true}}, WithResolver(resolver))
+	_, _, err = dd.getAddrs(context.Background(), false)
+	assert.NotNil(t, err)
+
+	// target not found
+	resolver.srvs["_grpc._tcp.example.com"] = []*net.SRV{{Target: "c.example.com.", Port: 80}}
+	_, _, err = dd.getAddrs(context.Background(), false)
+	assert.NotNil(t, err)
+}
+
+func TestDNSDiscoverer_TTL(t *testing.T) {
+	resolver := &stubResolver{
+		ips: map[string][]net.IP{
+			"a.example.com": {net.ParseIP("10.0.0.1")},
+		},
+		srvs: map[string][]*net.SRV{
+			"_http._tcp.example.com": {{Target: "a.example.com", Port: 8080}},
+		},
+		ttl: time.Millisecond * 50,
+	}
+
+	dd, err := NewDNSDiscoverer("serviceName", roundrobin.New(), []Node{
+		{Host: "_http._tcp.example.com", SRV: true},
+	},
+		WithResolver(resolver),
+		WithRefreshWindow(time.Hour),
+		WithRefreshLimit(time.Millisecond*10, time.Hour),
+	)
+	assert.Nil(t, err)
+
+	// the shorter ttl of srv and ip is used
+	_, ttl, err := dd.getAddrs(context.Background(), false)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Millisecond*50, ttl)
+
+	assert.Nil(t, dd.Start(context.Background()))
+	defer dd.Stop()
+	assert.Eventually(t, func() bool { return resolver.Times() >= 4 }, time.Second, time.Millisecond*10)
+}
+
+func TestDNSDiscoverer_nextRefresh(t *testing.T) {
+	dd, _ := NewDNSDiscoverer("serviceName", roundrobin.New(), nil,
+		WithRefreshWindow(time.Second*3),
+		WithRefreshLimit(time.Second, time.Minute),
+	)
+	assert.Equal(t, time.Second*3, dd.nextRefresh(0))
+	assert.Equal(t, time.Second, dd.nextRefresh(time.Millisecond))
+	assert.Equal(t, time.Second*30, dd.nextRefresh(time.Second*30))
+	assert.Equal(t, time.Minute, dd.nextRefresh(time.Hour))
+}
+
+func TestDNSDiscoverer_StartFailed(t *testing.T) {
+	resolver := &stubResolver{ips: map[string][]net.IP{}}
+
+	lb := roundrobin.New()
+	dd, err := NewDNSDiscoverer("serviceName", lb, []Node{{Host: "a.example.com", Port: 80}},
+		WithResolver(resolver),
+		WithRefreshWindow(time.Millisecond*10),
+	)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, dd.Start(context.Background()))
+	defer dd.Stop()
+
+	// resolvable later, picked up by the background refresh
+	resolver.lock.Lock()
+	resolver.ips["a.example.com"] = []net.IP{net.ParseIP("10.0.0.1")}
+	resolver.lock.Unlock()
+
+	assert.Eventually(t, func() bool {
+		a, err := lb.Pick(context.Background())
+		return err == nil && a.String() == "10.0.0.1:80"
+	}, time.Second, time.Millisecond*10)
+}
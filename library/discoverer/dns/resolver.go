@@ -0,0 +1,289 @@
+package dns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// defaultQueryTimeout is used if ctx has no deadline
+const defaultQueryTimeout = 3 * time.Second
+
+// Resolver lookup dns records, the returned ttl <= 0 means unknown, record with ttl 0 is regarded as unknown too.
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) (ips []net.IP, ttl time.Duration, err error)
+	// LookupSRV lookup the full srv name, such as _http._tcp.example.com
+	LookupSRV(ctx context.Context, name string) (srvs []*net.SRV, ttl time.Duration, err error)
+}
+
+type netResolver struct {
+	r *net.Resolver
+}
+
+var _ Resolver = (*netResolver)(nil)
+
+// NewNetResolver wrap net.Resolver, which does not expose ttl.
+func NewNetResolver(r *net.Resolver) *netResolver {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return &netResolver{r: r}
+}
+
+func (r *netResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	ips, err := r.r.LookupIP(ctx, network, host)
+	return ips, 0, err
+}
+
+func (r *netResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	_, srvs, err := r.r.LookupSRV(ctx, "", "", name)
+	return srvs, 0, err
+}
+
+type fallbackResolver struct {
+	primary  Resolver
+	fallback Resolver
+}
+
+var _ Resolver = (*fallbackResolver)(nil)
+
+// NewFallbackResolver use fallback if primary failed, such as the ttl resolver with net resolver,
+// which knows /etc/hosts and search domains.
+func NewFallbackResolver(primary, fallback Resolver) *fallbackResolver {
+	return &fallbackResolver{primary: primary, fallback: fallback}
+}
+
+func (r *fallbackResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	if ips, ttl, err := r.primary.LookupIP(ctx, network, host); err == nil {
+		return ips, ttl, nil
+	}
+	return r.fallback.LookupIP(ctx, network, host)
+}
+
+func (r *fallbackResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	if srvs, ttl, err := r.primary.LookupSRV(ctx, name); err == nil {
+		return srvs, ttl, nil
+	}
+	return r.fallback.LookupSRV(ctx, name)
+}
+
+type ttlResolver struct {
+	server string
+}
+
+var _ Resolver = (*ttlResolver)(nil)
+
+// NewTTLResolver query the nameserver directly over udp to get the ttl of records, the truncated
+// response is retried over tcp, server is host:port, such as the nameserver in /etc/resolv.conf.
+// It does not know /etc/hosts, nsswitch and search domains, use it by WithResolver,
+// and wrap it with NewFallbackResolver if they are needed.
+func NewTTLResolver(server string) *ttlResolver {
+	return &ttlResolver{server: server}
+}
+
+func (r *ttlResolver) LookupIP(ctx context.Context, network, host string) (ips []net.IP, ttl time.Duration, err error) {
+	types := []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	switch network {
+	case "ip4":
+		types = types[:1]
+	case "ip6":
+		types = types[1:]
+	}
+
+	// the family failed is skipped, partial results are better than nothing,
+	// such as the nameserver refuse AAAA of an ipv4 only domain
+	var queryErr error
+	for _, t := range types {
+		answers, err := r.query(ctx, host, t)
+		if err != nil {
+			queryErr = err
+			continue
+		}
+		for _, a := range answers {
+			switch body := a.Body.(type) {
+			case *dnsmessage.AResource:
+				ips = append(ips, net.IP(body.A[:]))
+			case *dnsmessage.AAAAResource:
+				ips = append(ips, net.IP(body.AAAA[:]))
+			default:
+				continue
+			}
+			ttl = shorterTTL(ttl, recordTTL(a.Header))
+		}
+	}
+
+	if len(ips) == 0 {
+		if queryErr != nil {
+			return nil, 0, queryErr
+		}
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: r.server, IsNotFound: true}
+	}
+
+	return ips, ttl, nil
+}
+
+func (r *ttlResolver) LookupSRV(ctx context.Context, name string) (srvs []*net.SRV, ttl time.Duration, err error) {
+	answers, err := r.query(ctx, name, dnsmessage.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, a := range answers {
+		body, ok := a.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+		srvs = append(srvs, &net.SRV{
+			Target:   body.Target.String(),
+			Port:     body.Port,
+			Priority: body.Priority,
+			Weight:   body.Weight,
+		})
+		ttl = shorterTTL(ttl, recordTTL(a.Header))
+	}
+
+	if len(srvs) == 0 {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: name, Server: r.server, IsNotFound: true}
+	}
+
+	return srvs, ttl, nil
+}
+
+// query send one question and return the answers, NXDOMAIN is returned as empty answers.
+func (r *ttlResolver) query(ctx context.Context, host string, t dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultQueryTimeout)
+		defer cancel()
+	}
+
+	if !strings.HasSuffix(host, ".") {
+		host = host + "."
+	}
+	name, err := dnsmessage.NewName(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid name %s", host)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	req := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: t, Class: dnsmessage.ClassINET},
+		},
+	}
+	b, err := req.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.exchange(ctx, "udp", id, b)
+	if err == nil && resp.Truncated {
+		resp, err = r.exchange(ctx, "tcp", id, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the partial answers would drop nodes
+	if resp.Truncated {
+		return nil, &net.DNSError{Err: "truncated response", Name: host, Server: r.server}
+	}
+
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+		return resp.Answers, nil
+	case dnsmessage.RCodeNameError:
+		return nil, nil
+	default:
+		return nil, &net.DNSError{Err: resp.RCode.String(), Name: host, Server: r.server}
+	}
+}
+
+// exchange send the packed request over udp or tcp and return the response of id.
+func (r *ttlResolver) exchange(ctx context.Context, network string, id uint16, req []byte) (*dnsmessage.Message, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, r.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if network == "tcp" {
+		return exchangeTCP(conn, id, req)
+	}
+
+	if _, err = conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &dnsmessage.Message{}
+		if err = resp.Unpack(buf[:n]); err != nil {
+			return nil, err
+		}
+		// ignore the response of other query
+		if resp.ID != id {
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// exchangeTCP the messages are prefixed with 2 bytes length over tcp
+func exchangeTCP(conn net.Conn, id uint16, req []byte) (*dnsmessage.Message, error) {
+	b := make([]byte, 2+len(req))
+	binary.BigEndian.PutUint16(b, uint16(len(req)))
+	copy(b[2:], req)
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+
+	resp := &dnsmessage.Message{}
+	if err := resp.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if resp.ID != id {
+		return nil, errors.Errorf("unexpected response id %d", resp.ID)
+	}
+	return resp, nil
+}
+
+// newID the unpredictable id makes the spoofed response harder
+func newID() (uint16, error) {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
+
+func recordTTL(h dnsmessage.ResourceHeader) time.Duration {
+	return time.Duration(h.TTL) * time.Second
+}
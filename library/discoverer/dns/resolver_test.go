@@ -0,0 +1,250 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startNameserver answer A, AAAA and SRV of example.com. and A of v4only.com. on udp, other names are NXDOMAIN.
+func startNameserver(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			req := dnsmessage.Message{}
+			if err = req.Unpack(buf[:n]); err != nil || len(req.Questions) != 1 {
+				continue
+			}
+
+			q := req.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true},
+				Questions: req.Questions,
+			}
+			h := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET}
+			switch {
+			case q.Name.String() == "example.com." && q.Type == dnsmessage.TypeA:
+				h.TTL = 30
+				resp.Answers = append(resp.Answers,
+					dnsmessage.Resource{Header: h, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}})
+				h.TTL = 20
+				resp.Answers = append(resp.Answers,
+					dnsmessage.Resource{Header: h, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}}})
+			case q.Name.String() == "example.com." && q.Type == dnsmessage.TypeAAAA:
+				h.TTL = 60
+				resp.Answers = append(resp.Answers,
+					dnsmessage.Resource{Header: h, Body: &dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}}})
+			case q.Name.String() == "_http._tcp.example.com." && q.Type == dnsmessage.TypeSRV:
+				h.TTL = 10
+				target, _ := dnsmessage.NewName("example.com.")
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: h, Body: &dnsmessage.SRVResource{
+					Priority: 1, Weight: 5, Port: 8080, Target: target,
+				}})
+			case q.Name.String() == "v4only.com." && q.Type == dnsmessage.TypeA:
+				h.TTL = 30
+				resp.Answers = append(resp.Answers,
+					dnsmessage.Resource{Header: h, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 3}}})
+			case q.Name.String() == "v4only.com.":
+				resp.RCode = dnsmessage.RCodeRefused
+			case q.Name.String() == "refused.com.":
+				resp.RCode = dnsmessage.RCodeRefused
+			case q.Name.String() != "example.com.":
+				resp.RCode = dnsmessage.RCodeNameError
+			}
+
+			b, _ := resp.Pack()
+			_, _ = conn.WriteTo(b, from)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestTTLResolver_LookupIP(t *testing.T) {
+	r := NewTTLResolver(startNameserver(t))
+	ctx := context.Background()
+
+	ips, ttl, err := r.LookupIP(ctx, "ip", "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ips))
+	assert.Equal(t, "10.0.0.1", ips[0].String())
+	assert.Equal(t, "::1", ips[2].String())
+	assert.Equal(t, 20*time.Second, ttl)
+
+	ips, ttl, err = r.LookupIP(ctx, "ip6", "example.com.")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ips))
+	assert.Equal(t, 60*time.Second, ttl)
+
+	_, _, err = r.LookupIP(ctx, "ip4", "notfound.com")
+	de, ok := err.(*net.DNSError)
+	assert.True(t, ok)
+	assert.True(t, de.IsNotFound)
+
+	_, _, err = r.LookupIP(ctx, "ip4", "refused.com")
+	assert.NotNil(t, err)
+
+	// AAAA refused, the A records are returned
+	ips, ttl, err = r.LookupIP(ctx, "ip", "v4only.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ips))
+	assert.Equal(t, "10.0.0.3", ips[0].String())
+	assert.Equal(t, 30*time.Second, ttl)
+
+	_, _, err = r.LookupIP(ctx, "ip6", "v4only.com")
+	assert.NotNil(t, err)
+}
+
+func TestTTLResolver_LookupSRV(t *testing.T) {
+	r := NewTTLResolver(startNameserver(t))
+	ctx := context.Background()
+
+	srvs, ttl, err := r.LookupSRV(ctx, "_http._tcp.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(srvs))
+	assert.Equal(t, &net.SRV{Target: "example.com.", Port: 8080, Priority: 1, Weight: 5}, srvs[0])
+	assert.Equal(t, 10*time.Second, ttl)
+
+	_, _, err = r.LookupSRV(ctx, "_grpc._tcp.example.com")
+	assert.NotNil(t, err)
+}
+
+func TestTTLResolver_Timeout(t *testing.T) {
+	// nobody answer
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, _, err = NewTTLResolver(conn.LocalAddr().String()).LookupIP(ctx, "ip", "example.com")
+	assert.NotNil(t, err)
+}
+
+func TestFallbackResolver(t *testing.T) {
+	ctx := context.Background()
+	r := NewFallbackResolver(NewTTLResolver(startNameserver(t)), &stubResolver{
+		ips:  map[string][]net.IP{"localhost": {net.ParseIP("127.0.0.1")}},
+		srvs: map[string][]*net.SRV{},
+	})
+
+	ips, ttl, err := r.LookupIP(ctx, "ip4", "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ips))
+	assert.Equal(t, 20*time.Second, ttl)
+
+	// not found by the nameserver, such as /etc/hosts
+	ips, ttl, err = r.LookupIP(ctx, "ip4", "localhost")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1", ips[0].String())
+	assert.Equal(t, time.Duration(0), ttl)
+
+	srvs, _, err := r.LookupSRV(ctx, "_http._tcp.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(srvs))
+
+	_, _, err = r.LookupSRV(ctx, "_grpc._tcp.example.com")
+	assert.NotNil(t, err)
+}
+
+// startTruncatedNameserver answer A of example.com. with one record and the truncated flag on udp,
+// and all the records on tcp of the same port if tcp.
+func startTruncatedNameserver(t *testing.T, tcp bool) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	answer := func(req dnsmessage.Message, count int) []byte {
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: req.ID, Response: true, Truncated: count == 1},
+			Questions: req.Questions,
+		}
+		h := dnsmessage.ResourceHeader{Name: req.Questions[0].Name, Class: dnsmessage.ClassINET, TTL: 30}
+		for i := 0; i < count; i++ {
+			resp.Answers = append(resp.Answers,
+				dnsmessage.Resource{Header: h, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, byte(i + 1)}}})
+		}
+		b, _ := resp.Pack()
+		return b
+	}
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := dnsmessage.Message{}
+			if err = req.Unpack(buf[:n]); err != nil || len(req.Questions) != 1 {
+				continue
+			}
+			_, _ = conn.WriteTo(answer(req, 1), from)
+		}
+	}()
+
+	if !tcp {
+		return conn.LocalAddr().String()
+	}
+
+	l, err := net.Listen("tcp", conn.LocalAddr().String())
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err = io.ReadFull(c, length[:]); err != nil {
+				_ = c.Close()
+				continue
+			}
+			buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err = io.ReadFull(c, buf); err != nil {
+				_ = c.Close()
+				continue
+			}
+			req := dnsmessage.Message{}
+			if err = req.Unpack(buf); err == nil && len(req.Questions) == 1 {
+				b := answer(req, 3)
+				binary.BigEndian.PutUint16(length[:], uint16(len(b)))
+				_, _ = c.Write(append(length[:], b...))
+			}
+			_ = c.Close()
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestTTLResolver_Truncated(t *testing.T) {
+	ctx := context.Background()
+
+	// retried over tcp
+	ips, ttl, err := NewTTLResolver(startTruncatedNameserver(t, true)).LookupIP(ctx, "ip4", "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ips))
+	assert.Equal(t, 30*time.Second, ttl)
+
+	// the partial answers are not used if tcp failed
+	_, _, err = NewTTLResolver(startTruncatedNameserver(t, false)).LookupIP(ctx, "ip4", "example.com")
+	assert.NotNil(t, err)
+}
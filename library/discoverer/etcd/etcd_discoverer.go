@@ -1 +1,282 @@
 package etcd
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/why444216978/go-util/assert"
+	ucontext "github.com/why444216978/go-util/context"
+	"github.com/why444216978/go-util/nopanic"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/discoverer"
+	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/registry"
+	registryEtcd "github.com/air-go/rpc/library/registry/etcd"
+)
+
+type options struct {
+	logger        logger.Logger
+	decode        registry.Decode
+	cmdTimeout    time.Duration
+	retryInterval time.Duration
+}
+
+func defaultOptions() *options {
+	return &options{
+		decode:        registryEtcd.JSONDecode,
+		cmdTimeout:    3 * time.Second,
+		retryInterval: time.Second,
+	}
+}
+
+type etcdDiscoverer struct {
+	*options
+	setup.SetupLogger
+	cli       *clientv3.Client
+	prefix    string
+	lb        loadbalancer.LoadBalancer
+	nodes     map[string]*registry.Node // key to node
+	revision  int64                     // the revision nodes synced to
+	startOnce sync.Once
+	stop      context.CancelFunc
+	done      chan struct{}
+}
+
+var _ discoverer.Discoverer = (*etcdDiscoverer)(nil)
+
+type optionFunc func(*options)
+
+func WithLogger(l logger.Logger) optionFunc {
+	return func(o *options) { o.logger = l }
+}
+
+// WithDecode set the decoder of node value, default is the json of registry.Node
+func WithDecode(d registry.Decode) optionFunc {
+	return func(o *options) { o.decode = d }
+}
+
+// WithCmdTimeout set the timeout of etcd get
+func WithCmdTimeout(t time.Duration) optionFunc {
+	return func(o *options) { o.cmdTimeout = t }
+}
+
+// WithRetryInterval set the interval between rewatching after watch canceled
+func WithRetryInterval(t time.Duration) optionFunc {
+	return func(o *options) { o.retryInterval = t }
+}
+
+// NewEtcdDiscoverer watch the keys with prefix, such as registryEtcd.ServicePrefix of the service.
+func NewEtcdDiscoverer(cli *clientv3.Client, prefix string, lb loadbalancer.LoadBalancer, opts ...optionFunc) (*etcdDiscoverer, error) {
+	if cli == nil {
+		return nil, errors.New("new etcd discoverer cli nil")
+	}
+
+	if prefix == "" {
+		return nil, errors.New("new etcd discoverer prefix empty")
+	}
+
+	if assert.IsNil(lb) {
+		return nil, errors.New("new etcd discoverer loadbalancer nil")
+	}
+
+	opt := defaultOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.decode == nil {
+		opt.decode = registryEtcd.JSONDecode
+	}
+
+	ed := &etcdDiscoverer{
+		options: opt,
+		cli:     cli,
+		prefix:  prefix,
+		lb:      lb,
+		nodes:   make(map[string]*registry.Node),
+		done:    make(chan struct{}),
+	}
+
+	ed.SetupLogger.SetLogger(opt.logger)
+
+	return ed, nil
+}
+
+func (ed *etcdDiscoverer) Start(ctx context.Context) (err error) {
+	ed.startOnce.Do(func() {
+		ctx, ed.stop = context.WithCancel(ucontext.RemoveDeadline(ctx))
+
+		if err = ed.load(ctx); err != nil {
+			close(ed.done)
+			return
+		}
+
+		ed.loop(ctx)
+	})
+
+	return
+}
+
+// Stop cancel the watch and wait the loop exit.
+func (ed *etcdDiscoverer) Stop() error {
+	if ed.stop == nil {
+		return nil
+	}
+	ed.stop()
+	<-ed.done
+	return nil
+}
+
+// load get all nodes with prefix, then notify loadbalancer.
+func (ed *etcdDiscoverer) load(ctx context.Context) error {
+	cctx, cancel := context.WithTimeout(ctx, ed.cmdTimeout)
+	defer cancel()
+
+	resp, err := ed.cli.Get(cctx, ed.prefix, clientv3.WithPrefix())
+	if err != nil {
+		ed.AutoLogger().Error(ctx, "etcdDiscoverGetErr",
+			logger.Reflect(logger.ServiceName, ed.prefix),
+			logger.Error(err),
+		)
+		return err
+	}
+
+	nodes := make(map[string]*registry.Node, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		node, err := ed.decode(string(kv.Value))
+		if err != nil {
+			ed.logDecodeErr(ctx, kv, err)
+			continue
+		}
+		nodes[string(kv.Key)] = node
+	}
+
+	ed.nodes = nodes
+	ed.revision = resp.Header.Revision
+
+	return ed.notify(ctx)
+}
+
+func (ed *etcdDiscoverer) loop(ctx context.Context) {
+	go nopanic.GoVoid(ctx, func() {
+		defer close(ed.done)
+
+		for {
+			ed.watch(ctx)
+
+			select {
+			case <-ctx.Done():
+				ed.AutoLogger().Info(ctx, "etcdDiscoverStop",
+					logger.Reflect(logger.ServiceName, ed.prefix),
+					logger.Error(ctx.Err()),
+				)
+				return
+			case <-time.After(ed.retryInterval):
+			}
+		}
+	})
+}
+
+// watch resume from the next revision of synced,
+// reload all nodes if the revision has been compacted.
+func (ed *etcdDiscoverer) watch(ctx context.Context) {
+	wctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	rch := ed.cli.Watch(wctx, ed.prefix, clientv3.WithPrefix(), clientv3.WithRev(ed.revision+1))
+	for resp := range rch {
+		if resp.CompactRevision != 0 {
+			ed.AutoLogger().Warn(ctx, "etcdDiscoverCompacted",
+				logger.Reflect(logger.ServiceName, ed.prefix),
+				logger.Reflect("compact_revision", resp.CompactRevision),
+			)
+			_ = ed.load(ctx)
+			return
+		}
+
+		if err := resp.Err(); err != nil {
+			ed.AutoLogger().Warn(ctx, "etcdDiscoverWatchErr",
+				logger.Reflect(logger.ServiceName, ed.prefix),
+				logger.Error(err),
+			)
+			return
+		}
+
+		if len(resp.Events) == 0 {
+			continue
+		}
+
+		for _, ev := range resp.Events {
+			key := string(ev.Kv.Key)
+			switch ev.Type {
+			case mvccpb.PUT:
+				node, err := ed.decode(string(ev.Kv.Value))
+				if err != nil {
+					ed.logDecodeErr(ctx, ev.Kv, err)
+					continue
+				}
+				ed.nodes[key] = node
+			case mvccpb.DELETE:
+				delete(ed.nodes, key)
+			}
+		}
+		ed.revision = resp.Header.Revision
+
+		_ = ed.notify(ctx)
+	}
+}
+
+// notify convert nodes to addrs sorted by key and set to loadbalancer.
+func (ed *etcdDiscoverer) notify(ctx context.Context) error {
+	keys := make([]string, 0, len(ed.nodes))
+	for key := range ed.nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	addrs := make([]net.Addr, 0, len(keys))
+	for _, key := range keys {
+		node := ed.nodes[key]
+		ip := net.ParseIP(node.Host)
+		if ip == nil {
+			ed.AutoLogger().Warn(ctx, "etcdDiscoverInvalidHost",
+				logger.Reflect(logger.ServiceName, ed.prefix),
+				logger.Reflect("key", key),
+				logger.Reflect("host", node.Host),
+			)
+			continue
+		}
+		addrs = append(addrs, addr.NewWeightedTCPAddr(ip, node.Port, node.Priority, int64(node.Weight)))
+	}
+
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	if err := ed.lb.SetAddrs(addrs); err != nil {
+		ed.AutoLogger().Error(ctx, "etcdDiscoverSetAddressesErr",
+			logger.Reflect(logger.ServiceName, ed.prefix),
+			logger.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (ed *etcdDiscoverer) logDecodeErr(ctx context.Context, kv *mvccpb.KeyValue, err error) {
+	ed.AutoLogger().Warn(ctx, "etcdDiscoverDecodeErr",
+		logger.Reflect(logger.ServiceName, ed.prefix),
+		logger.Reflect("key", string(kv.Key)),
+		logger.Reflect("value", string(kv.Value)),
+		logger.Error(err),
+	)
+}
@@ -0,0 +1,149 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/logger/nop"
+	"github.com/air-go/rpc/library/registry"
+	registryEtcd "github.com/air-go/rpc/library/registry/etcd"
+	"github.com/air-go/rpc/mock/tools/embedetcd"
+)
+
+func putNode(t *testing.T, cli *clientv3.Client, key string, node *registry.Node) {
+	val, err := registryEtcd.JSONEncode(node)
+	assert.Nil(t, err)
+	_, err = cli.Put(context.Background(), key, val)
+	assert.Nil(t, err)
+}
+
+// recordLoadBalancer record the addrs set by discoverer.
+type recordLoadBalancer struct {
+	lock  sync.Mutex
+	addrs []net.Addr
+	times int
+}
+
+func (lb *recordLoadBalancer) Strategy() string { return "record" }
+
+func (lb *recordLoadBalancer) SetAddrs(addrs []net.Addr) error {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	lb.addrs = addrs
+	lb.times++
+	return nil
+}
+
+func (lb *recordLoadBalancer) Pick(context.Context) (net.Addr, error) { return nil, nil }
+
+func (lb *recordLoadBalancer) Back(net.Addr, error) {}
+
+func (lb *recordLoadBalancer) Addresses() []string {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	res := make([]string, 0, len(lb.addrs))
+	for _, a := range lb.addrs {
+		res = append(res, a.String())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestNewEtcdDiscoverer(t *testing.T) {
+	lb := &recordLoadBalancer{}
+	cli := &clientv3.Client{}
+
+	_, err := NewEtcdDiscoverer(nil, "svc", lb)
+	assert.NotNil(t, err)
+	_, err = NewEtcdDiscoverer(cli, "", lb)
+	assert.NotNil(t, err)
+	_, err = NewEtcdDiscoverer(cli, "svc", nil)
+	assert.NotNil(t, err)
+
+	ed, err := NewEtcdDiscoverer(cli, "svc", lb, WithDecode(nil), WithLogger(nop.Logger))
+	assert.Nil(t, err)
+	assert.NotNil(t, ed.decode)
+	assert.Nil(t, ed.Stop())
+}
+
+func TestEtcdDiscoverer(t *testing.T) {
+	cli := embedetcd.Start(t)
+	putNode(t, cli, "svc.127.0.0.1.80", &registry.Node{Host: "127.0.0.1", Port: 80, Weight: 10, Priority: 1})
+	putNode(t, cli, "svc.127.0.0.2.80", &registry.Node{Host: "127.0.0.2", Port: 80})
+	putNode(t, cli, "other.127.0.0.3.80", &registry.Node{Host: "127.0.0.3", Port: 80})
+	_, _ = cli.Put(context.Background(), "svc.invalid", "invalid")
+
+	lb := &recordLoadBalancer{}
+	ed, err := NewEtcdDiscoverer(cli, "svc", lb, WithRetryInterval(time.Millisecond*10))
+	assert.Nil(t, err)
+	assert.Nil(t, ed.Start(context.Background()))
+	defer ed.Stop()
+
+	// first load is synchronous
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80"}, lb.Addresses())
+	for _, a := range lb.addrs {
+		if a.String() != "127.0.0.1:80" {
+			continue
+		}
+		wa := a.(addr.WeightedAddr)
+		assert.Equal(t, 1, wa.Priority())
+		assert.Equal(t, int64(10), wa.Weight())
+	}
+
+	putNode(t, cli, "svc.127.0.0.4.80", &registry.Node{Host: "127.0.0.4", Port: 80})
+	assert.Eventually(t, func() bool {
+		return len(lb.Addresses()) == 3
+	}, time.Second*3, time.Millisecond*10)
+
+	_, err = cli.Delete(context.Background(), "svc.127.0.0.1.80")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return fmt.Sprint(lb.Addresses()) == fmt.Sprint([]string{"127.0.0.2:80", "127.0.0.4:80"})
+	}, time.Second*3, time.Millisecond*10)
+}
+
+func TestEtcdDiscoverer_Resume(t *testing.T) {
+	cli := embedetcd.Start(t)
+	putNode(t, cli, "svc.127.0.0.1.80", &registry.Node{Host: "127.0.0.1", Port: 80})
+
+	lb := &recordLoadBalancer{}
+	ed, err := NewEtcdDiscoverer(cli, "svc", lb)
+	assert.Nil(t, err)
+	assert.Nil(t, ed.load(context.Background()))
+	assert.Equal(t, []string{"127.0.0.1:80"}, lb.Addresses())
+
+	// changes while watch canceled are received after rewatch
+	putNode(t, cli, "svc.127.0.0.2.80", &registry.Node{Host: "127.0.0.2", Port: 80})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ed.watch(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(lb.Addresses()) == 2
+	}, time.Second*3, time.Millisecond*10)
+	cancel()
+	<-done
+
+	// compacted revision cause reload
+	putNode(t, cli, "svc.127.0.0.3.80", &registry.Node{Host: "127.0.0.3", Port: 80})
+	resp, err := cli.Delete(context.Background(), "svc.127.0.0.1.80")
+	assert.Nil(t, err)
+	_, err = cli.Compact(context.Background(), resp.Header.Revision)
+	assert.Nil(t, err)
+
+	ed.watch(context.Background())
+	assert.Equal(t, []string{"127.0.0.2:80", "127.0.0.3:80"}, lb.Addresses())
+	assert.Equal(t, resp.Header.Revision, ed.revision)
+}
@@ -0,0 +1,101 @@
+package file
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/why444216978/go-util/assert"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/discoverer"
+	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/registry"
+	registryFile "github.com/air-go/rpc/library/registry/file"
+)
+
+type options struct {
+	logger logger.Logger
+}
+
+type fileDiscoverer struct {
+	*options
+	setup.SetupLogger
+	path      string
+	lb        loadbalancer.LoadBalancer
+	discovery *registryFile.FileDiscovery
+	startOnce sync.Once
+}
+
+var _ discoverer.Discoverer = (*fileDiscoverer)(nil)
+
+type optionFunc func(*options)
+
+func WithLogger(l logger.Logger) optionFunc {
+	return func(o *options) { o.logger = l }
+}
+
+// NewFileDiscoverer set the nodes in file to lb, see registry/file for the file format.
+func NewFileDiscoverer(path string, lb loadbalancer.LoadBalancer, opts ...optionFunc) (*fileDiscoverer, error) {
+	if path == "" {
+		return nil, errors.New("new file discoverer path empty")
+	}
+
+	if assert.IsNil(lb) {
+		return nil, errors.New("new file discoverer loadbalancer nil")
+	}
+
+	opt := &options{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	fd := &fileDiscoverer{
+		options: opt,
+		path:    path,
+		lb:      lb,
+	}
+
+	fd.SetupLogger.SetLogger(opt.logger)
+
+	return fd, nil
+}
+
+func (fd *fileDiscoverer) Start(ctx context.Context) (err error) {
+	fd.startOnce.Do(func() {
+		fd.discovery, err = registryFile.NewDiscovery(fd.path,
+			registryFile.WithLogger(fd.logger),
+			registryFile.WithNotify(fd.notify),
+		)
+	})
+
+	return
+}
+
+func (fd *fileDiscoverer) Stop() error {
+	if fd.discovery == nil {
+		return nil
+	}
+	return fd.discovery.Close()
+}
+
+func (fd *fileDiscoverer) notify(nodes []*registry.Node) {
+	addrs := make([]net.Addr, 0, len(nodes))
+	for _, node := range nodes {
+		addrs = append(addrs, addr.NewWeightedTCPAddr(net.ParseIP(node.Host), node.Port, node.Priority, int64(node.Weight)))
+	}
+
+	if len(addrs) == 0 {
+		return
+	}
+
+	if err := fd.lb.SetAddrs(addrs); err != nil {
+		fd.AutoLogger().Error(context.Background(), "fileDiscoverSetAddressesErr",
+			logger.Reflect(logger.ServiceName, fd.path),
+			logger.Error(err),
+		)
+	}
+}
@@ -0,0 +1,48 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/loadbalancer/priority"
+)
+
+func TestFileDiscoverer(t *testing.T) {
+	lb := priority.New()
+
+	_, err := NewFileDiscoverer("", lb)
+	assert.NotNil(t, err)
+	_, err = NewFileDiscoverer("nodes.json", nil)
+	assert.NotNil(t, err)
+
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	fd, err := NewFileDiscoverer(path, lb)
+	assert.Nil(t, err)
+	assert.Nil(t, fd.Stop())
+
+	// file not exist
+	assert.NotNil(t, fd.Start(context.Background()))
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"Nodes": [
+		{"Host": "127.0.0.1", "Port": 80, "Priority": 1},
+		{"Host": "127.0.0.2", "Port": 80}
+	]}`), 0o644))
+	fd, _ = NewFileDiscoverer(path, lb)
+	assert.Nil(t, fd.Start(context.Background()))
+	defer fd.Stop()
+
+	a, err := lb.Pick(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.2:80", a.String())
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"Nodes": [{"Host": "127.0.0.1", "Port": 80, "Priority": 1}]}`), 0o644))
+	assert.Eventually(t, func() bool {
+		a, _ := lb.Pick(context.Background())
+		return a.String() == "127.0.0.1:80"
+	}, time.Second*3, time.Millisecond*10)
+}
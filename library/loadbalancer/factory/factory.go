@@ -0,0 +1,20 @@
+package factory
+
+import (
+	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/loadbalancer/leastrequest"
+	"github.com/air-go/rpc/library/loadbalancer/priority"
+	"github.com/air-go/rpc/library/loadbalancer/roundrobin"
+)
+
+func New(t string) loadbalancer.LoadBalancer {
+	switch t {
+	case loadbalancer.TypeRoundRobin:
+		return roundrobin.New()
+	case loadbalancer.TypePriority:
+		return priority.New()
+	case loadbalancer.TypeLeastRequest:
+		return leastrequest.New()
+	}
+	return roundrobin.New()
+}
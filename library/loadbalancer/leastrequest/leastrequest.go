@@ -0,0 +1,124 @@
+// leastrequest is load balance by the least outstanding requests,
+// the picked addr is counted in flight until Back is called.
+package leastrequest
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/air-go/rpc/library/loadbalancer"
+)
+
+type LeastRequest struct {
+	lock  sync.Mutex
+	addrs []net.Addr
+	// inflight count by addr.String(), contains removed addrs which still have outstanding requests,
+	// so Back after SetAddrs neither lost nor corrupt the count.
+	inflight map[string]int64
+	current  map[string]struct{}
+	rand     *rand.Rand
+}
+
+var _ loadbalancer.LoadBalancer = (*LeastRequest)(nil)
+
+func New() *LeastRequest {
+	return &LeastRequest{
+		inflight: make(map[string]int64),
+		current:  make(map[string]struct{}),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (lr *LeastRequest) Strategy() string {
+	return "LeastRequest"
+}
+
+func (lr *LeastRequest) SetAddrs(addrs []net.Addr) error {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+
+	current := make(map[string]struct{}, len(addrs))
+	for _, a := range addrs {
+		current[a.String()] = struct{}{}
+	}
+
+	// keep the count of removed addrs until all of their requests back
+	for key, n := range lr.inflight {
+		if _, ok := current[key]; !ok && n <= 0 {
+			delete(lr.inflight, key)
+		}
+	}
+
+	lr.addrs = addrs
+	lr.current = current
+	return nil
+}
+
+// Pick choose the addr with the least outstanding requests, ties are broken randomly.
+func (lr *LeastRequest) Pick(context.Context) (net.Addr, error) {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+
+	if len(lr.addrs) == 0 {
+		return nil, loadbalancer.ErrAddrsEmpty
+	}
+
+	var (
+		min    int64 = -1
+		picked net.Addr
+		ties   int
+	)
+	for _, a := range lr.addrs {
+		n := lr.inflight[a.String()]
+		switch {
+		case min == -1 || n < min:
+			min, picked, ties = n, a, 1
+		case n == min:
+			// reservoir sampling, every tied addr has the same chance
+			ties++
+			if lr.rand.Intn(ties) == 0 {
+				picked = a
+			}
+		}
+	}
+
+	lr.inflight[picked.String()]++
+	return picked, nil
+}
+
+// Back finish one outstanding request of addr.
+func (lr *LeastRequest) Back(a net.Addr, _ error) {
+	if a == nil {
+		return
+	}
+
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+
+	key := a.String()
+	n, ok := lr.inflight[key]
+	if !ok {
+		return
+	}
+
+	n = n - 1
+	if _, exist := lr.current[key]; n <= 0 && !exist {
+		delete(lr.inflight, key)
+		return
+	}
+	if n < 0 {
+		n = 0
+	}
+	lr.inflight[key] = n
+}
+
+// Inflight return the outstanding requests of addr.
+func (lr *LeastRequest) Inflight(a net.Addr) int64 {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+
+	return lr.inflight[a.String()]
+}
@@ -0,0 +1,94 @@
+package leastrequest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/loadbalancer"
+)
+
+func newAddr(port int) net.Addr {
+	return &addr.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+}
+
+func TestLeastRequest_Pick(t *testing.T) {
+	lb := New()
+	assert.Equal(t, "LeastRequest", lb.Strategy())
+
+	_, err := lb.Pick(context.TODO())
+	assert.Equal(t, loadbalancer.ErrAddrsEmpty, err)
+
+	a80, a81, a82 := newAddr(80), newAddr(81), newAddr(82)
+	_ = lb.SetAddrs([]net.Addr{a80, a81, a82})
+
+	// every addr is picked once before any is picked twice
+	picked := make(map[string]int)
+	for i := 0; i < 3; i++ {
+		a, err := lb.Pick(context.TODO())
+		assert.Nil(t, err)
+		picked[a.String()]++
+	}
+	assert.Equal(t, 3, len(picked))
+
+	// the only one with least outstanding is picked
+	lb.Back(a81, nil)
+	assert.Equal(t, int64(0), lb.Inflight(a81))
+	a, _ := lb.Pick(context.TODO())
+	assert.Equal(t, a81.String(), a.String())
+	assert.Equal(t, int64(1), lb.Inflight(a80))
+	assert.Equal(t, int64(1), lb.Inflight(a81))
+	assert.Equal(t, int64(1), lb.Inflight(a82))
+}
+
+func TestLeastRequest_RandomTie(t *testing.T) {
+	lb := New()
+	_ = lb.SetAddrs([]net.Addr{newAddr(80), newAddr(81), newAddr(82)})
+
+	picked := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		a, _ := lb.Pick(context.TODO())
+		picked[a.String()]++
+		lb.Back(a, nil)
+	}
+	assert.Equal(t, 3, len(picked))
+	for _, n := range picked {
+		assert.Greater(t, n, 50)
+	}
+}
+
+func TestLeastRequest_SetAddrs(t *testing.T) {
+	lb := New()
+	a80, a81, a82 := newAddr(80), newAddr(81), newAddr(82)
+	_ = lb.SetAddrs([]net.Addr{a80, a81})
+
+	p1, _ := lb.Pick(context.TODO())
+	p2, _ := lb.Pick(context.TODO())
+	assert.NotEqual(t, p1.String(), p2.String())
+
+	// a81 removed while outstanding, a80 kept its count
+	_ = lb.SetAddrs([]net.Addr{a80, a82})
+	assert.Equal(t, int64(1), lb.Inflight(a80))
+	assert.Equal(t, int64(1), lb.Inflight(a81))
+
+	a, _ := lb.Pick(context.TODO())
+	assert.Equal(t, a82.String(), a.String())
+
+	// back of removed addr clean the count
+	lb.Back(a81, nil)
+	assert.Equal(t, int64(0), lb.Inflight(a81))
+	lb.Back(a81, nil)
+	assert.Equal(t, int64(0), lb.Inflight(a81))
+
+	// re-added addr keeps the outstanding count
+	_ = lb.SetAddrs([]net.Addr{a80, a81, a82})
+	assert.Equal(t, int64(1), lb.Inflight(a80))
+	lb.Back(a80, nil)
+	lb.Back(a80, nil)
+	assert.Equal(t, int64(0), lb.Inflight(a80))
+
+	lb.Back(nil, nil)
+}
@@ -5,6 +5,12 @@ import (
 	"net"
 )
 
+const (
+	TypeRoundRobin   = "round_robin"
+	TypePriority     = "priority"
+	TypeLeastRequest = "least_request"
+)
+
 type LoadBalancer interface {
 	Strategy() string
 	SetAddrs([]net.Addr) error
@@ -0,0 +1,203 @@
+// priority is load balance by priority group and weight,
+// traffic only goes to the highest priority group which has healthy addresses,
+// and is distributed by smooth weighted round robin inside the group.
+package priority
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const (
+	defaultMaxFails    = 3
+	defaultFailTimeout = time.Second * 10
+)
+
+type priorityAddr struct {
+	addr          net.Addr
+	priority      int
+	weight        int64
+	currentWeight int64
+	fails         int       // consecutive failures
+	downUntil     time.Time // unhealthy before
+}
+
+func (a *priorityAddr) healthy(now time.Time) bool {
+	return !now.Before(a.downUntil)
+}
+
+type Priority struct {
+	lock        sync.Mutex
+	addrs       map[string]*priorityAddr
+	groups      [][]*priorityAddr // sorted by priority, the lower value the earlier
+	maxFails    int
+	failTimeout time.Duration
+}
+
+var _ loadbalancer.LoadBalancer = (*Priority)(nil)
+
+type Option func(*Priority)
+
+// WithMaxFails set the consecutive failures to mark an address unhealthy
+func WithMaxFails(n int) Option {
+	return func(p *Priority) { p.maxFails = n }
+}
+
+// WithFailTimeout set the duration an unhealthy address is skipped
+func WithFailTimeout(d time.Duration) Option {
+	return func(p *Priority) { p.failTimeout = d }
+}
+
+func New(opts ...Option) *Priority {
+	p := &Priority{
+		addrs:       make(map[string]*priorityAddr),
+		maxFails:    defaultMaxFails,
+		failTimeout: defaultFailTimeout,
+	}
+
+	for _, o := range opts {
+		o(p)
+	}
+
+	if p.maxFails <= 0 {
+		p.maxFails = defaultMaxFails
+	}
+	if p.failTimeout <= 0 {
+		p.failTimeout = defaultFailTimeout
+	}
+
+	return p
+}
+
+func (p *Priority) Strategy() string {
+	return "Priority"
+}
+
+// SetAddrs regroup the addrs, the health of existing addrs is kept.
+// addr not implement addr.WeightedAddr is regarded as priority 0 and weight 1.
+func (p *Priority) SetAddrs(addrs []net.Addr) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var (
+		all    = make(map[string]*priorityAddr, len(addrs))
+		groups = make(map[int][]*priorityAddr)
+	)
+	for _, a := range addrs {
+		key := a.String()
+		if _, ok := all[key]; ok {
+			continue
+		}
+
+		priority, weight := 0, int64(1)
+		if wa, ok := a.(addr.WeightedAddr); ok {
+			priority, weight = wa.Priority(), wa.Weight()
+		}
+		if weight <= 0 {
+			weight = 1
+		}
+
+		pa := &priorityAddr{addr: a, priority: priority, weight: weight}
+		if old, ok := p.addrs[key]; ok {
+			pa.fails, pa.downUntil = old.fails, old.downUntil
+		}
+		all[key] = pa
+		groups[priority] = append(groups[priority], pa)
+	}
+
+	priorities := make([]int, 0, len(groups))
+	for priority := range groups {
+		priorities = append(priorities, priority)
+	}
+	sort.Ints(priorities)
+
+	p.addrs = all
+	p.groups = make([][]*priorityAddr, len(priorities))
+	for idx, priority := range priorities {
+		p.groups[idx] = groups[priority]
+	}
+
+	return nil
+}
+
+// Pick choose the first group which has healthy addrs,
+// if all addrs are unhealthy, the highest priority group is used to avoid rejecting all requests.
+func (p *Priority) Pick(context.Context) (net.Addr, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.groups) == 0 {
+		return nil, loadbalancer.ErrAddrsEmpty
+	}
+
+	now := time.Now()
+	for _, group := range p.groups {
+		if a := pick(group, now, false); a != nil {
+			return a.addr, nil
+		}
+	}
+
+	return pick(p.groups[0], now, true).addr, nil
+}
+
+func (p *Priority) Back(a net.Addr, err error) {
+	if a == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pa, ok := p.addrs[a.String()]
+	if !ok {
+		return
+	}
+
+	// the request not sent is neither success nor failure of addr
+	if errors.Is(err, servicer.ErrNotSent) {
+		return
+	}
+	if err == nil {
+		pa.fails = 0
+		return
+	}
+
+	pa.fails = pa.fails + 1
+	if pa.fails >= p.maxFails {
+		pa.fails = 0
+		pa.downUntil = time.Now().Add(p.failTimeout)
+	}
+}
+
+// pick is smooth weighted round robin of healthy addrs in group.
+func pick(group []*priorityAddr, now time.Time, all bool) *priorityAddr {
+	var (
+		best  *priorityAddr
+		total int64
+	)
+	for _, a := range group {
+		if !all && !a.healthy(now) {
+			continue
+		}
+
+		a.currentWeight = a.currentWeight + a.weight
+		total = total + a.weight
+		if best == nil || a.currentWeight > best.currentWeight {
+			best = a
+		}
+	}
+
+	if best != nil {
+		best.currentWeight = best.currentWeight - total
+	}
+
+	return best
+}
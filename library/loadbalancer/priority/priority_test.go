@@ -0,0 +1,110 @@
+package priority
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func newAddr(port, priority int, weight int64) net.Addr {
+	return addr.NewWeightedTCPAddr(net.ParseIP("127.0.0.1"), port, priority, weight)
+}
+
+func pickCount(t *testing.T, lb *Priority, times int) map[string]int {
+	count := make(map[string]int)
+	for i := 0; i < times; i++ {
+		a, err := lb.Pick(context.TODO())
+		assert.Nil(t, err)
+		count[a.String()]++
+	}
+	return count
+}
+
+func TestPriority_Pick(t *testing.T) {
+	lb := New()
+	assert.Equal(t, "Priority", lb.Strategy())
+
+	_, err := lb.Pick(context.TODO())
+	assert.Equal(t, loadbalancer.ErrAddrsEmpty, err)
+
+	_ = lb.SetAddrs([]net.Addr{
+		newAddr(80, 0, 3),
+		newAddr(81, 0, 1),
+		newAddr(82, 1, 1),
+	})
+
+	// only the highest priority group, by weight
+	count := pickCount(t, lb, 8)
+	assert.Equal(t, 6, count["127.0.0.1:80"])
+	assert.Equal(t, 2, count["127.0.0.1:81"])
+	assert.Equal(t, 0, count["127.0.0.1:82"])
+}
+
+func TestPriority_Failover(t *testing.T) {
+	lb := New(WithMaxFails(2), WithFailTimeout(time.Millisecond*100))
+	a80, a81, a82 := newAddr(80, 0, 1), newAddr(81, 0, 1), newAddr(82, 1, 1)
+	_ = lb.SetAddrs([]net.Addr{a80, a81, a82})
+
+	// a80 down, the rest of group takes all
+	lb.Back(a80, errors.New("err"))
+	lb.Back(a80, errors.New("err"))
+	count := pickCount(t, lb, 4)
+	assert.Equal(t, 4, count["127.0.0.1:81"])
+
+	// success reset consecutive failures
+	lb.Back(a81, errors.New("err"))
+	lb.Back(a81, nil)
+	lb.Back(a81, errors.New("err"))
+	// not sent neither reset nor count
+	lb.Back(a81, servicer.ErrNotSent)
+	lb.Back(a81, servicer.ErrNotSent)
+	count = pickCount(t, lb, 4)
+	assert.Equal(t, 4, count["127.0.0.1:81"])
+
+	// whole group down, fail over to lower priority
+	lb.Back(a81, errors.New("err"))
+	count = pickCount(t, lb, 4)
+	assert.Equal(t, 4, count["127.0.0.1:82"])
+
+	// health is kept after SetAddrs
+	_ = lb.SetAddrs([]net.Addr{a80, a81, a82})
+	count = pickCount(t, lb, 4)
+	assert.Equal(t, 4, count["127.0.0.1:82"])
+
+	// all down, use the highest priority group
+	lb.Back(a82, errors.New("err"))
+	lb.Back(a82, errors.New("err"))
+	count = pickCount(t, lb, 4)
+	assert.Equal(t, 2, count["127.0.0.1:80"])
+	assert.Equal(t, 2, count["127.0.0.1:81"])
+
+	// recover after fail timeout
+	time.Sleep(time.Millisecond * 110)
+	count = pickCount(t, lb, 4)
+	assert.Equal(t, 0, count["127.0.0.1:82"])
+
+	// unknown addr is ignored
+	lb.Back(newAddr(90, 0, 1), errors.New("err"))
+	lb.Back(nil, errors.New("err"))
+}
+
+func TestPriority_PlainAddr(t *testing.T) {
+	lb := New()
+	_ = lb.SetAddrs([]net.Addr{
+		&addr.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 80},
+		&addr.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 81},
+		&addr.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 81},
+	})
+
+	count := pickCount(t, lb, 4)
+	assert.Equal(t, 2, count["127.0.0.1:80"])
+	assert.Equal(t, 2, count["127.0.0.1:81"])
+}
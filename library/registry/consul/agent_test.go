@@ -0,0 +1,177 @@
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAgent is the stand-in of consul agent, only the api used by registry is implemented.
+type fakeAgent struct {
+	*httptest.Server
+	lock     sync.Mutex
+	changed  chan struct{} // closed and replaced on every change
+	index    uint64
+	services map[string]*AgentServiceRegistration
+	passed   map[string]time.Time // check id to the last pass time
+	requests map[string]int       // path prefix to count
+}
+
+func newFakeAgent(t *testing.T) *fakeAgent {
+	a := &fakeAgent{
+		changed:  make(chan struct{}),
+		index:    1,
+		services: make(map[string]*AgentServiceRegistration),
+		passed:   make(map[string]time.Time),
+		requests: make(map[string]int),
+	}
+	a.Server = httptest.NewServer(http.HandlerFunc(a.serve))
+	t.Cleanup(a.Close)
+	return a
+}
+
+func (a *fakeAgent) client() *Client {
+	return NewClient(a.URL)
+}
+
+func (a *fakeAgent) change() {
+	a.index++
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// restart lose all registrations
+func (a *fakeAgent) restart() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.services = make(map[string]*AgentServiceRegistration)
+	a.passed = make(map[string]time.Time)
+	a.change()
+}
+
+func (a *fakeAgent) service(id string) *AgentServiceRegistration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.services[id]
+}
+
+func (a *fakeAgent) count(prefix string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.requests[prefix]
+}
+
+func (a *fakeAgent) serve(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	a.lock.Lock()
+	for _, prefix := range []string{"/v1/agent/service/register", "/v1/agent/service/deregister/", "/v1/agent/check/pass/", "/v1/health/service/"} {
+		if strings.HasPrefix(path, prefix) {
+			a.requests[prefix]++
+		}
+	}
+	a.lock.Unlock()
+
+	switch {
+	case path == "/v1/agent/service/register" && r.Method == http.MethodPut:
+		reg := &AgentServiceRegistration{}
+		if err := json.NewDecoder(r.Body).Decode(reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.lock.Lock()
+		a.services[reg.ID] = reg
+		a.change()
+		a.lock.Unlock()
+	case strings.HasPrefix(path, "/v1/agent/service/deregister/") && r.Method == http.MethodPut:
+		id := strings.TrimPrefix(path, "/v1/agent/service/deregister/")
+		a.lock.Lock()
+		delete(a.services, id)
+		a.change()
+		a.lock.Unlock()
+	case strings.HasPrefix(path, "/v1/agent/check/pass/") && r.Method == http.MethodPut:
+		checkID := strings.TrimPrefix(path, "/v1/agent/check/pass/")
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		for _, reg := range a.services {
+			if reg.Check != nil && reg.Check.CheckID == checkID {
+				if _, ok := a.passed[checkID]; !ok {
+					a.change()
+				}
+				a.passed[checkID] = time.Now()
+				return
+			}
+		}
+		http.Error(w, "CheckID does not have associated TTL", http.StatusNotFound)
+	case strings.HasPrefix(path, "/v1/health/service/") && r.Method == http.MethodGet:
+		a.health(w, r, strings.TrimPrefix(path, "/v1/health/service/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *fakeAgent) health(w http.ResponseWriter, r *http.Request, name string) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+
+	a.lock.Lock()
+	if index > 0 && index == a.index {
+		changed := a.changed
+		a.lock.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+		a.lock.Lock()
+	}
+	defer a.lock.Unlock()
+
+	tag := r.URL.Query().Get("tag")
+	entries := []*ServiceEntry{}
+	for _, reg := range a.services {
+		if reg.Name != name || !a.passing(reg) || (tag != "" && !contains(reg.Tags, tag)) {
+			continue
+		}
+		entry := &ServiceEntry{Service: &AgentService{
+			ID:      reg.ID,
+			Service: reg.Name,
+			Tags:    reg.Tags,
+			Address: reg.Address,
+			Port:    reg.Port,
+			Meta:    reg.Meta,
+		}}
+		entry.Node.Address = "10.0.0.1"
+		if reg.Weights != nil {
+			entry.Service.Weights = *reg.Weights
+		}
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(a.index, 10))
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// passing must be called with lock, the HTTP check is regarded as passing
+func (a *fakeAgent) passing(reg *AgentServiceRegistration) bool {
+	if reg.Check == nil || reg.Check.TTL == "" {
+		return true
+	}
+	ttl, _ := time.ParseDuration(reg.Check.TTL)
+	passed, ok := a.passed[reg.Check.CheckID]
+	return ok && time.Since(passed) < ttl
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
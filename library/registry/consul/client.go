@@ -0,0 +1,209 @@
+// consul is service registry by the http api of consul agent,
+// the registrar register service to local agent and the discovery watch health passing instances by blocking query.
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultAddress = "127.0.0.1:8500"
+
+// ErrNotFound is returned when the agent does not know the service or check, such as after agent restart
+var ErrNotFound = errors.New("consul not found")
+
+type ClientOption struct {
+	token      string
+	datacenter string
+	httpClient *http.Client
+}
+
+type ClientOptionFunc func(*ClientOption)
+
+// WithToken set the acl token
+func WithToken(token string) ClientOptionFunc {
+	return func(o *ClientOption) { o.token = token }
+}
+
+// WithDatacenter set the datacenter of health query, default is the datacenter of agent
+func WithDatacenter(dc string) ClientOptionFunc {
+	return func(o *ClientOption) { o.datacenter = dc }
+}
+
+// WithHTTPClient set the http client, the timeout should be longer than the wait of blocking query
+func WithHTTPClient(c *http.Client) ClientOptionFunc {
+	return func(o *ClientOption) { o.httpClient = c }
+}
+
+// Client is the minimal client of consul agent http api used by registry
+type Client struct {
+	opts    *ClientOption
+	address string
+}
+
+// NewClient address is host:port or url of agent, default is DefaultAddress
+func NewClient(address string, opts ...ClientOptionFunc) *Client {
+	opt := &ClientOption{httpClient: &http.Client{}}
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.httpClient == nil {
+		opt.httpClient = &http.Client{}
+	}
+
+	if address == "" {
+		address = DefaultAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	return &Client{opts: opt, address: strings.TrimSuffix(address, "/")}
+}
+
+// AgentServiceCheck is the check registered with service, one of TTL and HTTP should be set
+type AgentServiceCheck struct {
+	CheckID                        string `json:",omitempty"`
+	TTL                            string `json:",omitempty"`
+	HTTP                           string `json:",omitempty"`
+	Interval                       string `json:",omitempty"`
+	Timeout                        string `json:",omitempty"`
+	DeregisterCriticalServiceAfter string `json:",omitempty"`
+}
+
+type AgentWeights struct {
+	Passing int
+	Warning int
+}
+
+type AgentServiceRegistration struct {
+	ID      string
+	Name    string
+	Tags    []string           `json:",omitempty"`
+	Address string             `json:",omitempty"`
+	Port    int                `json:",omitempty"`
+	Meta    map[string]string  `json:",omitempty"`
+	Weights *AgentWeights      `json:",omitempty"`
+	Check   *AgentServiceCheck `json:",omitempty"`
+}
+
+type AgentService struct {
+	ID      string
+	Service string
+	Tags    []string
+	Address string
+	Port    int
+	Meta    map[string]string
+	Weights AgentWeights
+}
+
+type ServiceEntry struct {
+	Node struct {
+		Node    string
+		Address string
+	}
+	Service *AgentService
+}
+
+// Register register service to agent, the registration with the same ID is replaced
+func (c *Client) Register(ctx context.Context, reg *AgentServiceRegistration) error {
+	return c.put(ctx, "/v1/agent/service/register", reg)
+}
+
+func (c *Client) Deregister(ctx context.Context, serviceID string) error {
+	return c.put(ctx, "/v1/agent/service/deregister/"+url.PathEscape(serviceID), nil)
+}
+
+// PassTTL mark the TTL check passing
+func (c *Client) PassTTL(ctx context.Context, checkID string) error {
+	return c.put(ctx, "/v1/agent/check/pass/"+url.PathEscape(checkID), nil)
+}
+
+// HealthService query the health passing instances of service,
+// it blocks until the index changed or wait elapsed if index > 0, and returns the new index.
+func (c *Client) HealthService(ctx context.Context, service, tag string, index uint64, wait time.Duration) ([]*ServiceEntry, uint64, error) {
+	query := url.Values{}
+	query.Set("passing", "1")
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	if c.opts.datacenter != "" {
+		query.Set("dc", c.opts.datacenter)
+	}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%dms", wait.Milliseconds()))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/v1/health/service/"+url.PathEscape(service)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	entries := []*ServiceEntry{}
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, errors.Wrap(err, "decode health service")
+	}
+
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "parse X-Consul-Index")
+	}
+
+	return entries, newIndex, nil
+}
+
+func (c *Client) put(ctx context.Context, path string, body interface{}) error {
+	resp, err := c.do(ctx, http.MethodPut, path, body)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.address+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.token != "" {
+		req.Header.Set("X-Consul-Token", c.opts.token)
+	}
+
+	resp, err := c.opts.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(ErrNotFound, "%s %s: %s", method, path, strings.TrimSpace(string(b)))
+	}
+	return nil, errors.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,192 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/why444216978/go-util/nopanic"
+
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type options struct {
+	logger        logger.Logger
+	tag           string
+	wait          time.Duration
+	retryInterval time.Duration
+}
+
+type Option func(*options)
+
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithTag only discover the instances with tag
+func WithTag(tag string) Option {
+	return func(o *options) { o.tag = tag }
+}
+
+// WithWait set the max wait of each blocking query, default is 1m
+func WithWait(wait time.Duration) Option {
+	return func(o *options) { o.wait = wait }
+}
+
+// WithRetryInterval set the interval between retries after query failed, default is 1s
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) { o.retryInterval = d }
+}
+
+// ConsulDiscovery watch the health passing instances of service by blocking query
+type ConsulDiscovery struct {
+	*options
+	setup.SetupLogger
+	cli         *Client
+	serviceName string
+	lock        sync.RWMutex
+	nodes       []*registry.Node
+	updateTime  time.Time
+	index       uint64
+	subscribers *registry.Subscribers
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+var _ registry.Discovery = (*ConsulDiscovery)(nil)
+
+// NewDiscovery query the instances once, return error if failed, then watch in the background.
+func NewDiscovery(cli *Client, name string, opts ...Option) (*ConsulDiscovery, error) {
+	if cli == nil {
+		return nil, errors.New("cli is nil")
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return nil, errors.New("serviceName is nil")
+	}
+
+	opt := &options{
+		wait:          time.Minute,
+		retryInterval: time.Second,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	cd := &ConsulDiscovery{
+		options:     opt,
+		cli:         cli,
+		serviceName: name,
+		subscribers: registry.NewSubscribers(),
+		done:        make(chan struct{}),
+	}
+	cd.SetupLogger.SetLogger(opt.logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := cd.query(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	cd.cancel = cancel
+	cd.watch(ctx)
+
+	return cd, nil
+}
+
+func (cd *ConsulDiscovery) GetNodes() []servicer.Node {
+	cd.lock.RLock()
+	defer cd.lock.RUnlock()
+
+	nodes := make([]servicer.Node, 0, len(cd.nodes))
+	for _, node := range cd.nodes {
+		nodes = append(nodes, toServicerNode(node))
+	}
+	return nodes
+}
+
+func (cd *ConsulDiscovery) GetUpdateTime() time.Time {
+	cd.lock.RLock()
+	defer cd.lock.RUnlock()
+	return cd.updateTime
+}
+
+func (cd *ConsulDiscovery) Subscribe(f func(events []registry.NodeEvent)) (unsubscribe func()) {
+	return cd.subscribers.Subscribe(f)
+}
+
+// Close stop watching and wait the loop exit.
+func (cd *ConsulDiscovery) Close() error {
+	cd.cancel()
+	<-cd.done
+	return nil
+}
+
+func (cd *ConsulDiscovery) watch(ctx context.Context) {
+	go nopanic.GoVoid(ctx, func() {
+		defer close(cd.done)
+
+		for {
+			err := cd.query(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				continue
+			}
+
+			cd.AutoLogger().Warn(ctx, "consulDiscoveryQueryErr",
+				logger.Reflect(logger.ServiceName, cd.serviceName),
+				logger.Error(err),
+			)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cd.retryInterval):
+			}
+		}
+	})
+}
+
+// query block until the index changed, then replace all nodes.
+func (cd *ConsulDiscovery) query(ctx context.Context) error {
+	entries, index, err := cd.cli.HealthService(ctx, cd.serviceName, cd.tag, cd.index, cd.wait)
+	if err != nil {
+		return err
+	}
+
+	// the index went backwards such as consul restored, restart from 0
+	if index < cd.index {
+		cd.index = 0
+		return nil
+	}
+	if index == cd.index {
+		return nil
+	}
+	cd.index = index
+
+	nodes := make([]*registry.Node, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Service == nil {
+			continue
+		}
+		nodes = append(nodes, toNode(entry))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return servicer.GenerateAddress(nodes[i].Host, nodes[i].Port) < servicer.GenerateAddress(nodes[j].Host, nodes[j].Port)
+	})
+
+	cd.lock.Lock()
+	cd.nodes = nodes
+	cd.updateTime = time.Now()
+	cd.lock.Unlock()
+
+	cd.subscribers.Update(cd.GetNodes())
+
+	return nil
+}
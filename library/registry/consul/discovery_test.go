@@ -0,0 +1,132 @@
+package consul
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func addresses(nodes []servicer.Node) []string {
+	res := []string{}
+	for _, n := range nodes {
+		res = append(res, n.Address())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func register(t *testing.T, cli *Client, name, host string, port int, opts ...RegistrarOptionFunc) *ConsulRegistrar {
+	r, err := NewRegistry(cli, name, host, port, append([]RegistrarOptionFunc{WithRegistrarTTL(time.Minute)}, opts...)...)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Register(context.Background()))
+	t.Cleanup(func() { _ = r.DeRegister(context.Background()) })
+	return r
+}
+
+func TestToNode(t *testing.T) {
+	entry := &ServiceEntry{Service: &AgentService{
+		Port:    80,
+		Tags:    []string{"v1", "lane=green", "zone=sh", "=invalid"},
+		Meta:    map[string]string{"lane": "blue", MetaZone: "bj", MetaPriority: "2", MetaStartTime: "100"},
+		Weights: AgentWeights{Passing: 10},
+	}}
+	entry.Node.Address = "10.0.0.1"
+
+	assert.Equal(t, &registry.Node{
+		Host:      "10.0.0.1",
+		Port:      80,
+		Weight:    10,
+		Priority:  2,
+		Zone:      "bj",
+		Meta:      map[string]string{"lane": "blue", "zone": "sh"},
+		StartTime: 100,
+	}, toNode(entry))
+}
+
+func TestConsulDiscovery(t *testing.T) {
+	agent := newFakeAgent(t)
+	cli := agent.client()
+
+	_, err := NewDiscovery(nil, "svc")
+	assert.NotNil(t, err)
+	_, err = NewDiscovery(cli, " ")
+	assert.NotNil(t, err)
+
+	register(t, cli, "svc", "127.0.0.1", 80, WithRegistrarWeight(10), WithRegistrarZone("bj"), WithRegistrarMeta(map[string]string{"lane": "blue"}))
+	register(t, cli, "svc-admin", "127.0.0.2", 80)
+
+	d, err := NewDiscovery(cli, "svc", WithWait(time.Second))
+	assert.Nil(t, err)
+	defer d.Close()
+
+	nodes := d.GetNodes()
+	assert.Equal(t, []string{"127.0.0.1:80"}, addresses(nodes))
+	assert.Equal(t, 10, nodes[0].Weight())
+	assert.Equal(t, "bj", nodes[0].Zone())
+	assert.Equal(t, "blue", nodes[0].Meta()["lane"])
+
+	var (
+		lock   sync.Mutex
+		events []string
+	)
+	d.Subscribe(func(evs []registry.NodeEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, ev := range evs {
+			events = append(events, ev.Type.String()+" "+ev.Node.Address())
+		}
+	})
+
+	// watched by blocking query
+	r := register(t, cli, "svc", "127.0.0.3", 80)
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 2
+	}, time.Second*3, time.Millisecond*10)
+
+	assert.Nil(t, r.DeRegister(context.Background()))
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 1
+	}, time.Second*3, time.Millisecond*10)
+
+	lock.Lock()
+	assert.Equal(t, []string{"add 127.0.0.1:80", "add 127.0.0.3:80", "delete 127.0.0.3:80"}, events)
+	lock.Unlock()
+
+	// index reset
+	agent.lock.Lock()
+	agent.index = 1
+	agent.change()
+	agent.lock.Unlock()
+	register(t, cli, "svc", "127.0.0.4", 80)
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 2
+	}, time.Second*3, time.Millisecond*10)
+}
+
+func TestConsulDiscovery_Tag(t *testing.T) {
+	agent := newFakeAgent(t)
+	cli := agent.client()
+
+	register(t, cli, "svc", "127.0.0.1", 80, WithRegistrarTags("canary"))
+	register(t, cli, "svc", "127.0.0.2", 80)
+
+	d, err := NewDiscovery(cli, "svc", WithTag("canary"))
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Equal(t, []string{"127.0.0.1:80"}, addresses(d.GetNodes()))
+}
+
+func TestConsulDiscovery_Unavailable(t *testing.T) {
+	agent := newFakeAgent(t)
+	agent.Close()
+
+	_, err := NewDiscovery(agent.client(), "svc")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,89 @@
+package consul
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// The fields of registry.Node without counterpart in consul are kept in service meta
+const (
+	MetaZone      = "zone"
+	MetaPriority  = "priority"
+	MetaStartTime = "start_time"
+)
+
+// toRegistration map node to the service registration, weight is the passing weight
+func toRegistration(id, name string, node *registry.Node, tags []string, check *AgentServiceCheck) *AgentServiceRegistration {
+	meta := make(map[string]string, len(node.Meta)+3)
+	for k, v := range node.Meta {
+		meta[k] = v
+	}
+	if node.Zone != "" {
+		meta[MetaZone] = node.Zone
+	}
+	if node.Priority != 0 {
+		meta[MetaPriority] = strconv.Itoa(node.Priority)
+	}
+	if node.StartTime != 0 {
+		meta[MetaStartTime] = strconv.FormatInt(node.StartTime, 10)
+	}
+
+	reg := &AgentServiceRegistration{
+		ID:      id,
+		Name:    name,
+		Tags:    tags,
+		Address: node.Host,
+		Port:    node.Port,
+		Meta:    meta,
+		Check:   check,
+	}
+	if node.Weight > 0 {
+		reg.Weights = &AgentWeights{Passing: node.Weight, Warning: 1}
+	}
+	return reg
+}
+
+// toNode map the health entry to node, the tag like key=value is mapped to meta if the key is not in service meta,
+// the address of consul node is used if service address empty.
+func toNode(entry *ServiceEntry) *registry.Node {
+	svc := entry.Service
+	node := &registry.Node{
+		Host:   svc.Address,
+		Port:   svc.Port,
+		Weight: svc.Weights.Passing,
+		Meta:   make(map[string]string, len(svc.Meta)+len(svc.Tags)),
+	}
+	if node.Host == "" {
+		node.Host = entry.Node.Address
+	}
+
+	for _, tag := range svc.Tags {
+		if k, v, ok := strings.Cut(tag, "="); ok && k != "" {
+			node.Meta[k] = v
+		}
+	}
+	for k, v := range svc.Meta {
+		switch k {
+		case MetaZone:
+			node.Zone = v
+		case MetaPriority:
+			node.Priority, _ = strconv.Atoi(v)
+		case MetaStartTime:
+			node.StartTime, _ = strconv.ParseInt(v, 10, 64)
+		default:
+			node.Meta[k] = v
+		}
+	}
+
+	return node
+}
+
+func toServicerNode(node *registry.Node) servicer.Node {
+	return servicer.NewNode(node.Host, node.Port,
+		servicer.WithWeight(node.Weight),
+		servicer.WithZone(node.Zone),
+		servicer.WithMeta(node.Meta))
+}
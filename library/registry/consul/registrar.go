@@ -0,0 +1,222 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type RegistrarOption struct {
+	id              string
+	tags            []string
+	weight          int
+	priority        int
+	zone            string
+	meta            map[string]string
+	ttl             time.Duration
+	httpCheck       string
+	interval        time.Duration
+	deregisterAfter time.Duration
+	notify          func(registry.Event)
+}
+
+type RegistrarOptionFunc func(*RegistrarOption)
+
+func defaultRegistrarOption() *RegistrarOption {
+	return &RegistrarOption{
+		ttl:             10 * time.Second,
+		interval:        10 * time.Second,
+		deregisterAfter: time.Minute,
+	}
+}
+
+// WithRegistrarID set the service id, default is name-host:port
+func WithRegistrarID(id string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.id = id }
+}
+
+func WithRegistrarTags(tags ...string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.tags = tags }
+}
+
+func WithRegistrarWeight(weight int) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.weight = weight }
+}
+
+func WithRegistrarPriority(priority int) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.priority = priority }
+}
+
+func WithRegistrarZone(zone string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.zone = zone }
+}
+
+func WithRegistrarMeta(meta map[string]string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.meta = meta }
+}
+
+// WithRegistrarTTL use TTL check passed by registrar every ttl/3, it is the default check with 10s
+func WithRegistrarTTL(ttl time.Duration) RegistrarOptionFunc {
+	return func(o *RegistrarOption) {
+		o.ttl = ttl
+		o.httpCheck = ""
+	}
+}
+
+// WithRegistrarHTTPCheck use HTTP check requested by agent every interval instead of TTL check
+func WithRegistrarHTTPCheck(url string, interval time.Duration) RegistrarOptionFunc {
+	return func(o *RegistrarOption) {
+		o.httpCheck = url
+		o.interval = interval
+	}
+}
+
+// WithRegistrarDeregisterAfter set the duration after which the critical service is deregistered by consul
+func WithRegistrarDeregisterAfter(d time.Duration) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.deregisterAfter = d }
+}
+
+// WithRegistrarNotify set the func called on every registration state change, it should not block
+func WithRegistrarNotify(f func(registry.Event)) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.notify = f }
+}
+
+// ConsulRegistrar register service to the local consul agent
+type ConsulRegistrar struct {
+	opts   *RegistrarOption
+	cli    *Client
+	reg    *AgentServiceRegistration
+	states *registry.States
+}
+
+var _ registry.Registrar = (*ConsulRegistrar)(nil)
+
+// NewRegistry
+func NewRegistry(cli *Client, name, host string, port int, opts ...RegistrarOptionFunc) (*ConsulRegistrar, error) {
+	if cli == nil {
+		return nil, errors.New("cli is nil")
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return nil, errors.New("serviceName is nil")
+	}
+
+	opt := defaultRegistrarOption()
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.httpCheck == "" && opt.ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+	if opt.id == "" {
+		opt.id = name + "-" + servicer.GenerateAddress(host, port)
+	}
+
+	check := &AgentServiceCheck{
+		CheckID:                        "service:" + opt.id,
+		DeregisterCriticalServiceAfter: durationString(opt.deregisterAfter),
+	}
+	if opt.httpCheck != "" {
+		check.HTTP = opt.httpCheck
+		check.Interval = durationString(opt.interval)
+		check.Timeout = durationString(opt.interval / 2)
+	} else {
+		check.TTL = durationString(opt.ttl)
+	}
+
+	node := &registry.Node{
+		Host:      host,
+		Port:      port,
+		Weight:    opt.weight,
+		Priority:  opt.priority,
+		Zone:      opt.zone,
+		Meta:      opt.meta,
+		StartTime: registry.StartTime().Unix(),
+	}
+
+	return &ConsulRegistrar{
+		opts:   opt,
+		cli:    cli,
+		reg:    toRegistration(opt.id, name, node, opt.tags, check),
+		states: registry.NewStates(opt.notify),
+	}, nil
+}
+
+// Register register service to agent, the TTL check is passed in the background until DeRegister,
+// and the service is registered again if the agent lost it.
+func (s *ConsulRegistrar) Register(ctx context.Context) error {
+	return s.states.Register(func(context.Context) error {
+		return s.register(ctx)
+	}, s.keepAlive)
+}
+
+// State return the current registration state
+func (s *ConsulRegistrar) State() registry.State {
+	return s.states.State()
+}
+
+// DeRegister stop passing the TTL check, then deregister service from agent
+func (s *ConsulRegistrar) DeRegister(ctx context.Context) error {
+	return s.states.DeRegister(func() error {
+		return s.cli.Deregister(ctx, s.reg.ID)
+	})
+}
+
+func (s *ConsulRegistrar) register(ctx context.Context) error {
+	if err := s.cli.Register(ctx, s.reg); err != nil {
+		return err
+	}
+	if s.reg.Check.TTL == "" {
+		return nil
+	}
+	return s.cli.PassTTL(ctx, s.reg.Check.CheckID)
+}
+
+// keepAlive pass the TTL check, register again if the check not found
+func (s *ConsulRegistrar) keepAlive(ctx context.Context) {
+	if s.reg.Check.TTL == "" {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(s.opts.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cctx, cancel := context.WithTimeout(ctx, s.opts.ttl/3)
+		err := s.cli.PassTTL(cctx, s.reg.Check.CheckID)
+		if errors.Is(err, ErrNotFound) {
+			err = s.register(cctx)
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err != nil:
+			s.states.Set(registry.StateLost, err)
+		case s.states.State() != registry.StateRegistered:
+			s.states.Set(registry.StateRegistered, nil)
+		}
+	}
+}
+
+// durationString format duration as consul accepted, such as 10s
+func durationString(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
@@ -0,0 +1,127 @@
+package consul
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+)
+
+type recordEvents struct {
+	lock   sync.Mutex
+	states []registry.State
+}
+
+func (r *recordEvents) notify(e registry.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.states = append(r.states, e.State)
+}
+
+func (r *recordEvents) get() []registry.State {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]registry.State{}, r.states...)
+}
+
+func TestNewRegistry(t *testing.T) {
+	cli := NewClient("")
+	assert.Equal(t, "http://"+DefaultAddress, cli.address)
+
+	_, err := NewRegistry(nil, "svc", "127.0.0.1", 80)
+	assert.NotNil(t, err)
+	_, err = NewRegistry(cli, "", "127.0.0.1", 80)
+	assert.NotNil(t, err)
+	_, err = NewRegistry(cli, "svc", "127.0.0.1", 80, WithRegistrarTTL(0))
+	assert.NotNil(t, err)
+
+	r, err := NewRegistry(cli, "svc", "127.0.0.1", 80, WithRegistrarHTTPCheck("http://127.0.0.1:80/health", time.Second*5))
+	assert.Nil(t, err)
+	assert.Equal(t, "svc-127.0.0.1:80", r.reg.ID)
+	assert.Equal(t, &AgentServiceCheck{
+		CheckID:                        "service:svc-127.0.0.1:80",
+		HTTP:                           "http://127.0.0.1:80/health",
+		Interval:                       "5000ms",
+		Timeout:                        "2500ms",
+		DeregisterCriticalServiceAfter: "60000ms",
+	}, r.reg.Check)
+}
+
+func TestConsulRegistrar(t *testing.T) {
+	agent := newFakeAgent(t)
+	ctx := context.Background()
+
+	events := &recordEvents{}
+	r, err := NewRegistry(agent.client(), "svc", "127.0.0.1", 80,
+		WithRegistrarWeight(10),
+		WithRegistrarPriority(1),
+		WithRegistrarZone("bj"),
+		WithRegistrarMeta(map[string]string{"lane": "blue"}),
+		WithRegistrarTags("v1"),
+		WithRegistrarTTL(time.Millisecond*300),
+		WithRegistrarNotify(events.notify),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, registry.StateUnregistered, r.State())
+
+	assert.Nil(t, r.Register(ctx))
+	assert.NotNil(t, r.Register(ctx))
+	assert.Equal(t, registry.StateRegistered, r.State())
+
+	reg := agent.service("svc-127.0.0.1:80")
+	assert.NotNil(t, reg)
+	assert.Equal(t, "svc", reg.Name)
+	assert.Equal(t, []string{"v1"}, reg.Tags)
+	assert.Equal(t, 10, reg.Weights.Passing)
+	assert.Equal(t, "blue", reg.Meta["lane"])
+	assert.Equal(t, "bj", reg.Meta[MetaZone])
+	assert.Equal(t, "1", reg.Meta[MetaPriority])
+	assert.Equal(t, "300ms", reg.Check.TTL)
+
+	// the TTL check is passed in the background
+	assert.Eventually(t, func() bool {
+		return agent.count("/v1/agent/check/pass/") >= 3
+	}, time.Second*3, time.Millisecond*10)
+
+	// agent lost the registration, register again
+	agent.restart()
+	assert.Eventually(t, func() bool {
+		return agent.service("svc-127.0.0.1:80") != nil
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, registry.StateRegistered, r.State())
+
+	// agent unreachable
+	agent.CloseClientConnections()
+	agent.Config.SetKeepAlivesEnabled(false)
+	agent.Listener.Close()
+	assert.Eventually(t, func() bool {
+		return r.State() == registry.StateLost
+	}, time.Second*3, time.Millisecond*10)
+	assert.NotNil(t, r.DeRegister(ctx))
+	assert.Equal(t, []registry.State{registry.StateRegistered, registry.StateLost}, events.get()[:2])
+}
+
+func TestConsulRegistrar_DeRegister(t *testing.T) {
+	agent := newFakeAgent(t)
+	ctx := context.Background()
+
+	// the notify func reading the state must not deadlock
+	var r *ConsulRegistrar
+	seen := &recordEvents{}
+	r, err := NewRegistry(agent.client(), "svc", "127.0.0.1", 80, WithRegistrarID("svc-1"), WithRegistrarHTTPCheck("http://127.0.0.1:80/health", time.Second),
+		WithRegistrarNotify(func(registry.Event) { seen.notify(registry.Event{State: r.State()}) }),
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Register(ctx))
+	assert.NotNil(t, agent.service("svc-1"))
+	assert.Equal(t, 0, agent.count("/v1/agent/check/pass/"))
+
+	assert.Nil(t, r.DeRegister(ctx))
+	assert.Nil(t, agent.service("svc-1"))
+	assert.Equal(t, registry.StateDeregistered, r.State())
+	assert.Equal(t, []registry.State{registry.StateRegistered, registry.StateDeregistered}, seen.get())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
@@ -17,6 +18,12 @@ import (
 )
 
 type DiscoveryOption struct {
+	namespace       string
+	env             string
+	legacy          bool
+	snapshotDir     string
+	protect         float64
+	protectDuration time.Duration
 	refreshDuration time.Duration
 	cmdTimeout      time.Duration
 	decode          registry.Decode
@@ -26,6 +33,8 @@ type DiscoveryOptionFunc func(*DiscoveryOption)
 
 func defaultDiscoveryOption() *DiscoveryOption {
 	return &DiscoveryOption{
+		legacy:          true,
+		protectDuration: time.Minute,
 		refreshDuration: time.Second * 10,
 		cmdTimeout:      time.Second * 3,
 		decode:          JSONDecode,
@@ -41,10 +50,50 @@ type EtcdDiscovery struct {
 	updateTime  time.Time
 	ticker      *time.Ticker
 	serviceName string
+	prefix      string
+	subscribers *registry.Subscribers
+	publishLock sync.Mutex // make the change and the publish of it atomic
+	snapshot    *registry.Snapshot
+	protector   *registry.Protector
+	stale       bool
+	snapshotted bool  // the nodes are loaded from snapshot, the next full load is accepted without protect
+	revision    int64 // the revision of last load
+	cancel      context.CancelFunc
 }
 
 var _ registry.Discovery = (*EtcdDiscovery)(nil)
 
+// WithNamespace set the root of keys, default is DefaultNamespace
+func WithNamespace(namespace string) DiscoveryOptionFunc {
+	return func(o *DiscoveryOption) { o.namespace = namespace }
+}
+
+// WithEnv set the env of keys, default is DefaultEnv
+func WithEnv(env string) DiscoveryOptionFunc {
+	return func(o *DiscoveryOption) { o.env = env }
+}
+
+// WithLegacy set whether to read the legacy key layout too, default is true until all registrars migrated
+func WithLegacy(legacy bool) DiscoveryOptionFunc {
+	return func(o *DiscoveryOption) { o.legacy = legacy }
+}
+
+// WithSnapshotDir persist the last good nodes to dir, which are loaded if etcd is unavailable at start
+func WithSnapshotDir(dir string) DiscoveryOptionFunc {
+	return func(o *DiscoveryOption) { o.snapshotDir = dir }
+}
+
+// WithProtectThreshold refuse the update which drop more than ratio of nodes since the last full load, such as 0.5,
+// the nodes are kept and marked stale until the update is acceptable or the protect duration passed.
+func WithProtectThreshold(ratio float64) DiscoveryOptionFunc {
+	return func(o *DiscoveryOption) { o.protect = ratio }
+}
+
+// WithProtectDuration bound how long the protection holds, default is one minute, <= 0 means no bound.
+func WithProtectDuration(d time.Duration) DiscoveryOptionFunc {
+	return func(o *DiscoveryOption) { o.protectDuration = d }
+}
+
 func WithRefreshDuration(d int) DiscoveryOptionFunc {
 	return func(o *DiscoveryOption) { o.refreshDuration = time.Duration(d) * time.Second }
 }
@@ -73,6 +122,12 @@ func NewDiscovery(cli *clientv3.Client, name string, opts ...DiscoveryOptionFunc
 		cli:         cli,
 		nodeList:    make(map[string]*registry.Node),
 		serviceName: name,
+		prefix:      ServicePrefix(opt.namespace, opt.env, name),
+		subscribers: registry.NewSubscribers(),
+		protector:   registry.NewProtector(opt.protect, opt.protectDuration),
+	}
+	if opt.snapshotDir != "" {
+		ed.snapshot = registry.NewSnapshot(opt.snapshotDir, name)
 	}
 
 	if err := ed.init(); err != nil {
@@ -82,25 +137,56 @@ func NewDiscovery(cli *clientv3.Client, name string, opts ...DiscoveryOptionFunc
 	return ed, nil
 }
 
-// GetNodes
+// GetNodes the node registered in both layouts is returned once, the current layout is preferred
 func (s *EtcdDiscovery) GetNodes() []servicer.Node {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 	nodes := make([]servicer.Node, 0)
 
-	for _, node := range s.nodeList {
-		nodes = append(nodes, servicer.NewNode(node.Host, node.Port, servicer.WithWeight(node.Weight)))
+	current := make(map[string]struct{})
+	for key, node := range s.nodeList {
+		if strings.HasPrefix(key, s.prefix) {
+			current[servicer.GenerateAddress(node.Host, node.Port)] = struct{}{}
+		}
+	}
+
+	for key, node := range s.nodeList {
+		if _, ok := current[servicer.GenerateAddress(node.Host, node.Port)]; ok && !strings.HasPrefix(key, s.prefix) {
+			continue
+		}
+		nodes = append(nodes, servicer.NewNode(node.Host, node.Port,
+			servicer.WithWeight(node.Weight),
+			servicer.WithZone(node.Zone),
+			servicer.WithMeta(node.Meta)))
 	}
 	return nodes
 }
 
 // GetUpdateTime
 func (s *EtcdDiscovery) GetUpdateTime() time.Time {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	return s.updateTime
 }
 
+// Stale report whether the nodes may be out of date, which are loaded from snapshot,
+// or the last refresh failed, or the last update was refused by protect threshold.
+func (s *EtcdDiscovery) Stale() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.stale
+}
+
+// Subscribe
+func (s *EtcdDiscovery) Subscribe(f func(events []registry.NodeEvent)) (unsubscribe func()) {
+	return s.subscribers.Subscribe(f)
+}
+
 // Close
 func (s *EtcdDiscovery) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
 	if s.ticker != nil {
 		s.ticker.Stop()
 	}
@@ -112,39 +198,82 @@ func (s *EtcdDiscovery) Close() error {
 
 // WatchService
 func (s *EtcdDiscovery) init() error {
-	// set all nodes
-	s.setNodes()
+	// set all nodes, fall back to snapshot if etcd unavailable
+	if err := s.setNodes(); err != nil {
+		s.loadSnapshot()
+	}
 
 	// start etcd watcher
-	go s.watcher()
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	go s.watcher(ctx, s.prefix, s.revision)
+	if s.opts.legacy {
+		go s.watcher(ctx, legacyPrefix(s.serviceName), s.revision)
+	}
 
 	// start refresh ticker
-	go s.refresh()
+	if s.opts.refreshDuration > 0 {
+		s.ticker = time.NewTicker(s.opts.refreshDuration)
+		go s.refresh()
+	}
 
 	return nil
 }
 
-// loadKVs
-func (s *EtcdDiscovery) loadKVs() (kvs []*mvccpb.KeyValue) {
-	ctx, cancel := s.context()
-	defer cancel()
+// loadKVs return error if any prefix failed, the partial kvs would drop nodes,
+// revision is of the first get, so that watching from it misses nothing.
+func (s *EtcdDiscovery) loadKVs() (kvs []*mvccpb.KeyValue, revision int64, err error) {
+	prefixes := []string{s.prefix}
+	if s.opts.legacy {
+		prefixes = append(prefixes, legacyPrefix(s.serviceName))
+	}
 
-	resp, err := s.cli.Get(ctx, s.serviceName, clientv3.WithPrefix())
-	if err != nil {
-		s.logErr("get by prefix", s.serviceName, "", err)
-		return
+	for _, prefix := range prefixes {
+		ctx, cancel := s.context()
+		resp, err := s.cli.Get(ctx, prefix, clientv3.WithPrefix())
+		cancel()
+		if err != nil {
+			s.logErr("get by prefix", prefix, "", err)
+			return nil, 0, err
+		}
+		if revision == 0 {
+			revision = resp.Header.Revision
+		}
+		kvs = append(kvs, resp.Kvs...)
 	}
-	kvs = resp.Kvs
 	return
 }
 
-// watcher
-func (s *EtcdDiscovery) watcher() {
-	ctx, cancel := s.context()
-	defer cancel()
+// match filter the keys of other services with the same prefix
+func (s *EtcdDiscovery) match(key string, node *registry.Node) bool {
+	if strings.HasPrefix(key, s.prefix) {
+		return isInstanceKey(key, s.prefix)
+	}
+	return s.opts.legacy && isLegacyKey(key, s.serviceName, node)
+}
+
+// watcher keep watching until ctx canceled, the first watch start from the revision after first load,
+// the changes missed while rewatching are synced by refresh.
+func (s *EtcdDiscovery) watcher(ctx context.Context, prefix string, revision int64) {
+	for {
+		s.watch(ctx, prefix, revision)
+		revision = 0
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
 
-	rch := s.cli.Watch(ctx, s.serviceName, clientv3.WithPrefix())
-	s.log("Watch", "")
+func (s *EtcdDiscovery) watch(ctx context.Context, prefix string, revision int64) {
+	opts := []clientv3.OpOption{clientv3.WithPrefix()}
+	if revision > 0 {
+		opts = append(opts, clientv3.WithRev(revision+1))
+	}
+	rch := s.cli.Watch(clientv3.WithRequireLeader(ctx), prefix, opts...)
+	s.log("Watch", prefix)
 	for wresp := range rch {
 		for _, ev := range wresp.Events {
 			key := string(ev.Kv.Key)
@@ -155,7 +284,10 @@ func (s *EtcdDiscovery) watcher() {
 				node, err := s.opts.decode(val)
 				if err != nil {
 					s.logErr("decode val", key, val, err)
-					return
+					continue
+				}
+				if !s.match(key, node) {
+					continue
 				}
 				s.setNode(key, node)
 				s.log("mvccpb.PUT", key)
@@ -169,21 +301,24 @@ func (s *EtcdDiscovery) watcher() {
 
 // refresh
 func (s *EtcdDiscovery) refresh() {
-	if s.opts.refreshDuration == -1 {
-		return
-	}
-
-	s.ticker = time.NewTicker(s.opts.refreshDuration)
 	for range s.ticker.C {
-		s.setNodes()
+		_ = s.setNodes()
 		s.log("refresh", "all")
 	}
 }
 
-// setNodes
-func (s *EtcdDiscovery) setNodes() {
+// setNodes replace all nodes by etcd, the nodes are kept and marked stale if load failed or protected,
+// the first full load after snapshot is always accepted, the snapshot may be larger than the current cluster.
+func (s *EtcdDiscovery) setNodes() error {
+	kvs, revision, err := s.loadKVs()
+	if err != nil {
+		s.lock.Lock()
+		s.stale = true
+		s.lock.Unlock()
+		return err
+	}
+
 	nodeList := make(map[string]*registry.Node)
-	kvs := s.loadKVs()
 	for _, kv := range kvs {
 		key := string(kv.Key)
 		val := string(kv.Value)
@@ -193,29 +328,121 @@ func (s *EtcdDiscovery) setNodes() {
 			s.logErr("decode val", key, val, err)
 			continue
 		}
+		if !s.match(key, node) {
+			continue
+		}
 		nodeList[key] = node
 	}
 
+	s.publishLock.Lock()
+	defer s.publishLock.Unlock()
+
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	s.revision = revision
+	if s.snapshotted {
+		s.protector.Reset(len(nodeList))
+	} else if s.protector.Refuse(len(s.nodeList), len(nodeList), true) {
+		s.stale = true
+		s.lock.Unlock()
+		s.log("protect", "all")
+		return nil
+	}
 	s.nodeList = nodeList
 	s.updateTime = time.Now()
+	s.stale = false
+	s.snapshotted = false
+	s.lock.Unlock()
+
+	s.publish()
+	return nil
 }
 
 // setNode
 func (s *EtcdDiscovery) setNode(key string, node *registry.Node) {
+	s.publishLock.Lock()
+	defer s.publishLock.Unlock()
+
 	s.lock.Lock()
-	defer s.lock.Unlock()
 	s.nodeList[key] = node
 	s.updateTime = time.Now()
+	s.lock.Unlock()
+
+	s.publish()
 }
 
 // delNode
 func (s *EtcdDiscovery) delNode(key string) {
+	s.publishLock.Lock()
+	defer s.publishLock.Unlock()
+
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	if _, ok := s.nodeList[key]; !ok {
+		s.lock.Unlock()
+		return
+	}
+	if s.protector.Refuse(len(s.nodeList), len(s.nodeList)-1, false) {
+		s.stale = true
+		s.lock.Unlock()
+		s.log("protect", key)
+		return
+	}
 	delete(s.nodeList, key)
 	s.updateTime = time.Now()
+	s.lock.Unlock()
+
+	s.publish()
+}
+
+// publish must be called with publishLock, push the changes and save the snapshot
+func (s *EtcdDiscovery) publish() {
+	s.subscribers.Update(s.GetNodes())
+
+	if s.snapshot == nil {
+		return
+	}
+
+	s.lock.RLock()
+	nodeList := make(map[string]*registry.Node, len(s.nodeList))
+	for key, node := range s.nodeList {
+		nodeList[key] = node
+	}
+	stale := s.stale
+	s.lock.RUnlock()
+
+	// the nodes from snapshot are not written back
+	if stale {
+		return
+	}
+	if err := s.snapshot.Save(nodeList); err != nil {
+		s.logErr("save snapshot", s.snapshot.Path(), "", err)
+	}
+}
+
+// loadSnapshot is called only at start when etcd unavailable
+func (s *EtcdDiscovery) loadSnapshot() {
+	if s.snapshot == nil {
+		return
+	}
+
+	nodeList, err := s.snapshot.Load()
+	if err != nil {
+		s.logErr("load snapshot", s.snapshot.Path(), "", err)
+		return
+	}
+
+	s.publishLock.Lock()
+	defer s.publishLock.Unlock()
+
+	s.lock.Lock()
+	s.nodeList = nodeList
+	s.updateTime = time.Now()
+	s.stale = true
+	s.snapshotted = true
+	s.protector.Reset(len(nodeList))
+	s.lock.Unlock()
+
+	s.publish()
+	s.log("load snapshot", s.snapshot.Path())
 }
 
 func (s *EtcdDiscovery) logErr(action, key, val string, err error) {
@@ -230,12 +457,17 @@ func (s *EtcdDiscovery) context() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), s.opts.cmdTimeout)
 }
 
+// JSONDecode decode both the legacy and current schema, the newer schema is rejected
 func JSONDecode(val string) (*registry.Node, error) {
-	node := &registry.Node{}
-	err := json.Unmarshal([]byte(val), node)
+	v := &value{Node: &registry.Node{}}
+	err := json.Unmarshal([]byte(val), v)
 	if err != nil {
 		return nil, errors.New("Unmarshal val " + err.Error())
 	}
 
-	return node, nil
+	if v.Schema > SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema %d", v.Schema)
+	}
+
+	return v.Node, nil
 }
@@ -0,0 +1,288 @@
+package etcd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/mock/tools/embedetcd"
+)
+
+type recordNodeEvents struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *recordNodeEvents) on(events []registry.NodeEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, ev := range events {
+		r.events = append(r.events, ev.Type.String()+" "+ev.Node.Address())
+	}
+}
+
+func (r *recordNodeEvents) get() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string{}, r.events...)
+}
+
+func TestEtcdDiscovery_Subscribe(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	put := func(key string, node *registry.Node) {
+		val, err := JSONEncode(node)
+		assert.Nil(t, err)
+		_, err = cli.Put(ctx, key, val)
+		assert.Nil(t, err)
+	}
+	put("svc.127.0.0.1.80", &registry.Node{Host: "127.0.0.1", Port: 80})
+
+	d, err := NewDiscovery(embedetcd.NewClient(t, endpoint), "svc", WithRefreshDuration(3600), WithCmdTimeOut(time.Millisecond*500))
+	assert.Nil(t, err)
+	defer d.Close()
+
+	r := &recordNodeEvents{}
+	unsubscribe := d.Subscribe(r.on)
+	assert.Equal(t, []string{"add 127.0.0.1:80"}, r.get())
+
+	// watch is still alive after cmd timeout
+	time.Sleep(time.Second)
+	put("svc.127.0.0.2.80", &registry.Node{Host: "127.0.0.2", Port: 80})
+	put("svc.127.0.0.1.80", &registry.Node{Host: "127.0.0.1", Port: 80, Weight: 10})
+	_, err = cli.Delete(ctx, "svc.127.0.0.2.80")
+	assert.Nil(t, err)
+
+	expected := []string{"add 127.0.0.1:80", "add 127.0.0.2:80", "update 127.0.0.1:80", "delete 127.0.0.2:80"}
+	assert.Eventually(t, func() bool {
+		return len(r.get()) == len(expected)
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, expected, r.get())
+
+	unsubscribe()
+	put("svc.127.0.0.3.80", &registry.Node{Host: "127.0.0.3", Port: 80})
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 2
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, expected, r.get())
+}
+
+func TestEtcdDiscovery_Layout(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	put := func(key string, node *registry.Node) {
+		val, err := JSONEncode(node)
+		assert.Nil(t, err)
+		_, err = cli.Put(ctx, key, val)
+		assert.Nil(t, err)
+	}
+	put(InstanceKey("", "", "user", "127.0.0.1:80"), &registry.Node{Host: "127.0.0.1", Port: 80, Weight: 10})
+	put(InstanceKey("", "", "user-admin", "127.0.0.2:80"), &registry.Node{Host: "127.0.0.2", Port: 80})
+	put(InstanceKey("", "", "user", "127.0.0.9:80/extra"), &registry.Node{Host: "127.0.0.9", Port: 80})
+	put(InstanceKey("", "prod", "user", "127.0.0.8:80"), &registry.Node{Host: "127.0.0.8", Port: 80})
+	// legacy layout, the node registered in both layouts is returned once
+	put(LegacyKey("user", "127.0.0.1", 80), &registry.Node{Host: "127.0.0.1", Port: 80, Weight: 1})
+	put(LegacyKey("user", "127.0.0.3", 80), &registry.Node{Host: "127.0.0.3", Port: 80})
+	put(LegacyKey("user.v2", "127.0.0.4", 80), &registry.Node{Host: "127.0.0.4", Port: 80})
+
+	d, err := NewDiscovery(embedetcd.NewClient(t, endpoint), "user", WithRefreshDuration(3600))
+	assert.Nil(t, err)
+	defer d.Close()
+
+	nodes := d.GetNodes()
+	weights := make(map[string]int)
+	for _, n := range nodes {
+		weights[n.Address()] = n.Weight()
+	}
+	assert.Equal(t, map[string]int{"127.0.0.1:80": 10, "127.0.0.3:80": 0}, weights)
+
+	// watched changes are filtered too
+	put(LegacyKey("user.v2", "127.0.0.5", 80), &registry.Node{Host: "127.0.0.5", Port: 80})
+	put(InstanceKey("", "", "user-admin", "127.0.0.6:80"), &registry.Node{Host: "127.0.0.6", Port: 80})
+	put(InstanceKey("", "", "user", "127.0.0.7:80"), &registry.Node{Host: "127.0.0.7", Port: 80})
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 3
+	}, time.Second*3, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 3, len(d.GetNodes()))
+
+	prod, err := NewDiscovery(embedetcd.NewClient(t, endpoint), "user", WithEnv("prod"), WithLegacy(false), WithRefreshDuration(3600))
+	assert.Nil(t, err)
+	defer prod.Close()
+	nodes = prod.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "127.0.0.8:80", nodes[0].Address())
+}
+
+func TestJSONDecode(t *testing.T) {
+	node, err := JSONDecode(`{"Host":"127.0.0.1","Port":80,"Weight":10}`)
+	assert.Nil(t, err)
+	assert.Equal(t, &registry.Node{Host: "127.0.0.1", Port: 80, Weight: 10}, node)
+
+	val, err := JSONEncode(node)
+	assert.Nil(t, err)
+	assert.Contains(t, val, `"Schema":1`)
+	decoded, err := JSONDecode(val)
+	assert.Nil(t, err)
+	assert.Equal(t, node, decoded)
+
+	_, err = JSONDecode(`{"Schema":2,"Host":"127.0.0.1","Port":80}`)
+	assert.NotNil(t, err)
+}
+
+func TestEtcdDiscovery_Snapshot(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	val, err := JSONEncode(&registry.Node{Host: "127.0.0.1", Port: 80, Weight: 10})
+	assert.Nil(t, err)
+	_, err = cli.Put(ctx, InstanceKey("", "", "svc", "127.0.0.1:80"), val)
+	assert.Nil(t, err)
+
+	d, err := NewDiscovery(embedetcd.NewClient(t, endpoint), "svc", WithSnapshotDir(dir), WithRefreshDuration(3600))
+	assert.Nil(t, err)
+	assert.False(t, d.(*EtcdDiscovery).Stale())
+	assert.Nil(t, d.Close())
+
+	// etcd unavailable at start
+	unavailable, err := clientv3.New(clientv3.Config{Endpoints: []string{"127.0.0.1:1"}})
+	assert.Nil(t, err)
+	d, err = NewDiscovery(unavailable, "svc", WithSnapshotDir(dir), WithRefreshDuration(3600), WithCmdTimeOut(time.Millisecond*200))
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.True(t, d.(*EtcdDiscovery).Stale())
+	nodes := d.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "127.0.0.1:80", nodes[0].Address())
+	assert.Equal(t, 10, nodes[0].Weight())
+
+	// refresh failure keeps the nodes
+	assert.NotNil(t, d.(*EtcdDiscovery).setNodes())
+	assert.Equal(t, 1, len(d.GetNodes()))
+}
+
+func TestEtcdDiscovery_Protect(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	put := func(host string) {
+		val, err := JSONEncode(&registry.Node{Host: host, Port: 80})
+		assert.Nil(t, err)
+		_, err = cli.Put(ctx, InstanceKey("", "", "svc", host+":80"), val)
+		assert.Nil(t, err)
+	}
+	for _, host := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"} {
+		put(host)
+	}
+
+	d, err := NewDiscovery(embedetcd.NewClient(t, endpoint), "svc", WithProtectThreshold(0.3), WithRefreshDuration(3600))
+	assert.Nil(t, err)
+	defer d.Close()
+	ed := d.(*EtcdDiscovery)
+	assert.Equal(t, 4, len(d.GetNodes()))
+
+	// the first delete drop 25%, the next one is refused
+	_, err = cli.Delete(ctx, ServicePrefix("", "", "svc"), clientv3.WithPrefix())
+	assert.Nil(t, err)
+	assert.Eventually(t, ed.Stale, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, 3, len(d.GetNodes()))
+
+	// the refresh is refused too
+	assert.Nil(t, ed.setNodes())
+	assert.True(t, ed.Stale())
+	assert.Equal(t, 3, len(d.GetNodes()))
+
+	// the refused nodes 2, 3 and 4 are kept, dropping only 4 is acceptable
+	for _, host := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.5", "127.0.0.6"} {
+		put(host)
+	}
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 6
+	}, time.Second*3, time.Millisecond*10)
+	assert.Nil(t, ed.setNodes())
+	assert.False(t, ed.Stale())
+	assert.Equal(t, 5, len(d.GetNodes()))
+}
+
+func TestEtcdDiscovery_ProtectDuration(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	for _, host := range []string{"127.0.0.1", "127.0.0.2"} {
+		val, err := JSONEncode(&registry.Node{Host: host, Port: 80})
+		assert.Nil(t, err)
+		_, err = cli.Put(ctx, InstanceKey("", "", "svc", host+":80"), val)
+		assert.Nil(t, err)
+	}
+
+	d, err := NewDiscovery(embedetcd.NewClient(t, endpoint), "svc", WithProtectThreshold(0.3),
+		WithProtectDuration(time.Millisecond*500), WithRefreshDuration(3600))
+	assert.Nil(t, err)
+	defer d.Close()
+	ed := d.(*EtcdDiscovery)
+	assert.Equal(t, 2, len(d.GetNodes()))
+
+	// removing one of two nodes drop 50%, refused at first
+	_, err = cli.Delete(ctx, InstanceKey("", "", "svc", "127.0.0.2:80"))
+	assert.Nil(t, err)
+	assert.Eventually(t, ed.Stale, time.Second*3, time.Millisecond*10)
+	assert.Nil(t, ed.setNodes())
+	assert.Equal(t, 2, len(d.GetNodes()))
+
+	// accepted after the protect duration
+	time.Sleep(time.Millisecond * 600)
+	assert.Nil(t, ed.setNodes())
+	assert.False(t, ed.Stale())
+	nodes := d.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "127.0.0.1:80", nodes[0].Address())
+}
+
+func TestEtcdDiscovery_SnapshotProtect(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := map[string]*registry.Node{}
+	for _, host := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"} {
+		snapshot[InstanceKey("", "", "svc", host+":80")] = &registry.Node{Host: host, Port: 80}
+	}
+	assert.Nil(t, registry.NewSnapshot(dir, "svc").Save(snapshot))
+
+	// etcd unavailable at start, the snapshot is loaded
+	client := embedetcd.FreeURL(t)
+	unavailable, err := clientv3.New(clientv3.Config{Endpoints: []string{client.Host}})
+	assert.Nil(t, err)
+	d, err := NewDiscovery(unavailable, "svc", WithSnapshotDir(dir), WithProtectThreshold(0.3),
+		WithRefreshDuration(3600), WithCmdTimeOut(time.Millisecond*200))
+	assert.Nil(t, err)
+	defer d.Close()
+	ed := d.(*EtcdDiscovery)
+	assert.True(t, ed.Stale())
+	assert.Equal(t, 4, len(d.GetNodes()))
+
+	// the cluster shrank while the process was down, the first load is accepted
+	cli := embedetcd.NewClient(t, embedetcd.NewServerAt(t, client))
+	val, err := JSONEncode(&registry.Node{Host: "127.0.0.5", Port: 80})
+	assert.Nil(t, err)
+	_, err = cli.Put(context.Background(), InstanceKey("", "", "svc", "127.0.0.5:80"), val)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return ed.setNodes() == nil
+	}, time.Second*10, time.Millisecond*100)
+	assert.False(t, ed.Stale())
+	nodes := d.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "127.0.0.5:80", nodes[0].Address())
+}
@@ -0,0 +1,60 @@
+package etcd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// The key layout is <namespace>/<version>/<env>/<service>/<instance-id>, such as
+// /air/registry/v1/prod/user/127.0.0.1:80, so that the prefix of one service never matches another.
+// The legacy layout is <service>.<host>.<port>, which is still read by discovery during migration.
+const (
+	DefaultNamespace = "/air/registry"
+	DefaultEnv       = "default"
+	KeyVersion       = "v1"
+)
+
+// ServicePrefix is the prefix of all instance keys of service, ends with "/"
+func ServicePrefix(namespace, env, serviceName string) string {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if env == "" {
+		env = DefaultEnv
+	}
+	return fmt.Sprintf("%s/%s/%s/%s/", strings.TrimSuffix(namespace, "/"), KeyVersion, env, serviceName)
+}
+
+// InstanceKey is the key of one instance
+func InstanceKey(namespace, env, serviceName, instanceID string) string {
+	return ServicePrefix(namespace, env, serviceName) + instanceID
+}
+
+// InstanceID is the default instance id, which is the address of node
+func InstanceID(host string, port int) string {
+	return servicer.GenerateAddress(host, port)
+}
+
+// LegacyKey is the key of legacy layout
+func LegacyKey(serviceName, host string, port int) string {
+	return fmt.Sprintf("%s.%s.%d", serviceName, host, port)
+}
+
+// legacyPrefix also matches other services with the same prefix, such as user.v2 for user,
+// so every key should be checked by isLegacyKey.
+func legacyPrefix(serviceName string) string {
+	return serviceName + "."
+}
+
+func isLegacyKey(key, serviceName string, node *registry.Node) bool {
+	return key == LegacyKey(serviceName, node.Host, node.Port)
+}
+
+// isInstanceKey check the key is exactly one level under prefix
+func isInstanceKey(key, prefix string) bool {
+	id := strings.TrimPrefix(key, prefix)
+	return len(id) < len(key) && id != "" && !strings.Contains(id, "/")
+}
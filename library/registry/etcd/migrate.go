@@ -0,0 +1,97 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/air-go/rpc/library/registry"
+)
+
+type MigrateOption struct {
+	namespace string
+	env       string
+	decode    registry.Decode
+	encode    registry.Encode
+}
+
+type MigrateOptionFunc func(*MigrateOption)
+
+// WithMigrateNamespace set the root of target keys, default is DefaultNamespace
+func WithMigrateNamespace(namespace string) MigrateOptionFunc {
+	return func(o *MigrateOption) { o.namespace = namespace }
+}
+
+// WithMigrateEnv set the env of target keys, default is DefaultEnv
+func WithMigrateEnv(env string) MigrateOptionFunc {
+	return func(o *MigrateOption) { o.env = env }
+}
+
+func WithMigrateDecode(decode registry.Decode) MigrateOptionFunc {
+	return func(o *MigrateOption) { o.decode = decode }
+}
+
+func WithMigrateEncode(encode registry.Encode) MigrateOptionFunc {
+	return func(o *MigrateOption) { o.encode = encode }
+}
+
+// Migrate copy the legacy keys of service to the current layout with the current schema.
+// The copied key is bound to the same lease, so it expires with the legacy one,
+// and the key already existing in the current layout is not overwritten.
+// The legacy keys are kept for discoveries which have not been upgraded.
+func Migrate(ctx context.Context, cli *clientv3.Client, name string, opts ...MigrateOptionFunc) (copied int, err error) {
+	if cli == nil {
+		return 0, errors.New("cli is nil")
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return 0, errors.New("serviceName is nil")
+	}
+
+	opt := &MigrateOption{
+		decode: JSONDecode,
+		encode: JSONEncode,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	resp, err := cli.Get(ctx, legacyPrefix(name), clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, kv := range resp.Kvs {
+		node, err := opt.decode(string(kv.Value))
+		if err != nil || !isLegacyKey(string(kv.Key), name, node) {
+			continue
+		}
+
+		val, err := opt.encode(node)
+		if err != nil {
+			return copied, err
+		}
+
+		key := InstanceKey(opt.namespace, opt.env, name, InstanceID(node.Host, node.Port))
+		putOpts := []clientv3.OpOption{}
+		if kv.Lease != 0 {
+			putOpts = append(putOpts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+		}
+
+		txnResp, err := cli.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, val, putOpts...)).
+			Commit()
+		if err != nil {
+			return copied, fmt.Errorf("copy %s to %s: %w", string(kv.Key), key, err)
+		}
+		if txnResp.Succeeded {
+			copied++
+		}
+	}
+
+	return copied, nil
+}
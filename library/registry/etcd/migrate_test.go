@@ -0,0 +1,66 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/mock/tools/embedetcd"
+)
+
+func TestMigrate(t *testing.T) {
+	cli := embedetcd.Start(t)
+	ctx := context.Background()
+
+	lease, err := cli.Grant(ctx, 60)
+	assert.Nil(t, err)
+	_, err = cli.Put(ctx, LegacyKey("user", "127.0.0.1", 80), `{"Host":"127.0.0.1","Port":80,"Weight":10}`, clientv3.WithLease(lease.ID))
+	assert.Nil(t, err)
+	_, err = cli.Put(ctx, LegacyKey("user", "127.0.0.2", 80), `{"Host":"127.0.0.2","Port":80}`)
+	assert.Nil(t, err)
+	_, err = cli.Put(ctx, LegacyKey("user.v2", "127.0.0.3", 80), `{"Host":"127.0.0.3","Port":80}`)
+	assert.Nil(t, err)
+	_, err = cli.Put(ctx, "user.invalid", "invalid")
+	assert.Nil(t, err)
+
+	// existing key is not overwritten
+	current, err := JSONEncode(&registry.Node{Host: "127.0.0.2", Port: 80, Weight: 5})
+	assert.Nil(t, err)
+	_, err = cli.Put(ctx, InstanceKey("", "prod", "user", "127.0.0.2:80"), current)
+	assert.Nil(t, err)
+
+	copied, err := Migrate(ctx, cli, "user", WithMigrateEnv("prod"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, copied)
+
+	resp, err := cli.Get(ctx, ServicePrefix("", "prod", "user"), clientv3.WithPrefix())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		node, err := JSONDecode(string(kv.Value))
+		assert.Nil(t, err)
+		switch node.Host {
+		case "127.0.0.1":
+			assert.Equal(t, 10, node.Weight)
+			assert.Equal(t, int64(lease.ID), kv.Lease)
+			assert.Contains(t, string(kv.Value), `"Schema":1`)
+		case "127.0.0.2":
+			assert.Equal(t, 5, node.Weight)
+		}
+	}
+
+	// migrate again is idempotent
+	copied, err = Migrate(ctx, cli, "user", WithMigrateEnv("prod"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, copied)
+
+	// the copied key expires with the legacy one
+	_, err = cli.Revoke(ctx, lease.ID)
+	assert.Nil(t, err)
+	resp, err = cli.Get(ctx, InstanceKey("", "prod", "user", "127.0.0.1:80"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(resp.Kvs))
+}
@@ -4,8 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/air-go/rpc/library/registry"
 
@@ -13,34 +14,72 @@ import (
 )
 
 type RegistrarOption struct {
-	lease  int64
-	encode registry.Encode
+	namespace  string
+	env        string
+	instanceID string
+	legacy     bool
+	lease      int64
+	encode     registry.Encode
+	weight     int
+	priority   int
+	zone       string
+	meta       map[string]string
+	notify     func(registry.Event)
+	minBackoff time.Duration
+	maxBackoff time.Duration
 }
 
 type RegistrarOptionFunc func(*RegistrarOption)
 
 func defaultRegistrarOption() *RegistrarOption {
 	return &RegistrarOption{
-		lease:  5,
-		encode: JSONEncode,
+		legacy:     true,
+		lease:      5,
+		encode:     JSONEncode,
+		minBackoff: time.Second,
+		maxBackoff: time.Second * 30,
 	}
 }
 
 // EtcdRegistrar
 type EtcdRegistrar struct {
-	opts          *RegistrarOption
-	cli           *clientv3.Client
-	serviceName   string
-	host          string
-	port          int
-	leaseID       clientv3.LeaseID
-	keepAliveChan <-chan *clientv3.LeaseKeepAliveResponse
-	key           string
-	val           string
+	opts        *RegistrarOption
+	cli         *clientv3.Client
+	serviceName string
+	host        string
+	port        int
+	lock        sync.Mutex
+	leaseID     clientv3.LeaseID
+	states      *registry.States
+	key         string
+	legacyKey   string
+	val         string
 }
 
 var _ registry.Registrar = (*EtcdRegistrar)(nil)
 
+// WithRegistrarNamespace set the root of keys, default is DefaultNamespace
+func WithRegistrarNamespace(namespace string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.namespace = namespace }
+}
+
+// WithRegistrarEnv set the env of keys, such as prod and test, default is DefaultEnv
+func WithRegistrarEnv(env string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.env = env }
+}
+
+// WithRegistrarInstanceID set the last part of key, default is host:port
+func WithRegistrarInstanceID(id string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.instanceID = id }
+}
+
+// WithRegistrarLegacy set whether to write the legacy key too, default is true during migration,
+// the legacy key is bound to the same lease, so it is kept alive and revoked with the current key.
+// It can be turned off after all the discoveries have been upgraded to read the current layout.
+func WithRegistrarLegacy(legacy bool) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.legacy = legacy }
+}
+
 func WithRegistrarLease(lease int64) RegistrarOptionFunc {
 	return func(o *RegistrarOption) { o.lease = lease }
 }
@@ -49,6 +88,35 @@ func WithRegistrarEncode(encode registry.Encode) RegistrarOptionFunc {
 	return func(o *RegistrarOption) { o.encode = encode }
 }
 
+func WithRegistrarWeight(weight int) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.weight = weight }
+}
+
+func WithRegistrarPriority(priority int) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.priority = priority }
+}
+
+func WithRegistrarZone(zone string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.zone = zone }
+}
+
+func WithRegistrarMeta(meta map[string]string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.meta = meta }
+}
+
+// WithRegistrarNotify set the func called on every registration state change, it should not block
+func WithRegistrarNotify(f func(registry.Event)) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.notify = f }
+}
+
+// WithRegistrarBackoff set the exponential backoff of re-registering after lease lost
+func WithRegistrarBackoff(min, max time.Duration) RegistrarOptionFunc {
+	return func(o *RegistrarOption) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
 // NewRegistry
 func NewRegistry(cli *clientv3.Client, name, host string, port int, opts ...RegistrarOptionFunc) (*EtcdRegistrar, error) {
 	var err error
@@ -65,6 +133,12 @@ func NewRegistry(cli *clientv3.Client, name, host string, port int, opts ...Regi
 	for _, o := range opts {
 		o(opt)
 	}
+	if opt.minBackoff <= 0 {
+		opt.minBackoff = time.Second
+	}
+	if opt.maxBackoff < opt.minBackoff {
+		opt.maxBackoff = opt.minBackoff
+	}
 
 	r := &EtcdRegistrar{
 		opts:        opt,
@@ -72,13 +146,28 @@ func NewRegistry(cli *clientv3.Client, name, host string, port int, opts ...Regi
 		serviceName: name,
 		host:        host,
 		port:        port,
+		states:      registry.NewStates(opt.notify),
 	}
 
-	r.key = fmt.Sprintf("%s.%s.%d", r.serviceName, r.host, r.port)
+	if r.opts.instanceID == "" {
+		r.opts.instanceID = InstanceID(r.host, r.port)
+	}
+	if strings.Contains(r.opts.instanceID, "/") {
+		return nil, errors.New("instanceID contains /")
+	}
+	r.key = InstanceKey(r.opts.namespace, r.opts.env, r.serviceName, r.opts.instanceID)
+	if r.opts.legacy {
+		r.legacyKey = LegacyKey(r.serviceName, r.host, r.port)
+	}
 
 	if r.val, err = r.opts.encode(&registry.Node{
-		Host: r.host,
-		Port: r.port,
+		Host:      r.host,
+		Port:      r.port,
+		Weight:    r.opts.weight,
+		Priority:  r.opts.priority,
+		Zone:      r.opts.zone,
+		Meta:      r.opts.meta,
+		StartTime: registry.StartTime().Unix(),
 	}); err != nil {
 		return nil, err
 	}
@@ -86,63 +175,138 @@ func NewRegistry(cli *clientv3.Client, name, host string, port int, opts ...Regi
 	return r, nil
 }
 
+// Register put the key with lease and keep alive,
+// the key is put again with a new lease if the keep alive stopped, until DeRegister.
 func (s *EtcdRegistrar) Register(ctx context.Context) error {
 	if s.cli == nil {
 		return errors.New("cli is nil")
 	}
 
 	// 申请租约设置时间keepalive
-	if err := s.putKeyWithRegistrarLease(ctx, s.opts.lease); err != nil {
-		return err
+	var keepAliveChan <-chan *clientv3.LeaseKeepAliveResponse
+	register := func(keepAliveCtx context.Context) error {
+		leaseID, ch, err := s.putKeyWithRegistrarLease(ctx, keepAliveCtx)
+		if err != nil {
+			return err
+		}
+		s.lock.Lock()
+		s.leaseID = leaseID
+		s.lock.Unlock()
+		keepAliveChan = ch
+		return nil
 	}
 
 	// 监听续租相应chan
-	go s.listenLeaseRespChan()
+	return s.states.Register(register, func(keepAliveCtx context.Context) {
+		s.listenLeaseRespChan(keepAliveCtx, keepAliveChan)
+	})
+}
 
-	return nil
+// State return the current registration state
+func (s *EtcdRegistrar) State() registry.State {
+	return s.states.State()
 }
 
 // putKeyWithRegistrarLease
-func (s *EtcdRegistrar) putKeyWithRegistrarLease(ctx context.Context, lease int64) error {
+func (s *EtcdRegistrar) putKeyWithRegistrarLease(ctx, keepAliveCtx context.Context) (clientv3.LeaseID, <-chan *clientv3.LeaseKeepAliveResponse, error) {
 	// 设置租约时间
-	resp, err := s.cli.Grant(ctx, lease)
+	resp, err := s.cli.Grant(ctx, s.opts.lease)
 	if err != nil {
-		return err
+		return 0, nil, err
+	}
+	// 注册服务并绑定租约, the legacy key is put in the same txn
+	ops := []clientv3.Op{clientv3.OpPut(s.key, s.val, clientv3.WithLease(resp.ID))}
+	if s.legacyKey != "" {
+		ops = append(ops, clientv3.OpPut(s.legacyKey, s.val, clientv3.WithLease(resp.ID)))
 	}
-	// 注册服务并绑定租约
-	_, err = s.cli.Put(ctx, s.key, s.val, clientv3.WithLease(resp.ID))
+	_, err = s.cli.Txn(ctx).Then(ops...).Commit()
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 	// 设置续租 定期发送需求请求
-	leaseRespChan, err := s.cli.KeepAlive(context.Background(), resp.ID)
+	leaseRespChan, err := s.cli.KeepAlive(keepAliveCtx, resp.ID)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
-	s.leaseID = resp.ID
-	s.keepAliveChan = leaseRespChan
-	return nil
+	return resp.ID, leaseRespChan, nil
 }
 
-// listenLeaseRespChan
-func (s *EtcdRegistrar) listenLeaseRespChan() {
-	for leaseKeepResp := range s.keepAliveChan {
-		_ = leaseKeepResp
-		// log.Println("续租：", leaseKeepResp)
+// listenLeaseRespChan drain the keep alive responses, re-register with backoff when the chan closed.
+func (s *EtcdRegistrar) listenLeaseRespChan(ctx context.Context, keepAliveChan <-chan *clientv3.LeaseKeepAliveResponse) {
+	for {
+		for range keepAliveChan {
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.states.Set(registry.StateLost, errors.New("keep alive channel closed"))
+
+		var ok bool
+		if keepAliveChan, ok = s.reRegister(ctx); !ok {
+			return
+		}
 	}
 }
 
-// Close
+// reRegister retry until success or ctx done
+func (s *EtcdRegistrar) reRegister(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, bool) {
+	backoff := s.opts.minBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(backoff):
+		}
+
+		putCtx, cancel := context.WithTimeout(ctx, time.Duration(s.opts.lease)*time.Second)
+		leaseID, keepAliveChan, err := s.putKeyWithRegistrarLease(putCtx, ctx)
+		cancel()
+
+		if err == nil {
+			s.lock.Lock()
+			s.leaseID = leaseID
+			s.lock.Unlock()
+			s.states.Set(registry.StateRegistered, nil)
+			return keepAliveChan, true
+		}
+		s.states.Set(registry.StateLost, err)
+
+		if backoff = backoff * 2; backoff > s.opts.maxBackoff {
+			backoff = s.opts.maxBackoff
+		}
+	}
+}
+
+// DeRegister stop the keep alive, then revoke the lease and close the client
 func (s *EtcdRegistrar) DeRegister(ctx context.Context) error {
-	// 撤销租约
-	if _, err := s.cli.Revoke(ctx, s.leaseID); err != nil {
+	// 停止续租, then 撤销租约, both the current and legacy keys are deleted with it
+	err := s.states.DeRegister(func() error {
+		s.lock.Lock()
+		leaseID := s.leaseID
+		s.lock.Unlock()
+
+		_, err := s.cli.Revoke(ctx, leaseID)
+		return err
+	})
+	if err != nil {
 		return err
 	}
 	return s.cli.Close()
 }
 
+// SchemaVersion is the version of value encoded by JSONEncode, the legacy value without Schema is version 0
+const SchemaVersion = 1
+
+// value is the versioned schema of node value, the fields of node are inlined for compatibility
+type value struct {
+	Schema int
+	*registry.Node
+}
+
 func JSONEncode(node *registry.Node) (string, error) {
-	val, err := json.Marshal(node)
+	val, err := json.Marshal(&value{Schema: SchemaVersion, Node: node})
 	if err != nil {
 		return "", errors.New("marshal node " + err.Error())
 	}
@@ -0,0 +1,178 @@
+package etcd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/mock/tools/embedetcd"
+)
+
+type recordEvents struct {
+	lock   sync.Mutex
+	events []registry.Event
+}
+
+func (r *recordEvents) notify(e registry.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recordEvents) states() []registry.State {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	res := make([]registry.State, 0, len(r.events))
+	for _, e := range r.events {
+		res = append(res, e.State)
+	}
+	return res
+}
+
+func TestEtcdRegistrar(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	events := &recordEvents{}
+	r, err := NewRegistry(embedetcd.NewClient(t, endpoint), "svc", "127.0.0.1", 80,
+		WithRegistrarWeight(10),
+		WithRegistrarMeta(map[string]string{"lane": "blue"}),
+		WithRegistrarNotify(events.notify),
+		WithRegistrarBackoff(time.Millisecond*10, time.Millisecond*50),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, registry.StateUnregistered, r.State())
+
+	assert.Nil(t, r.Register(ctx))
+	assert.NotNil(t, r.Register(ctx))
+	assert.Equal(t, registry.StateRegistered, r.State())
+
+	resp, err := cli.Get(ctx, "/air/registry/v1/default/svc/127.0.0.1:80")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(resp.Kvs))
+	node, err := JSONDecode(string(resp.Kvs[0].Value))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, node.Weight)
+	assert.Equal(t, "blue", node.Meta["lane"])
+	assert.Equal(t, registry.StartTime().Unix(), node.StartTime)
+
+	// the legacy key is written with the same lease for the discoveries not upgraded
+	legacy, err := cli.Get(ctx, "svc.127.0.0.1.80")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(legacy.Kvs))
+	assert.Equal(t, resp.Kvs[0].Value, legacy.Kvs[0].Value)
+	assert.Equal(t, resp.Kvs[0].Lease, legacy.Kvs[0].Lease)
+
+	// lease lost, register again with a new lease
+	leaseID := r.leaseID
+	_, err = cli.Revoke(ctx, leaseID)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		resp, err := cli.Get(ctx, "/air/registry/v1/default/svc/127.0.0.1:80")
+		return err == nil && len(resp.Kvs) == 1 && resp.Kvs[0].Lease != int64(leaseID)
+	}, time.Second*5, time.Millisecond*10)
+	assert.Eventually(t, func() bool {
+		return r.State() == registry.StateRegistered
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, []registry.State{registry.StateRegistered, registry.StateLost, registry.StateRegistered}, events.states())
+
+	assert.Nil(t, r.DeRegister(ctx))
+	assert.Equal(t, registry.StateDeregistered, r.State())
+	resp, err = cli.Get(ctx, "/air/registry/v1/default/svc/127.0.0.1:80")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(resp.Kvs))
+	resp, err = cli.Get(ctx, "svc.127.0.0.1.80")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(resp.Kvs))
+}
+
+func TestEtcdRegistrar_NoLegacy(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	r, err := NewRegistry(embedetcd.NewClient(t, endpoint), "svc", "127.0.0.1", 80, WithRegistrarLegacy(false))
+	assert.Nil(t, err)
+	assert.Nil(t, r.Register(ctx))
+	defer r.DeRegister(ctx)
+
+	resp, err := cli.Get(ctx, "/air/registry/v1/default/svc/127.0.0.1:80")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(resp.Kvs))
+	resp, err = cli.Get(ctx, "svc.127.0.0.1.80")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(resp.Kvs))
+}
+
+func TestEtcdRegistrar_DeRegister(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	events := &recordEvents{}
+	r, err := NewRegistry(embedetcd.NewClient(t, endpoint), "svc", "127.0.0.1", 80,
+		WithRegistrarLease(1),
+		WithRegistrarNotify(events.notify),
+		WithRegistrarBackoff(time.Millisecond*10, time.Millisecond*20),
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Register(ctx))
+
+	// DeRegister after re-registered
+	_, _ = cli.Revoke(ctx, r.leaseID)
+	assert.Eventually(t, func() bool {
+		return len(events.states()) >= 3
+	}, time.Second*5, time.Millisecond*10)
+	assert.Nil(t, r.DeRegister(ctx))
+
+	// keep alive is stopped, state is not changed any more
+	count := len(events.states())
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, count, len(events.states()))
+	assert.Equal(t, registry.StateDeregistered, r.State())
+}
+
+func TestEtcdRegistrar_NotifyState(t *testing.T) {
+	endpoint := embedetcd.NewServer(t)
+	cli := embedetcd.NewClient(t, endpoint)
+	ctx := context.Background()
+
+	// the notify func reading the state must not deadlock
+	var r *EtcdRegistrar
+	events, seen := &recordEvents{}, &recordEvents{}
+	r, err := NewRegistry(embedetcd.NewClient(t, endpoint), "svc", "127.0.0.1", 80,
+		WithRegistrarNotify(func(e registry.Event) {
+			events.notify(e)
+			seen.notify(registry.Event{State: r.State()})
+		}),
+		WithRegistrarBackoff(time.Millisecond*10, time.Millisecond*20),
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Register(ctx))
+
+	r.lock.Lock()
+	leaseID := r.leaseID
+	r.lock.Unlock()
+	_, _ = cli.Revoke(ctx, leaseID)
+	assert.Eventually(t, func() bool {
+		return len(events.states()) >= 3
+	}, time.Second*5, time.Millisecond*10)
+	assert.Nil(t, r.DeRegister(ctx))
+
+	assert.Equal(t, events.states(), seen.states())
+	assert.Equal(t, registry.StateDeregistered, r.State())
+}
+
+func TestState_String(t *testing.T) {
+	assert.Equal(t, "unregistered", registry.StateUnregistered.String())
+	assert.Equal(t, "registered", registry.StateRegistered.String())
+	assert.Equal(t, "lost", registry.StateLost.String())
+	assert.Equal(t, "deregistered", registry.StateDeregistered.String())
+	assert.Equal(t, "unknown", registry.State(100).String())
+}
@@ -0,0 +1,222 @@
+// file is service discovery by a static node list file, which is reloaded when changed.
+// The file is parsed by its extension, such as yaml, toml and json:
+//
+//	[[Nodes]]
+//	Host = "127.0.0.1"
+//	Port = 8080
+//	Weight = 10
+package file
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/why444216978/go-util/nopanic"
+
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type content struct {
+	Nodes []*registry.Node
+}
+
+type options struct {
+	logger   logger.Logger
+	notify   func(nodes []*registry.Node)
+	debounce time.Duration
+}
+
+type Option func(*options)
+
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithDebounce set the quiet time after the last change before reloading,
+// so a file written in several steps is loaded once it is complete, default 100ms
+func WithDebounce(d time.Duration) Option {
+	return func(o *options) { o.debounce = d }
+}
+
+// WithNotify set the func called with the new node list after each successful load
+func WithNotify(f func(nodes []*registry.Node)) Option {
+	return func(o *options) { o.notify = f }
+}
+
+type FileDiscovery struct {
+	*options
+	setup.SetupLogger
+	path        string
+	lock        sync.RWMutex
+	nodes       []*registry.Node
+	updateTime  time.Time
+	watcher     *fsnotify.Watcher
+	subscribers *registry.Subscribers
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+var _ registry.Discovery = (*FileDiscovery)(nil)
+
+// NewDiscovery load the file and watch it, return error if the first load failed.
+func NewDiscovery(path string, opts ...Option) (*FileDiscovery, error) {
+	opt := &options{debounce: time.Millisecond * 100}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := &FileDiscovery{
+		options:     opt,
+		path:        path,
+		done:        make(chan struct{}),
+		subscribers: registry.NewSubscribers(),
+	}
+	fd.SetupLogger.SetLogger(opt.logger)
+
+	if err = fd.load(); err != nil {
+		return nil, err
+	}
+
+	// watch the dir, editors and config systems usually replace the file by rename
+	if fd.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return nil, err
+	}
+	if err = fd.watcher.Add(filepath.Dir(path)); err != nil {
+		_ = fd.watcher.Close()
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, fd.cancel = context.WithCancel(context.Background())
+	fd.watch(ctx)
+
+	return fd, nil
+}
+
+func (fd *FileDiscovery) GetNodes() []servicer.Node {
+	fd.lock.RLock()
+	defer fd.lock.RUnlock()
+
+	nodes := make([]servicer.Node, 0, len(fd.nodes))
+	for _, node := range fd.nodes {
+		nodes = append(nodes, servicer.NewNode(node.Host, node.Port,
+			servicer.WithWeight(node.Weight),
+			servicer.WithZone(node.Zone),
+			servicer.WithMeta(node.Meta)))
+	}
+	return nodes
+}
+
+func (fd *FileDiscovery) GetUpdateTime() time.Time {
+	fd.lock.RLock()
+	defer fd.lock.RUnlock()
+	return fd.updateTime
+}
+
+func (fd *FileDiscovery) Subscribe(f func(events []registry.NodeEvent)) (unsubscribe func()) {
+	return fd.subscribers.Subscribe(f)
+}
+
+// Close stop watching the file.
+func (fd *FileDiscovery) Close() error {
+	fd.cancel()
+	<-fd.done
+	return fd.watcher.Close()
+}
+
+func (fd *FileDiscovery) watch(ctx context.Context) {
+	go nopanic.GoVoid(ctx, func() {
+		defer close(fd.done)
+
+		// nil until a change, a burst of changes is reloaded once
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-fd.watcher.Errors:
+				if !ok {
+					return
+				}
+				fd.AutoLogger().Warn(ctx, "fileDiscoveryWatchErr",
+					logger.Reflect(logger.ServiceName, fd.path),
+					logger.Error(err),
+				)
+			case ev, ok := <-fd.watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != fd.path || ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				reload = time.After(fd.debounce)
+			case <-reload:
+				reload = nil
+				if err := fd.load(); err != nil {
+					fd.AutoLogger().Warn(ctx, "fileDiscoveryLoadErr",
+						logger.Reflect(logger.ServiceName, fd.path),
+						logger.Error(err),
+					)
+				}
+			}
+		}
+	})
+}
+
+// load parse and validate the whole file, the node list is kept if any error,
+// an empty list is an error too, which is usually a truncated file being written.
+func (fd *FileDiscovery) load() error {
+	v := viper.New()
+	v.SetConfigFile(fd.path)
+	if err := v.ReadInConfig(); err != nil {
+		return errors.Wrapf(err, "read %s", fd.path)
+	}
+
+	c := &content{}
+	if err := v.Unmarshal(c); err != nil {
+		return errors.Wrapf(err, "unmarshal %s", fd.path)
+	}
+
+	if len(c.Nodes) == 0 {
+		return errors.Errorf("no nodes in %s", fd.path)
+	}
+
+	seen := make(map[string]struct{}, len(c.Nodes))
+	for idx, node := range c.Nodes {
+		if node == nil || net.ParseIP(node.Host) == nil || node.Port <= 0 || node.Port > 65535 {
+			return errors.Errorf("invalid node %d in %s", idx, fd.path)
+		}
+		address := servicer.GenerateAddress(node.Host, node.Port)
+		if _, ok := seen[address]; ok {
+			return errors.Errorf("repeat node %s in %s", address, fd.path)
+		}
+		seen[address] = struct{}{}
+	}
+
+	fd.lock.Lock()
+	fd.nodes = c.Nodes
+	fd.updateTime = time.Now()
+	fd.lock.Unlock()
+
+	fd.subscribers.Update(fd.GetNodes())
+
+	if fd.notify != nil {
+		fd.notify(c.Nodes)
+	}
+
+	return nil
+}
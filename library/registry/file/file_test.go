@@ -0,0 +1,140 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+)
+
+func addresses(fd *FileDiscovery) []string {
+	res := []string{}
+	for _, n := range fd.GetNodes() {
+		res = append(res, n.Address())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestFileDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nodes.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(`
+[[Nodes]]
+Host = "127.0.0.1"
+Port = 80
+Weight = 10
+Zone = "bj"
+[Nodes.Meta]
+lane = "blue"
+
+[[Nodes]]
+Host = "127.0.0.2"
+Port = 80
+`), 0o644))
+
+	notified := make(chan []*registry.Node, 10)
+	fd, err := NewDiscovery(path, WithNotify(func(nodes []*registry.Node) { notified <- nodes }))
+	assert.Nil(t, err)
+	defer fd.Close()
+
+	assert.Equal(t, 2, len(<-notified))
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80"}, addresses(fd))
+	for _, n := range fd.GetNodes() {
+		if n.Address() != "127.0.0.1:80" {
+			continue
+		}
+		assert.Equal(t, 10, n.Weight())
+		assert.Equal(t, "bj", n.Zone())
+		assert.Equal(t, "blue", n.Meta()["lane"])
+	}
+	updateTime := fd.GetUpdateTime()
+
+	// malformed update is rejected
+	assert.Nil(t, os.WriteFile(path, []byte(`[[Nodes]]
+Host = "invalid"
+Port = 80
+`), 0o644))
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80"}, addresses(fd))
+	assert.Equal(t, updateTime, fd.GetUpdateTime())
+
+	// replace by rename
+	tmp := filepath.Join(dir, "nodes.tmp")
+	assert.Nil(t, os.WriteFile(tmp, []byte(`[[Nodes]]
+Host = "127.0.0.3"
+Port = 81
+`), 0o644))
+	assert.Nil(t, os.Rename(tmp, path))
+	assert.Eventually(t, func() bool {
+		a := addresses(fd)
+		return len(a) == 1 && a[0] == "127.0.0.3:81"
+	}, time.Second*3, time.Millisecond*10)
+	assert.True(t, fd.GetUpdateTime().After(updateTime))
+}
+
+func TestFileDiscovery_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewDiscovery(filepath.Join(dir, "notfound.json"))
+	assert.NotNil(t, err)
+
+	cases := map[string]string{
+		"syntax.json": `{"Nodes": [`,
+		"port.json":   `{"Nodes": [{"Host": "127.0.0.1", "Port": 0}]}`,
+		"repeat.yaml": "nodes:\n  - host: 127.0.0.1\n    port: 80\n  - host: 127.0.0.1\n    port: 80\n",
+		"empty.toml":  "",
+		"none.json":   `{"Nodes": []}`,
+	}
+	for name, c := range cases {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(c), 0o644))
+		_, err = NewDiscovery(path)
+		assert.NotNil(t, err, name)
+	}
+
+	// yaml with lower case keys
+	path := filepath.Join(dir, "nodes.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("nodes:\n  - host: 127.0.0.1\n    port: 80\n    weight: 3\n"), 0o644))
+	fd, err := NewDiscovery(path)
+	assert.Nil(t, err)
+	defer fd.Close()
+	assert.Equal(t, 3, fd.GetNodes()[0].Weight())
+}
+
+func TestFileDiscovery_Truncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.toml")
+	first := "[[Nodes]]\nHost = \"127.0.0.1\"\nPort = 80\n"
+	second := "[[Nodes]]\nHost = \"127.0.0.2\"\nPort = 80\n"
+	assert.Nil(t, os.WriteFile(path, []byte(first+second), 0o644))
+
+	notified := make(chan []*registry.Node, 10)
+	fd, err := NewDiscovery(path, WithNotify(func(nodes []*registry.Node) { notified <- nodes }))
+	assert.Nil(t, err)
+	defer fd.Close()
+	assert.Equal(t, 2, len(<-notified))
+
+	// truncated, the last good list is kept
+	assert.Nil(t, os.Truncate(path, 0))
+	time.Sleep(time.Millisecond * 300)
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80"}, addresses(fd))
+	assert.Equal(t, 0, len(notified))
+
+	// rewritten in steps, only the complete file is loaded
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o644)
+	assert.Nil(t, err)
+	_, _ = f.WriteString(first)
+	_, _ = f.WriteString(second)
+	_, _ = f.WriteString("[[Nodes]]\nHost = \"127.0.0.3\"\nPort = 80\n")
+	assert.Nil(t, f.Close())
+
+	assert.Equal(t, 3, len(<-notified))
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80", "127.0.0.3:80"}, addresses(fd))
+	time.Sleep(time.Millisecond * 300)
+	assert.Equal(t, 0, len(notified))
+}
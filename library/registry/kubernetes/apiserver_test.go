@@ -0,0 +1,164 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testToken = "test-token"
+
+type loggedEvent struct {
+	rv    int
+	event *WatchEvent
+}
+
+// fakeAPIServer is the stand-in of api server, only the EndpointSlices list and watch are implemented.
+type fakeAPIServer struct {
+	*httptest.Server
+	lock      sync.Mutex
+	changed   chan struct{}
+	rv        int
+	compacted int // watch from the version before it is expired
+	slices    map[string]*EndpointSlice
+	events    []loggedEvent
+	lists     int
+}
+
+func newFakeAPIServer(t *testing.T) *fakeAPIServer {
+	s := &fakeAPIServer{
+		changed: make(chan struct{}),
+		rv:      1,
+		slices:  make(map[string]*EndpointSlice),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeAPIServer) config() *Config {
+	return &Config{
+		Host:        s.URL,
+		BearerToken: testToken,
+		CAData:      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}),
+		Namespace:   "default",
+	}
+}
+
+func (s *fakeAPIServer) listCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lists
+}
+
+// apply put or delete the slice and log the event
+func (s *fakeAPIServer) apply(typ string, slice *EndpointSlice) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rv++
+	slice.Metadata.ResourceVersion = strconv.Itoa(s.rv)
+	if typ == EventDeleted {
+		delete(s.slices, slice.Metadata.Name)
+	} else {
+		s.slices[slice.Metadata.Name] = slice
+	}
+
+	b, _ := json.Marshal(slice)
+	s.events = append(s.events, loggedEvent{rv: s.rv, event: &WatchEvent{Type: typ, Object: b}})
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// compact make the versions up to now gone, the watching streams are expired too
+func (s *fakeAPIServer) compact() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rv++
+	s.compacted = s.rv
+	s.events = nil
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *fakeAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(&Status{Code: http.StatusUnauthorized, Reason: "Unauthorized"})
+		return
+	}
+	if r.URL.Path != endpointSlicesPath("default") {
+		http.NotFound(w, r)
+		return
+	}
+
+	service := strings.TrimPrefix(r.URL.Query().Get("labelSelector"), serviceNameLabel+"=")
+	if r.URL.Query().Get("watch") == "1" {
+		s.watch(w, r, service)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lists++
+
+	list := &EndpointSliceList{Metadata: ListMeta{ResourceVersion: strconv.Itoa(s.rv)}}
+	names := make([]string, 0, len(s.slices))
+	for name := range s.slices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if slice := s.slices[name]; slice.Metadata.Labels[serviceNameLabel] == service {
+			list.Items = append(list.Items, *slice)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (s *fakeAPIServer) watch(w http.ResponseWriter, r *http.Request, service string) {
+	rv, _ := strconv.Atoi(r.URL.Query().Get("resourceVersion"))
+	timeout, _ := strconv.Atoi(r.URL.Query().Get("timeoutSeconds"))
+	deadline := time.After(time.Duration(timeout) * time.Second)
+
+	encoder := json.NewEncoder(w)
+	for {
+		s.lock.Lock()
+		if rv < s.compacted {
+			s.lock.Unlock()
+			b, _ := json.Marshal(&Status{Code: http.StatusGone, Reason: "Expired", Message: "too old resource version"})
+			_ = encoder.Encode(&WatchEvent{Type: EventError, Object: b})
+			return
+		}
+		for _, e := range s.events {
+			if e.rv <= rv {
+				continue
+			}
+			rv = e.rv
+			slice := &EndpointSlice{}
+			_ = json.Unmarshal(e.event.Object, slice)
+			if slice.Metadata.Labels[serviceNameLabel] != service {
+				continue
+			}
+			_ = encoder.Encode(e.event)
+		}
+		changed := s.changed
+		s.lock.Unlock()
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
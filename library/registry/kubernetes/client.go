@@ -0,0 +1,223 @@
+// kubernetes is service discovery by the EndpointSlices of Service, which are listed and watched from api server.
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// serviceNameLabel is set on EndpointSlices by the controller of Service
+const serviceNameLabel = "kubernetes.io/service-name"
+
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type EndpointConditions struct {
+	Ready       *bool `json:"ready,omitempty"`
+	Serving     *bool `json:"serving,omitempty"`
+	Terminating *bool `json:"terminating,omitempty"`
+}
+
+type ObjectReference struct {
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type Endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions EndpointConditions `json:"conditions"`
+	NodeName   *string            `json:"nodeName,omitempty"`
+	Zone       *string            `json:"zone,omitempty"`
+	TargetRef  *ObjectReference   `json:"targetRef,omitempty"`
+}
+
+type EndpointPort struct {
+	Name     *string `json:"name,omitempty"`
+	Port     *int32  `json:"port,omitempty"`
+	Protocol *string `json:"protocol,omitempty"`
+}
+
+type EndpointSlice struct {
+	Metadata    ObjectMeta     `json:"metadata"`
+	AddressType string         `json:"addressType"`
+	Endpoints   []Endpoint     `json:"endpoints"`
+	Ports       []EndpointPort `json:"ports"`
+}
+
+type EndpointSliceList struct {
+	Metadata ListMeta        `json:"metadata"`
+	Items    []EndpointSlice `json:"items"`
+}
+
+// Status is returned by api server on failure, such as the ERROR event of watch
+type Status struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("status %d %s: %s", s.Code, s.Reason, s.Message)
+}
+
+// IsGone report whether the resource version is too old to watch, which should be listed again
+func IsGone(err error) bool {
+	s := &Status{}
+	return errors.As(err, &s) && s.Code == http.StatusGone
+}
+
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+	EventBookmark = "BOOKMARK"
+	EventError    = "ERROR"
+)
+
+type WatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Client is the minimal client of api server used by discovery
+type Client struct {
+	cfg        *Config
+	httpClient *http.Client
+}
+
+func NewClient(cfg *Config) (*Client, error) {
+	if cfg == nil || cfg.Host == "" {
+		return nil, errors.New("kubernetes config host empty")
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if len(cfg.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return nil, errors.New("invalid kubernetes ca")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.CertData) > 0 {
+		cert, err := tls.X509KeyPair(cfg.CertData, cfg.KeyData)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid kubernetes client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Transport: transport},
+	}, nil
+}
+
+// ListEndpointSlices list the EndpointSlices of service
+func (c *Client) ListEndpointSlices(ctx context.Context, namespace, service string) (*EndpointSliceList, error) {
+	resp, err := c.get(ctx, endpointSlicesPath(namespace), url.Values{
+		"labelSelector": {serviceNameLabel + "=" + service},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	list := &EndpointSliceList{}
+	if err = json.NewDecoder(resp.Body).Decode(list); err != nil {
+		return nil, errors.Wrap(err, "decode endpoint slice list")
+	}
+	return list, nil
+}
+
+// WatchEndpointSlices watch the EndpointSlices of service after resourceVersion,
+// the events are sent to f until the stream ended by server or ctx done, or f returned error.
+func (c *Client) WatchEndpointSlices(ctx context.Context, namespace, service, resourceVersion string, timeoutSeconds int, f func(*WatchEvent) error) error {
+	resp, err := c.get(ctx, endpointSlicesPath(namespace), url.Values{
+		"labelSelector":       {serviceNameLabel + "=" + service},
+		"watch":               {"1"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {fmt.Sprint(timeoutSeconds)},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		ev := &WatchEvent{}
+		if err = decoder.Decode(ev); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "decode watch event")
+		}
+		if err = f(ev); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.cfg.Host, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token := c.cfg.BearerToken
+	if c.cfg.TokenFile != "" {
+		b, err := os.ReadFile(c.cfg.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	status := &Status{}
+	if err = json.Unmarshal(b, status); err != nil || status.Code == 0 {
+		status = &Status{Code: resp.StatusCode, Message: string(bytes.TrimSpace(b))}
+	}
+	return nil, status
+}
+
+func endpointSlicesPath(namespace string) string {
+	return "/apis/discovery.k8s.io/v1/namespaces/" + url.PathEscape(namespace) + "/endpointslices"
+}
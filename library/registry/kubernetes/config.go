@@ -0,0 +1,199 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	defaultNamespace  = "default"
+)
+
+// Config is how to access the api server, one of BearerToken, TokenFile and client certificate is used to auth.
+type Config struct {
+	Host        string // url of api server, such as https://10.0.0.1:443
+	BearerToken string
+	TokenFile   string // read on every request, so the rotated token of service account is used
+	CAData      []byte
+	CertData    []byte
+	KeyData     []byte
+	Insecure    bool
+	Namespace   string // the namespace of pod or kubeconfig context
+}
+
+// InClusterConfig use the service account mounted in the pod
+func InClusterConfig() (*Config, error) {
+	return inClusterConfig(serviceAccountDir)
+}
+
+func inClusterConfig(dir string) (*Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not in cluster, KUBERNETES_SERVICE_HOST or KUBERNETES_SERVICE_PORT empty")
+	}
+
+	tokenFile := filepath.Join(dir, "token")
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, err
+	}
+
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := defaultNamespace
+	if b, err := os.ReadFile(filepath.Join(dir, "namespace")); err == nil && len(strings.TrimSpace(string(b))) > 0 {
+		namespace = strings.TrimSpace(string(b))
+	}
+
+	return &Config{
+		Host:      "https://" + net.JoinHostPort(host, port),
+		TokenFile: tokenFile,
+		CAData:    ca,
+		Namespace: namespace,
+	}, nil
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// KubeconfigConfig load the kubeconfig file, context empty means current-context.
+// Only token and client certificate auth are supported, exec and auth-provider plugins are not.
+func KubeconfigConfig(path, context string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	kc := &kubeconfig{}
+	if err = yaml.Unmarshal(b, kc); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal %s", path)
+	}
+
+	if context == "" {
+		context = kc.CurrentContext
+	}
+
+	cfg := &Config{Namespace: defaultNamespace}
+	var clusterName, userName string
+	for _, c := range kc.Contexts {
+		if c.Name != context {
+			continue
+		}
+		clusterName, userName = c.Context.Cluster, c.Context.User
+		if c.Context.Namespace != "" {
+			cfg.Namespace = c.Context.Namespace
+		}
+	}
+	if clusterName == "" {
+		return nil, errors.Errorf("context %q not found in %s", context, path)
+	}
+
+	// relative file in kubeconfig is relative to the kubeconfig
+	dir := filepath.Dir(path)
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		cfg.Host = c.Cluster.Server
+		cfg.Insecure = c.Cluster.InsecureSkipTLSVerify
+		if cfg.CAData, err = fileOrData(dir, c.Cluster.CertificateAuthority, c.Cluster.CertificateAuthorityData); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Host == "" {
+		return nil, errors.Errorf("cluster %q not found in %s", clusterName, path)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cfg.BearerToken = u.User.Token
+		if u.User.TokenFile != "" {
+			cfg.TokenFile = resolvePath(dir, u.User.TokenFile)
+		}
+		if cfg.CertData, err = fileOrData(dir, u.User.ClientCertificate, u.User.ClientCertificateData); err != nil {
+			return nil, err
+		}
+		if cfg.KeyData, err = fileOrData(dir, u.User.ClientKey, u.User.ClientKeyData); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// DefaultConfig use in cluster config if in pod, otherwise $KUBECONFIG or ~/.kube/config
+func DefaultConfig() (*Config, error) {
+	if cfg, err := InClusterConfig(); err == nil {
+		return cfg, nil
+	}
+
+	path := os.Getenv("KUBECONFIG")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".kube", "config")
+	}
+	// only the first file of list is used
+	path = filepath.SplitList(path)[0]
+
+	return KubeconfigConfig(path, "")
+}
+
+func fileOrData(dir, file, data string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(resolvePath(dir, file))
+	}
+	return nil, nil
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,105 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInClusterConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	_, err := inClusterConfig(dir)
+	assert.NotNil(t, err)
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	_, err = inClusterConfig(dir)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "token"), []byte("token"), 0o600))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("ca"), 0o600))
+	cfg, err := inClusterConfig(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, &Config{
+		Host:      "https://10.0.0.1:443",
+		TokenFile: filepath.Join(dir, "token"),
+		CAData:    []byte("ca"),
+		Namespace: "default",
+	}, cfg)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "namespace"), []byte("prod\n"), 0o600))
+	cfg, err = inClusterConfig(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", cfg.Namespace)
+}
+
+func TestKubeconfigConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("ca"), 0o600))
+	assert.Nil(t, os.WriteFile(path, []byte(`
+apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev:6443
+    certificate-authority: ca.crt
+- name: prod-cluster
+  cluster:
+    server: https://prod:6443
+    insecure-skip-tls-verify: true
+users:
+- name: dev-user
+  user:
+    token: dev-token
+- name: prod-user
+  user:
+    client-certificate-data: `+base64.StdEncoding.EncodeToString([]byte("cert"))+`
+    client-key-data: `+base64.StdEncoding.EncodeToString([]byte("key"))+`
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+    namespace: user
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+`), 0o600))
+
+	cfg, err := KubeconfigConfig(path, "")
+	assert.Nil(t, err)
+	assert.Equal(t, &Config{
+		Host:        "https://dev:6443",
+		BearerToken: "dev-token",
+		CAData:      []byte("ca"),
+		Namespace:   "user",
+	}, cfg)
+
+	cfg, err = KubeconfigConfig(path, "prod")
+	assert.Nil(t, err)
+	assert.Equal(t, &Config{
+		Host:      "https://prod:6443",
+		CertData:  []byte("cert"),
+		KeyData:   []byte("key"),
+		Insecure:  true,
+		Namespace: "default",
+	}, cfg)
+
+	_, err = KubeconfigConfig(path, "test")
+	assert.NotNil(t, err)
+	_, err = KubeconfigConfig(filepath.Join(dir, "none"), "")
+	assert.NotNil(t, err)
+
+	_, err = NewClient(cfg)
+	assert.NotNil(t, err)
+	_, err = NewClient(&Config{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,318 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/why444216978/go-util/nopanic"
+
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// The metadata of endpoint carried in node meta, the zone is set to node zone too
+const (
+	MetaZone     = "zone"
+	MetaNodeName = "node_name"
+	MetaPod      = "pod"
+)
+
+type options struct {
+	logger         logger.Logger
+	portName       string
+	retryInterval  time.Duration
+	timeoutSeconds int
+}
+
+type Option func(*options)
+
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithPortName select the port by name if the Service has multiple ports, default is the first port
+func WithPortName(name string) Option {
+	return func(o *options) { o.portName = name }
+}
+
+// WithRetryInterval set the interval between relisting after failed, default is 1s
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) { o.retryInterval = d }
+}
+
+// WithWatchTimeout set the timeout of each watch request, default is 5m
+func WithWatchTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeoutSeconds = int(d.Seconds()) }
+}
+
+// KubernetesDiscovery list and watch the EndpointSlices of Service, only the ready endpoints are nodes
+type KubernetesDiscovery struct {
+	*options
+	setup.SetupLogger
+	cli             *Client
+	namespace       string
+	service         string
+	lock            sync.RWMutex
+	slices          map[string]*EndpointSlice // name to slice
+	nodes           []*registry.Node
+	updateTime      time.Time
+	resourceVersion string
+	subscribers     *registry.Subscribers
+	cancel          context.CancelFunc
+	done            chan struct{}
+}
+
+var _ registry.Discovery = (*KubernetesDiscovery)(nil)
+
+// NewDiscovery list the EndpointSlices once, return error if failed, then watch in the background.
+func NewDiscovery(cli *Client, namespace, service string, opts ...Option) (*KubernetesDiscovery, error) {
+	if cli == nil {
+		return nil, errors.New("cli is nil")
+	}
+
+	if service = strings.TrimSpace(service); service == "" {
+		return nil, errors.New("serviceName is nil")
+	}
+
+	if namespace == "" {
+		namespace = cli.cfg.Namespace
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	opt := &options{
+		retryInterval:  time.Second,
+		timeoutSeconds: 300,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	kd := &KubernetesDiscovery{
+		options:     opt,
+		cli:         cli,
+		namespace:   namespace,
+		service:     service,
+		slices:      make(map[string]*EndpointSlice),
+		subscribers: registry.NewSubscribers(),
+		done:        make(chan struct{}),
+	}
+	kd.SetupLogger.SetLogger(opt.logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := kd.list(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	kd.cancel = cancel
+	kd.loop(ctx)
+
+	return kd, nil
+}
+
+func (kd *KubernetesDiscovery) GetNodes() []servicer.Node {
+	kd.lock.RLock()
+	defer kd.lock.RUnlock()
+
+	nodes := make([]servicer.Node, 0, len(kd.nodes))
+	for _, node := range kd.nodes {
+		nodes = append(nodes, servicer.NewNode(node.Host, node.Port,
+			servicer.WithWeight(node.Weight),
+			servicer.WithZone(node.Zone),
+			servicer.WithMeta(node.Meta)))
+	}
+	return nodes
+}
+
+func (kd *KubernetesDiscovery) GetUpdateTime() time.Time {
+	kd.lock.RLock()
+	defer kd.lock.RUnlock()
+	return kd.updateTime
+}
+
+func (kd *KubernetesDiscovery) Subscribe(f func(events []registry.NodeEvent)) (unsubscribe func()) {
+	return kd.subscribers.Subscribe(f)
+}
+
+// Close stop watching and wait the loop exit.
+func (kd *KubernetesDiscovery) Close() error {
+	kd.cancel()
+	<-kd.done
+	return nil
+}
+
+// loop watch from the resource version of list, and relist if the version is gone or watch failed.
+func (kd *KubernetesDiscovery) loop(ctx context.Context) {
+	go nopanic.GoVoid(ctx, func() {
+		defer close(kd.done)
+
+		for {
+			err := kd.cli.WatchEndpointSlices(ctx, kd.namespace, kd.service, kd.resourceVersion, kd.timeoutSeconds, kd.handle)
+			if ctx.Err() != nil {
+				return
+			}
+			// the stream ended by timeout, watch again from the last version
+			if err == nil {
+				continue
+			}
+
+			kd.AutoLogger().Warn(ctx, "kubernetesDiscoveryWatchErr",
+				logger.Reflect(logger.ServiceName, kd.service),
+				logger.Reflect("namespace", kd.namespace),
+				logger.Error(err),
+			)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(kd.retryInterval):
+				}
+				if err = kd.list(ctx); err == nil {
+					break
+				}
+				kd.AutoLogger().Warn(ctx, "kubernetesDiscoveryListErr",
+					logger.Reflect(logger.ServiceName, kd.service),
+					logger.Reflect("namespace", kd.namespace),
+					logger.Error(err),
+				)
+			}
+		}
+	})
+}
+
+func (kd *KubernetesDiscovery) list(ctx context.Context) error {
+	list, err := kd.cli.ListEndpointSlices(ctx, kd.namespace, kd.service)
+	if err != nil {
+		return err
+	}
+
+	slices := make(map[string]*EndpointSlice, len(list.Items))
+	for idx := range list.Items {
+		slices[list.Items[idx].Metadata.Name] = &list.Items[idx]
+	}
+	kd.slices = slices
+	kd.resourceVersion = list.Metadata.ResourceVersion
+
+	kd.update()
+	return nil
+}
+
+// handle apply one watch event, the ERROR event is returned to relist
+func (kd *KubernetesDiscovery) handle(ev *WatchEvent) error {
+	if ev.Type == EventError {
+		status := &Status{}
+		if err := json.Unmarshal(ev.Object, status); err != nil {
+			return errors.Wrap(err, "decode watch error")
+		}
+		return status
+	}
+
+	slice := &EndpointSlice{}
+	if err := json.Unmarshal(ev.Object, slice); err != nil {
+		return errors.Wrap(err, "decode endpoint slice")
+	}
+	kd.resourceVersion = slice.Metadata.ResourceVersion
+
+	switch ev.Type {
+	case EventAdded, EventModified:
+		kd.slices[slice.Metadata.Name] = slice
+	case EventDeleted:
+		delete(kd.slices, slice.Metadata.Name)
+	default:
+		return nil
+	}
+
+	kd.update()
+	return nil
+}
+
+// update merge the ready endpoints of all slices, the endpoint in multiple slices while moving is kept once.
+func (kd *KubernetesDiscovery) update() {
+	names := make([]string, 0, len(kd.slices))
+	for name := range kd.slices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]struct{})
+	nodes := make([]*registry.Node, 0)
+	for _, name := range names {
+		slice := kd.slices[name]
+		if slice.AddressType != "IPv4" && slice.AddressType != "IPv6" {
+			continue
+		}
+		port, ok := kd.port(slice)
+		if !ok {
+			continue
+		}
+
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			for _, address := range ep.Addresses {
+				if net.ParseIP(address) == nil {
+					continue
+				}
+				key := servicer.GenerateAddress(address, port)
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+				nodes = append(nodes, toNode(address, port, ep))
+			}
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return servicer.GenerateAddress(nodes[i].Host, nodes[i].Port) < servicer.GenerateAddress(nodes[j].Host, nodes[j].Port)
+	})
+
+	kd.lock.Lock()
+	kd.nodes = nodes
+	kd.updateTime = time.Now()
+	kd.lock.Unlock()
+
+	kd.subscribers.Update(kd.GetNodes())
+}
+
+// port select the port by name, or the first one if name not set
+func (kd *KubernetesDiscovery) port(slice *EndpointSlice) (int, bool) {
+	for _, p := range slice.Ports {
+		if p.Port == nil {
+			continue
+		}
+		if kd.portName == "" || (p.Name != nil && *p.Name == kd.portName) {
+			return int(*p.Port), true
+		}
+	}
+	return 0, false
+}
+
+func toNode(address string, port int, ep Endpoint) *registry.Node {
+	node := &registry.Node{
+		Host: address,
+		Port: port,
+		Meta: make(map[string]string),
+	}
+	if ep.Zone != nil {
+		node.Zone = *ep.Zone
+		node.Meta[MetaZone] = *ep.Zone
+	}
+	if ep.NodeName != nil {
+		node.Meta[MetaNodeName] = *ep.NodeName
+	}
+	if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+		node.Meta[MetaPod] = ep.TargetRef.Name
+	}
+	return node
+}
@@ -0,0 +1,176 @@
+package kubernetes
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func newSlice(name, service string, ports map[string]int32, endpoints ...Endpoint) *EndpointSlice {
+	slice := &EndpointSlice{
+		Metadata:    ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{serviceNameLabel: service}},
+		AddressType: "IPv4",
+		Endpoints:   endpoints,
+	}
+	names := make([]string, 0, len(ports))
+	for n := range ports {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		slice.Ports = append(slice.Ports, EndpointPort{Name: ptr(n), Port: ptr(ports[n])})
+	}
+	return slice
+}
+
+func ready(address, zone string) Endpoint {
+	return Endpoint{
+		Addresses:  []string{address},
+		Conditions: EndpointConditions{Ready: ptr(true)},
+		Zone:       ptr(zone),
+		NodeName:   ptr("node-" + zone),
+		TargetRef:  &ObjectReference{Kind: "Pod", Name: "pod-" + address},
+	}
+}
+
+func addresses(nodes []servicer.Node) []string {
+	res := []string{}
+	for _, n := range nodes {
+		res = append(res, n.Address())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestKubernetesDiscovery(t *testing.T) {
+	server := newFakeAPIServer(t)
+	http := map[string]int32{"http": 8080}
+	server.apply(EventAdded, newSlice("user-a", "user", http,
+		ready("10.0.0.1", "bj"),
+		Endpoint{Addresses: []string{"10.0.0.2"}, Conditions: EndpointConditions{Ready: ptr(false)}},
+		// ready is nil means ready
+		Endpoint{Addresses: []string{"10.0.0.3"}},
+	))
+	// the endpoint moving between slices is kept once
+	server.apply(EventAdded, newSlice("user-b", "user", http, ready("10.0.0.3", "sh")))
+	fqdn := newSlice("user-c", "user", http, ready("example.com", "bj"))
+	fqdn.AddressType = "FQDN"
+	server.apply(EventAdded, fqdn)
+	server.apply(EventAdded, newSlice("user-admin-a", "user-admin", http, ready("10.0.0.9", "bj")))
+
+	cli, err := NewClient(server.config())
+	assert.Nil(t, err)
+
+	_, err = NewDiscovery(nil, "", "user")
+	assert.NotNil(t, err)
+	_, err = NewDiscovery(cli, "", " ")
+	assert.NotNil(t, err)
+
+	d, err := NewDiscovery(cli, "", "user", WithWatchTimeout(time.Second), WithRetryInterval(time.Millisecond*10))
+	assert.Nil(t, err)
+	defer d.Close()
+
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.3:8080"}, addresses(d.GetNodes()))
+	for _, n := range d.GetNodes() {
+		if n.Address() != "10.0.0.1:8080" {
+			continue
+		}
+		assert.Equal(t, "bj", n.Zone())
+		assert.Equal(t, map[string]string{MetaZone: "bj", MetaNodeName: "node-bj", MetaPod: "pod-10.0.0.1"}, n.Meta())
+	}
+
+	var (
+		lock   sync.Mutex
+		events []string
+	)
+	d.Subscribe(func(evs []registry.NodeEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, ev := range evs {
+			events = append(events, ev.Type.String()+" "+ev.Node.Address())
+		}
+	})
+
+	// watch
+	server.apply(EventModified, newSlice("user-a", "user", http, ready("10.0.0.1", "bj"), ready("10.0.0.2", "bj")))
+	server.apply(EventDeleted, newSlice("user-b", "user", http))
+	server.apply(EventAdded, newSlice("user-admin-b", "user-admin", http, ready("10.0.0.8", "bj")))
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(events) == 5
+	}, time.Second*3, time.Millisecond*10)
+	lock.Lock()
+	assert.Equal(t, []string{"add 10.0.0.1:8080", "add 10.0.0.3:8080", "add 10.0.0.2:8080", "update 10.0.0.3:8080", "delete 10.0.0.3:8080"}, events)
+	lock.Unlock()
+
+	// watch again after the stream timeout
+	time.Sleep(time.Millisecond * 1100)
+	server.apply(EventAdded, newSlice("user-d", "user", http, ready("10.0.0.4", "bj")))
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 3
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, 1, server.listCount())
+
+	// relist after the version gone
+	server.compact()
+	assert.Eventually(t, func() bool {
+		return server.listCount() == 2
+	}, time.Second*3, time.Millisecond*10)
+	server.apply(EventDeleted, newSlice("user-d", "user", http))
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 2
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, 2, server.listCount())
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, addresses(d.GetNodes()))
+}
+
+func TestKubernetesDiscovery_PortName(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.apply(EventAdded, newSlice("user-a", "user", map[string]int32{"grpc": 9090, "http": 8080}, ready("10.0.0.1", "bj")))
+
+	cli, err := NewClient(server.config())
+	assert.Nil(t, err)
+
+	d, err := NewDiscovery(cli, "default", "user", WithPortName("http"))
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Equal(t, []string{"10.0.0.1:8080"}, addresses(d.GetNodes()))
+
+	d2, err := NewDiscovery(cli, "default", "user", WithPortName("metrics"))
+	assert.Nil(t, err)
+	defer d2.Close()
+	assert.Equal(t, 0, len(d2.GetNodes()))
+}
+
+func TestKubernetesDiscovery_Unauthorized(t *testing.T) {
+	server := newFakeAPIServer(t)
+	cfg := server.config()
+	cfg.BearerToken = "invalid"
+
+	cli, err := NewClient(cfg)
+	assert.Nil(t, err)
+	_, err = NewDiscovery(cli, "", "user")
+	assert.NotNil(t, err)
+	status, ok := err.(*Status)
+	assert.True(t, ok)
+	assert.Equal(t, 401, status.Code)
+
+	// tls verify failed without ca
+	cfg = server.config()
+	cfg.CAData = nil
+	cli, err = NewClient(cfg)
+	assert.Nil(t, err)
+	_, err = NewDiscovery(cli, "", "user")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,244 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/why444216978/go-util/nopanic"
+
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type options struct {
+	logger          logger.Logger
+	prefix          string
+	ttl             time.Duration
+	refreshInterval time.Duration
+}
+
+type Option func(*options)
+
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithPrefix set the prefix of keys, default is DefaultPrefix
+func WithPrefix(prefix string) Option {
+	return func(o *options) { o.prefix = prefix }
+}
+
+// WithTTL set the ttl of node, the node without heartbeat for ttl is reaped, default is 10s
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) { o.ttl = ttl }
+}
+
+// WithRefreshInterval set the interval of reaping and reloading, which covers the lost messages, default is ttl/2
+func WithRefreshInterval(d time.Duration) Option {
+	return func(o *options) { o.refreshInterval = d }
+}
+
+// RedisDiscovery load the alive nodes of service, reload on the published change and reap the expired nodes periodically.
+// The heartbeat is compared with the local clock, so the clock skew between hosts should be much less than ttl.
+type RedisDiscovery struct {
+	*options
+	setup.SetupLogger
+	cli         redis.UniversalClient
+	serviceName string
+	keys        []string
+	channel     string
+	pubsub      *redis.PubSub
+	lock        sync.RWMutex
+	nodes       []*registry.Node
+	updateTime  time.Time
+	subscribers *registry.Subscribers
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+var _ registry.Discovery = (*RedisDiscovery)(nil)
+
+// NewDiscovery subscribe the channel and load the nodes once, return error if failed, then watch in the background.
+func NewDiscovery(cli redis.UniversalClient, name string, opts ...Option) (*RedisDiscovery, error) {
+	if cli == nil {
+		return nil, errors.New("cli is nil")
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return nil, errors.New("serviceName is nil")
+	}
+
+	opt := &options{
+		prefix: DefaultPrefix,
+		ttl:    10 * time.Second,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+	if opt.refreshInterval <= 0 {
+		opt.refreshInterval = opt.ttl / 2
+	}
+
+	rd := &RedisDiscovery{
+		options:     opt,
+		cli:         cli,
+		serviceName: name,
+		keys:        []string{nodesKey(opt.prefix, name), heartbeatKey(opt.prefix, name)},
+		channel:     channel(opt.prefix, name),
+		subscribers: registry.NewSubscribers(),
+		done:        make(chan struct{}),
+	}
+	rd.SetupLogger.SetLogger(opt.logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// subscribe before load, so the change between them is not lost
+	rd.pubsub = cli.Subscribe(ctx, rd.channel)
+	if _, err := rd.pubsub.Receive(ctx); err != nil {
+		cancel()
+		_ = rd.pubsub.Close()
+		return nil, err
+	}
+
+	if err := rd.load(ctx); err != nil {
+		cancel()
+		_ = rd.pubsub.Close()
+		return nil, err
+	}
+	rd.cancel = cancel
+	rd.watch(ctx)
+
+	return rd, nil
+}
+
+func (rd *RedisDiscovery) GetNodes() []servicer.Node {
+	rd.lock.RLock()
+	defer rd.lock.RUnlock()
+
+	nodes := make([]servicer.Node, 0, len(rd.nodes))
+	for _, node := range rd.nodes {
+		nodes = append(nodes, servicer.NewNode(node.Host, node.Port,
+			servicer.WithWeight(node.Weight),
+			servicer.WithZone(node.Zone),
+			servicer.WithMeta(node.Meta)))
+	}
+	return nodes
+}
+
+func (rd *RedisDiscovery) GetUpdateTime() time.Time {
+	rd.lock.RLock()
+	defer rd.lock.RUnlock()
+	return rd.updateTime
+}
+
+func (rd *RedisDiscovery) Subscribe(f func(events []registry.NodeEvent)) (unsubscribe func()) {
+	return rd.subscribers.Subscribe(f)
+}
+
+// Close unsubscribe the channel and wait the loop exit.
+func (rd *RedisDiscovery) Close() error {
+	rd.cancel()
+	err := rd.pubsub.Close()
+	<-rd.done
+	return err
+}
+
+// watch reload on every message, and reap then reload every refreshInterval.
+// The pubsub reconnects by itself, the messages lost while reconnecting are covered by refreshing.
+func (rd *RedisDiscovery) watch(ctx context.Context) {
+	messages := rd.pubsub.Channel()
+	ticker := time.NewTicker(rd.refreshInterval)
+
+	go nopanic.GoVoid(ctx, func() {
+		defer close(rd.done)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+			case <-ticker.C:
+				if err := rd.reap(ctx); err != nil && ctx.Err() == nil {
+					rd.AutoLogger().Warn(ctx, "redisDiscoveryReapErr",
+						logger.Reflect(logger.ServiceName, rd.serviceName),
+						logger.Error(err),
+					)
+				}
+			}
+
+			if err := rd.load(ctx); err != nil && ctx.Err() == nil {
+				rd.AutoLogger().Warn(ctx, "redisDiscoveryLoadErr",
+					logger.Reflect(logger.ServiceName, rd.serviceName),
+					logger.Error(err),
+				)
+			}
+		}
+	})
+}
+
+// reap remove the nodes whose heartbeat expired, every discovery reaps and it is idempotent.
+func (rd *RedisDiscovery) reap(ctx context.Context) error {
+	deadline := time.Now().Add(-rd.ttl).UnixMilli()
+	return reapScript.Run(ctx, rd.cli, rd.keys, deadline, rd.channel).Err()
+}
+
+// load replace all nodes by the alive ones, the node expired but not reaped yet is skipped too.
+func (rd *RedisDiscovery) load(ctx context.Context) error {
+	var (
+		alive *redis.StringSliceCmd
+		vals  *redis.StringStringMapCmd
+	)
+	deadline := time.Now().Add(-rd.ttl).UnixMilli()
+	if _, err := rd.cli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		alive = pipe.ZRangeByScore(ctx, rd.keys[1], &redis.ZRangeBy{Min: strconv.FormatInt(deadline, 10), Max: "+inf"})
+		vals = pipe.HGetAll(ctx, rd.keys[0])
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	nodes := make([]*registry.Node, 0, len(alive.Val()))
+	for _, address := range alive.Val() {
+		val, ok := vals.Val()[address]
+		if !ok {
+			continue
+		}
+		node, err := decode(val)
+		if err != nil {
+			rd.AutoLogger().Warn(ctx, "redisDiscoveryDecodeErr",
+				logger.Reflect(logger.ServiceName, rd.serviceName),
+				logger.Reflect("address", address),
+				logger.Error(err),
+			)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return servicer.GenerateAddress(nodes[i].Host, nodes[i].Port) < servicer.GenerateAddress(nodes[j].Host, nodes[j].Port)
+	})
+
+	rd.lock.Lock()
+	rd.nodes = nodes
+	rd.updateTime = time.Now()
+	rd.lock.Unlock()
+
+	rd.subscribers.Update(rd.GetNodes())
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+	"github.com/air-go/rpc/mock/tools/miniredis"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+func addresses(nodes []servicer.Node) []string {
+	res := []string{}
+	for _, n := range nodes {
+		res = append(res, n.Address())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestNewDiscovery(t *testing.T) {
+	cli := miniredis.NewClient()
+
+	_, err := NewDiscovery(nil, "svc")
+	assert.NotNil(t, err)
+	_, err = NewDiscovery(cli, " ")
+	assert.NotNil(t, err)
+	_, err = NewDiscovery(cli, "svc", WithTTL(0))
+	assert.NotNil(t, err)
+
+	d, err := NewDiscovery(cli, "svc", WithPrefix("test:"+t.Name()))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second*5, d.refreshInterval)
+	assert.Equal(t, 0, len(d.GetNodes()))
+	assert.Nil(t, d.Close())
+}
+
+func TestRedisDiscovery(t *testing.T) {
+	ctx := context.Background()
+	cli := miniredis.NewClient()
+	prefix := "test:" + t.Name()
+	ttl := time.Millisecond * 300
+
+	r1, err := NewRegistry(cli, "svc", "127.0.0.1", 80, WithRegistrarPrefix(prefix), WithRegistrarTTL(ttl),
+		WithRegistrarZone("bj"), WithRegistrarWeight(10), WithRegistrarMeta(map[string]string{"lane": "blue"}))
+	assert.Nil(t, err)
+	assert.Nil(t, r1.Register(ctx))
+	defer r1.DeRegister(ctx)
+
+	// the other service and prefix are not discovered
+	other, err := NewRegistry(cli, "other", "127.0.0.1", 90, WithRegistrarPrefix(prefix), WithRegistrarTTL(ttl))
+	assert.Nil(t, err)
+	assert.Nil(t, other.Register(ctx))
+	defer other.DeRegister(ctx)
+
+	// the refresh is long, so the changes are from pubsub
+	d, err := NewDiscovery(cli, "svc", WithPrefix(prefix), WithTTL(ttl), WithRefreshInterval(time.Hour))
+	assert.Nil(t, err)
+	defer d.Close()
+
+	nodes := d.GetNodes()
+	assert.Equal(t, []string{"127.0.0.1:80"}, addresses(nodes))
+	assert.Equal(t, "bj", nodes[0].Zone())
+	assert.Equal(t, 10, nodes[0].Weight())
+	assert.Equal(t, "blue", nodes[0].Meta()["lane"])
+	assert.False(t, d.GetUpdateTime().IsZero())
+
+	var (
+		lock   sync.Mutex
+		events []string
+	)
+	d.Subscribe(func(evs []registry.NodeEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, ev := range evs {
+			events = append(events, ev.Type.String()+" "+ev.Node.Address())
+		}
+	})
+	getEvents := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, events...)
+	}
+
+	r2, err := NewRegistry(cli, "svc", "127.0.0.1", 81, WithRegistrarPrefix(prefix), WithRegistrarTTL(ttl))
+	assert.Nil(t, err)
+	assert.Nil(t, r2.Register(ctx))
+	assert.Eventually(t, func() bool {
+		return len(getEvents()) == 2
+	}, time.Second, time.Millisecond*10)
+
+	assert.Nil(t, r2.DeRegister(ctx))
+	assert.Eventually(t, func() bool {
+		return len(getEvents()) == 3
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, []string{"add 127.0.0.1:80", "add 127.0.0.1:81", "delete 127.0.0.1:81"}, getEvents())
+	assert.Equal(t, []string{"127.0.0.1:80"}, addresses(d.GetNodes()))
+}
+
+func TestRedisDiscovery_Reap(t *testing.T) {
+	ctx := context.Background()
+	cli := miniredis.NewClient()
+	prefix := "test:" + t.Name()
+	ttl := time.Millisecond * 300
+
+	r, err := NewRegistry(cli, "svc", "127.0.0.1", 80, WithRegistrarPrefix(prefix), WithRegistrarTTL(ttl))
+	assert.Nil(t, err)
+	assert.Nil(t, r.Register(ctx))
+	defer r.DeRegister(ctx)
+
+	// the crashed instance without heartbeat
+	crashed, err := NewRegistry(cli, "svc", "127.0.0.1", 81, WithRegistrarPrefix(prefix), WithRegistrarTTL(ttl))
+	assert.Nil(t, err)
+	_, err = crashed.register(ctx, true)
+	assert.Nil(t, err)
+
+	d, err := NewDiscovery(cli, "svc", WithPrefix(prefix), WithTTL(ttl), WithRefreshInterval(time.Millisecond*50))
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.1:81"}, addresses(d.GetNodes()))
+
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 1
+	}, time.Second*2, time.Millisecond*10)
+	assert.Equal(t, []string{"127.0.0.1:80"}, addresses(d.GetNodes()))
+	assert.Equal(t, []string{"127.0.0.1:80"}, cli.HKeys(ctx, nodesKey(prefix, "svc")).Val())
+	assert.Equal(t, []string{"127.0.0.1:80"}, cli.ZRange(ctx, heartbeatKey(prefix, "svc"), 0, -1).Val())
+
+	// the expired but not reaped node is skipped
+	assert.Nil(t, cli.ZAdd(ctx, heartbeatKey(prefix, "svc"), &goredis.Z{Score: 1, Member: "127.0.0.1:82"}).Err())
+	assert.Nil(t, cli.HSet(ctx, nodesKey(prefix, "svc"), "127.0.0.1:82", `{"Host":"127.0.0.1","Port":82}`).Err())
+	assert.Nil(t, d.load(ctx))
+	assert.Equal(t, []string{"127.0.0.1:80"}, addresses(d.GetNodes()))
+}
@@ -0,0 +1,74 @@
+// redis is service registry for the deployments without etcd, the instances are kept in redis and
+// expired by heartbeat, the changes are published to the channel of service.
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/air-go/rpc/library/registry"
+)
+
+// DefaultPrefix is the prefix of keys and channel
+const DefaultPrefix = "air:registry"
+
+// The keys of service share the hash tag of service name, so they are in the same slot of redis cluster.
+//
+//	{prefix}:{service}:nodes     hash of address to the encoded node
+//	{prefix}:{service}:heartbeat sorted set of address scored by the unix millisecond of last heartbeat
+//	{prefix}:{service}:events    channel of change, the message is the changed address or reap
+func nodesKey(prefix, service string) string {
+	return prefix + ":{" + service + "}:nodes"
+}
+
+func heartbeatKey(prefix, service string) string {
+	return prefix + ":{" + service + "}:heartbeat"
+}
+
+func channel(prefix, service string) string {
+	return prefix + ":{" + service + "}:events"
+}
+
+// registerScript put the node and heartbeat, the change is published if the node is new or force,
+// return 1 if the node is new which means it is reaped or never registered.
+var registerScript = redis.NewScript(`
+local new = redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+if new == 1 or ARGV[5] == '1' then
+	redis.call('PUBLISH', ARGV[4], ARGV[1])
+end
+return new
+`)
+
+// deregisterScript remove the node and heartbeat, then publish the change
+var deregisterScript = redis.NewScript(`
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('PUBLISH', ARGV[2], ARGV[1])
+return 1
+`)
+
+// reapScript remove the nodes whose last heartbeat is before the deadline, return the count of reaped
+var reapScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', '(' .. ARGV[1])
+for _, addr in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], addr)
+	redis.call('HDEL', KEYS[1], addr)
+end
+if #expired > 0 then
+	redis.call('PUBLISH', ARGV[2], 'reap')
+end
+return #expired
+`)
+
+func encode(node *registry.Node) (string, error) {
+	b, err := json.Marshal(node)
+	return string(b), err
+}
+
+func decode(val string) (*registry.Node, error) {
+	node := &registry.Node{}
+	err := json.Unmarshal([]byte(val), node)
+	return node, err
+}
@@ -0,0 +1,183 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// ErrReaped is the reason of StateLost when the node was reaped by discovery, it is registered again by heartbeat
+var ErrReaped = errors.New("node reaped")
+
+type RegistrarOption struct {
+	prefix   string
+	ttl      time.Duration
+	weight   int
+	priority int
+	zone     string
+	meta     map[string]string
+	notify   func(registry.Event)
+}
+
+type RegistrarOptionFunc func(*RegistrarOption)
+
+func defaultRegistrarOption() *RegistrarOption {
+	return &RegistrarOption{
+		prefix: DefaultPrefix,
+		ttl:    10 * time.Second,
+	}
+}
+
+// WithRegistrarPrefix set the prefix of keys, default is DefaultPrefix
+func WithRegistrarPrefix(prefix string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.prefix = prefix }
+}
+
+// WithRegistrarTTL set the ttl of node, the heartbeat is sent every ttl/3, default is 10s,
+// it should be same as the ttl of discovery.
+func WithRegistrarTTL(ttl time.Duration) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.ttl = ttl }
+}
+
+func WithRegistrarWeight(weight int) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.weight = weight }
+}
+
+func WithRegistrarPriority(priority int) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.priority = priority }
+}
+
+func WithRegistrarZone(zone string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.zone = zone }
+}
+
+func WithRegistrarMeta(meta map[string]string) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.meta = meta }
+}
+
+// WithRegistrarNotify set the func called on every registration state change, it should not block
+func WithRegistrarNotify(f func(registry.Event)) RegistrarOptionFunc {
+	return func(o *RegistrarOption) { o.notify = f }
+}
+
+// RedisRegistrar register node to redis and keep it alive by heartbeat
+type RedisRegistrar struct {
+	opts    *RegistrarOption
+	cli     redis.UniversalClient
+	address string
+	val     string
+	keys    []string
+	channel string
+	states  *registry.States
+}
+
+var _ registry.Registrar = (*RedisRegistrar)(nil)
+
+// NewRegistry
+func NewRegistry(cli redis.UniversalClient, name, host string, port int, opts ...RegistrarOptionFunc) (*RedisRegistrar, error) {
+	if cli == nil {
+		return nil, errors.New("cli is nil")
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return nil, errors.New("serviceName is nil")
+	}
+
+	opt := defaultRegistrarOption()
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+
+	val, err := encode(&registry.Node{
+		Host:      host,
+		Port:      port,
+		Weight:    opt.weight,
+		Priority:  opt.priority,
+		Zone:      opt.zone,
+		Meta:      opt.meta,
+		StartTime: registry.StartTime().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisRegistrar{
+		opts:    opt,
+		cli:     cli,
+		address: servicer.GenerateAddress(host, port),
+		val:     val,
+		keys:    []string{nodesKey(opt.prefix, name), heartbeatKey(opt.prefix, name)},
+		channel: channel(opt.prefix, name),
+		states:  registry.NewStates(opt.notify),
+	}, nil
+}
+
+// Register put the node and send heartbeat in the background until DeRegister,
+// the node is registered again by heartbeat if it was reaped.
+func (s *RedisRegistrar) Register(ctx context.Context) error {
+	return s.states.Register(func(context.Context) error {
+		_, err := s.register(ctx, true)
+		return err
+	}, s.keepAlive)
+}
+
+// State return the current registration state
+func (s *RedisRegistrar) State() registry.State {
+	return s.states.State()
+}
+
+// DeRegister stop the heartbeat, then remove the node and publish the change
+func (s *RedisRegistrar) DeRegister(ctx context.Context) error {
+	return s.states.DeRegister(func() error {
+		return deregisterScript.Run(ctx, s.cli, s.keys, s.address, s.channel).Err()
+	})
+}
+
+// register return true if the node is new
+func (s *RedisRegistrar) register(ctx context.Context, force bool) (bool, error) {
+	publish := "0"
+	if force {
+		publish = "1"
+	}
+	created, err := registerScript.Run(ctx, s.cli, s.keys, s.address, s.val, time.Now().UnixMilli(), s.channel, publish).Int()
+	return created == 1, err
+}
+
+func (s *RedisRegistrar) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cctx, cancel := context.WithTimeout(ctx, s.opts.ttl/3)
+		reaped, err := s.register(cctx, false)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err != nil:
+			s.states.Set(registry.StateLost, err)
+		case reaped:
+			s.states.Set(registry.StateLost, ErrReaped)
+			s.states.Set(registry.StateRegistered, nil)
+		case s.states.State() != registry.StateRegistered:
+			s.states.Set(registry.StateRegistered, nil)
+		}
+	}
+}
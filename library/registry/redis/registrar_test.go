@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/mock/tools/miniredis"
+)
+
+type recordEvents struct {
+	lock   sync.Mutex
+	states []registry.State
+	errs   []error
+}
+
+func (r *recordEvents) notify(e registry.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.states = append(r.states, e.State)
+	r.errs = append(r.errs, e.Err)
+}
+
+func (r *recordEvents) get() []registry.State {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]registry.State{}, r.states...)
+}
+
+func TestNewRegistry(t *testing.T) {
+	cli := miniredis.NewClient()
+
+	_, err := NewRegistry(nil, "svc", "127.0.0.1", 80)
+	assert.NotNil(t, err)
+	_, err = NewRegistry(cli, "", "127.0.0.1", 80)
+	assert.NotNil(t, err)
+	_, err = NewRegistry(cli, "svc", "127.0.0.1", 80, WithRegistrarTTL(0))
+	assert.NotNil(t, err)
+
+	r, err := NewRegistry(cli, "svc", "127.0.0.1", 80, WithRegistrarPrefix("test"))
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:80", r.address)
+	assert.Equal(t, []string{"test:{svc}:nodes", "test:{svc}:heartbeat"}, r.keys)
+	assert.Equal(t, "test:{svc}:events", r.channel)
+}
+
+func TestRedisRegistrar(t *testing.T) {
+	ctx := context.Background()
+	cli := miniredis.NewClient()
+	prefix := "test:" + t.Name()
+
+	sub := cli.Subscribe(ctx, channel(prefix, "svc"))
+	defer sub.Close()
+	_, err := sub.Receive(ctx)
+	assert.Nil(t, err)
+
+	// the notify func reading the state must not deadlock
+	var r *RedisRegistrar
+	events, seen := &recordEvents{}, &recordEvents{}
+	r, err = NewRegistry(cli, "svc", "127.0.0.1", 80,
+		WithRegistrarPrefix(prefix),
+		WithRegistrarWeight(10),
+		WithRegistrarPriority(1),
+		WithRegistrarZone("bj"),
+		WithRegistrarMeta(map[string]string{"lane": "blue"}),
+		WithRegistrarTTL(time.Millisecond*300),
+		WithRegistrarNotify(func(e registry.Event) {
+			events.notify(e)
+			seen.notify(registry.Event{State: r.State()})
+		}),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, registry.StateUnregistered, r.State())
+
+	assert.Nil(t, r.Register(ctx))
+	assert.NotNil(t, r.Register(ctx))
+	assert.Equal(t, registry.StateRegistered, r.State())
+
+	msg, err := sub.ReceiveMessage(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:80", msg.Payload)
+
+	val, err := cli.HGet(ctx, nodesKey(prefix, "svc"), "127.0.0.1:80").Result()
+	assert.Nil(t, err)
+	node, err := decode(val)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, node.Weight)
+	assert.Equal(t, 1, node.Priority)
+	assert.Equal(t, "bj", node.Zone)
+	assert.Equal(t, map[string]string{"lane": "blue"}, node.Meta)
+
+	// heartbeat
+	score, err := cli.ZScore(ctx, heartbeatKey(prefix, "svc"), "127.0.0.1:80").Result()
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		s, _ := cli.ZScore(ctx, heartbeatKey(prefix, "svc"), "127.0.0.1:80").Result()
+		return s > score
+	}, time.Second, time.Millisecond*10)
+
+	// registered again by heartbeat after reaped
+	assert.Nil(t, reapScript.Run(ctx, cli, r.keys, time.Now().Add(time.Second).UnixMilli(), r.channel).Err())
+	msg, err = sub.ReceiveMessage(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "reap", msg.Payload)
+	msg, err = sub.ReceiveMessage(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:80", msg.Payload)
+	assert.Eventually(t, func() bool {
+		return len(events.get()) == 3
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, []registry.State{registry.StateRegistered, registry.StateLost, registry.StateRegistered}, events.get())
+	events.lock.Lock()
+	assert.Equal(t, ErrReaped, events.errs[1])
+	events.lock.Unlock()
+
+	assert.Nil(t, r.DeRegister(ctx))
+	assert.Equal(t, registry.StateDeregistered, r.State())
+	assert.Equal(t, events.get(), seen.get())
+	msg, err = sub.ReceiveMessage(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:80", msg.Payload)
+	assert.Equal(t, int64(0), cli.Exists(ctx, nodesKey(prefix, "svc"), heartbeatKey(prefix, "svc")).Val())
+}
@@ -7,10 +7,62 @@ import (
 	"github.com/air-go/rpc/library/servicer"
 )
 
+// Type of registry, used by RegistryType of service config
+const (
+	TypeEtcd       = "etcd"
+	TypeConsul     = "consul"
+	TypeFile       = "file"
+	TypeKubernetes = "kubernetes"
+	TypeRedis      = "redis"
+	TypeXDS        = "xds"
+)
+
 type Node struct {
-	Host   string
-	Port   int
-	Weight int // TODO support service weight
+	Host      string
+	Port      int
+	Weight    int
+	Priority  int // the lower the preferred
+	Zone      string
+	Meta      map[string]string // version, lane and other tags
+	StartTime int64             // unix second the process started
+}
+
+// startTime is the init time of package, regarded as the process start time
+var startTime = time.Now()
+
+func StartTime() time.Time {
+	return startTime
+}
+
+// State is the registration state of Registrar
+type State int32
+
+const (
+	StateUnregistered State = iota
+	StateRegistered
+	StateLost // registration lost and retrying
+	StateDeregistered
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUnregistered:
+		return "unregistered"
+	case StateRegistered:
+		return "registered"
+	case StateLost:
+		return "lost"
+	case StateDeregistered:
+		return "deregistered"
+	}
+	return "unknown"
+}
+
+// Event is the registration state change, Err is the reason of StateLost or the failure of retry
+type Event struct {
+	State State
+	Err   error
+	Time  time.Time
 }
 
 // Registrar is service registrar
@@ -23,6 +75,9 @@ type Registrar interface {
 type Discovery interface {
 	GetNodes() []servicer.Node
 	GetUpdateTime() time.Time
+	// Subscribe call f with all nodes as NodeAdd immediately, then with the deltas of every change,
+	// f is called serially and should not block, call the returned func to unsubscribe.
+	Subscribe(f func(events []NodeEvent)) (unsubscribe func())
 	Close() error
 }
 
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshot persist the last good nodes of one service to local file,
+// so that the nodes are still available if the registry is unreachable when the process starts.
+type Snapshot struct {
+	path string
+}
+
+// NewSnapshot the file is <dir>/<serviceName>.json
+func NewSnapshot(dir, serviceName string) *Snapshot {
+	return &Snapshot{path: filepath.Join(dir, url.PathEscape(serviceName)+".json")}
+}
+
+func (s *Snapshot) Path() string {
+	return s.path
+}
+
+// Load return the saved nodes keyed by their key in registry
+func (s *Snapshot) Load() (map[string]*Node, error) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*Node)
+	if err = json.Unmarshal(b, &nodes); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal %s", s.path)
+	}
+	return nodes, nil
+}
+
+// Save write to a temp file then rename, so the file is never partially written
+func (s *Snapshot) Save(nodes map[string]*Node) error {
+	b, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
+
+// Protector refuse the update which drop more than threshold ratio of nodes compared with a baseline,
+// the baseline is the node count of the last accepted full load, raised by the added nodes,
+// so the nodes removed one by one are counted together instead of each change alone.
+// The protection holds at most hold since the first refused update, then the update is accepted,
+// so that the real removals, such as one of two nodes, are not refused forever.
+// Protector is not safe for concurrent use.
+type Protector struct {
+	threshold float64
+	hold      time.Duration
+	baseline  int
+	since     time.Time // the first refused time, zero if not protecting
+}
+
+// NewProtector threshold <= 0 means never protect, hold <= 0 means protect until the update is acceptable.
+func NewProtector(threshold float64, hold time.Duration) *Protector {
+	return &Protector{threshold: threshold, hold: hold}
+}
+
+// Refuse report whether the update from before to after nodes should be refused,
+// full means after is the count of a full load, which becomes the baseline if accepted.
+func (p *Protector) Refuse(before, after int, full bool) bool {
+	if p.threshold <= 0 {
+		return false
+	}
+	if before > p.baseline {
+		p.baseline = before
+	}
+
+	if p.baseline == 0 || float64(p.baseline-after)/float64(p.baseline) <= p.threshold {
+		p.since = time.Time{}
+		if full {
+			p.baseline = after
+		}
+		return false
+	}
+
+	now := time.Now()
+	if p.since.IsZero() {
+		p.since = now
+	}
+	if p.hold > 0 && now.Sub(p.since) >= p.hold {
+		p.Reset(after)
+		return false
+	}
+	return true
+}
+
+// Reset set the baseline and stop protecting, such as the nodes are replaced without check.
+func (p *Protector) Reset(count int) {
+	p.baseline = count
+	p.since = time.Time{}
+}
@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSnapshot(dir+"/sub", "user/admin")
+
+	_, err := s.Load()
+	assert.True(t, os.IsNotExist(err))
+
+	nodes := map[string]*Node{
+		"k1": {Host: "127.0.0.1", Port: 80, Weight: 10, Meta: map[string]string{"lane": "blue"}},
+		"k2": {Host: "127.0.0.2", Port: 80},
+	}
+	assert.Nil(t, s.Save(nodes))
+	loaded, err := s.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, nodes, loaded)
+
+	assert.Nil(t, s.Save(map[string]*Node{}))
+	loaded, err = s.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(loaded))
+
+	// no temp file left
+	entries, err := os.ReadDir(dir + "/sub")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "user%2Fadmin.json", entries[0].Name())
+
+	assert.Nil(t, os.WriteFile(s.Path(), []byte("invalid"), 0o644))
+	_, err = s.Load()
+	assert.NotNil(t, err)
+}
+
+func TestProtector(t *testing.T) {
+	// threshold <= 0 never protect
+	p := NewProtector(0, 0)
+	assert.False(t, p.Refuse(10, 0, true))
+
+	p = NewProtector(0.5, 0)
+	assert.False(t, p.Refuse(0, 0, true))
+	assert.False(t, p.Refuse(0, 10, true))
+	assert.False(t, p.Refuse(10, 20, false))
+	assert.False(t, p.Refuse(20, 10, false))
+	// compared with the baseline 20 instead of the previous 10
+	assert.True(t, p.Refuse(10, 9, false))
+	assert.True(t, p.Refuse(10, 9, true))
+	// the acceptable full load becomes the baseline
+	assert.False(t, p.Refuse(10, 10, true))
+	assert.False(t, p.Refuse(10, 5, false))
+	assert.True(t, p.Refuse(5, 4, false))
+
+	p.Reset(1)
+	assert.True(t, p.Refuse(1, 0, false))
+	assert.True(t, p.Refuse(1, 0, true))
+
+	p = NewProtector(1, 0)
+	assert.False(t, p.Refuse(1, 0, false))
+}
+
+func TestProtector_Hold(t *testing.T) {
+	p := NewProtector(0.3, time.Millisecond*100)
+	assert.False(t, p.Refuse(0, 2, true))
+	assert.True(t, p.Refuse(2, 1, false))
+	assert.True(t, p.Refuse(2, 1, true))
+
+	// the protection holds at most 100ms
+	time.Sleep(time.Millisecond * 150)
+	assert.False(t, p.Refuse(2, 1, true))
+	assert.False(t, p.Refuse(1, 1, true))
+
+	// the hold restarts from the next refused update
+	assert.False(t, p.Refuse(1, 3, true))
+	assert.True(t, p.Refuse(3, 1, false))
+	assert.True(t, p.Refuse(3, 1, false))
+}
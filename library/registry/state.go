@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// States keep the registration state of Registrar and notify its changes, and run the registration lifecycle,
+// so the registrars share the order of registering, keeping alive, deregistering and notifying.
+// Set must not be called with the lock of registrar held,
+// so the notify func is free to call State of the registrar.
+type States struct {
+	lock       sync.Mutex
+	state      State
+	notifyLock sync.Mutex // keep the events in the order of Set
+	notify     func(Event)
+
+	runLock sync.Mutex // guard cancel and done
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewStates return States in StateUnregistered, notify can be nil.
+func NewStates(notify func(Event)) *States {
+	return &States{notify: notify}
+}
+
+// State return the current state
+func (s *States) State() State {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.state
+}
+
+// Set update the state and notify the event, the retry failure is notified even if state not changed.
+func (s *States) Set(state State, err error) {
+	s.notifyLock.Lock()
+	defer s.notifyLock.Unlock()
+
+	s.lock.Lock()
+	s.state = state
+	s.lock.Unlock()
+
+	if s.notify != nil {
+		s.notify(Event{State: state, Err: err, Time: time.Now()})
+	}
+}
+
+// Register call register once, then notify StateRegistered and run keepAlive in the background,
+// so StateRegistered is always the first event. Both funcs get the ctx canceled by DeRegister,
+// and keepAlive should return after it done. register must not call Register or DeRegister.
+func (s *States) Register(register func(ctx context.Context) error, keepAlive func(ctx context.Context)) error {
+	s.runLock.Lock()
+	if s.cancel != nil {
+		s.runLock.Unlock()
+		return errors.New("already registered")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := register(ctx); err != nil {
+		cancel()
+		s.runLock.Unlock()
+		return err
+	}
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	s.runLock.Unlock()
+
+	s.Set(StateRegistered, nil)
+
+	go func() {
+		defer close(done)
+		keepAlive(ctx)
+	}()
+
+	return nil
+}
+
+// DeRegister stop keepAlive and wait it returned, then call deregister without any lock held,
+// StateDeregistered is notified if deregister succeeded.
+func (s *States) DeRegister(deregister func() error) error {
+	s.runLock.Lock()
+	cancel, done := s.cancel, s.done
+	s.runLock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	if err := deregister(); err != nil {
+		return err
+	}
+	s.Set(StateDeregistered, nil)
+	return nil
+}
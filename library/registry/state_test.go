@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStates(t *testing.T) {
+	var (
+		s      *States
+		events []Event
+		seen   []State
+	)
+	// the notify func can read the state without deadlock
+	s = NewStates(func(e Event) {
+		events = append(events, e)
+		seen = append(seen, s.State())
+	})
+	assert.Equal(t, StateUnregistered, s.State())
+
+	errLost := errors.New("lost")
+	s.Set(StateRegistered, nil)
+	s.Set(StateLost, errLost)
+	s.Set(StateLost, errLost)
+	assert.Equal(t, StateLost, s.State())
+
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, errLost, events[2].Err)
+	assert.False(t, events[2].Time.IsZero())
+	assert.Equal(t, []State{StateRegistered, StateLost, StateLost}, seen)
+
+	// nil notify
+	s = NewStates(nil)
+	s.Set(StateDeregistered, nil)
+	assert.Equal(t, StateDeregistered, s.State())
+}
+
+func TestStates_Register(t *testing.T) {
+	var (
+		s      *States
+		lock   sync.Mutex
+		events []string
+	)
+	record := func(e string) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, e)
+	}
+	s = NewStates(func(e Event) { record(e.State.String()) })
+
+	// register failed, can be retried
+	errRegister := errors.New("register")
+	assert.Equal(t, errRegister, s.Register(func(context.Context) error { return errRegister }, nil))
+	assert.Equal(t, StateUnregistered, s.State())
+
+	// StateRegistered is notified before keepAlive starts
+	keepAlive := func(ctx context.Context) {
+		record("keepAlive")
+		<-ctx.Done()
+		record("stopped")
+	}
+	assert.Nil(t, s.Register(func(context.Context) error { return nil }, keepAlive))
+	assert.NotNil(t, s.Register(func(context.Context) error { return nil }, keepAlive))
+
+	// deregister is called after keepAlive returned, the failure is not notified
+	errDeregister := errors.New("deregister")
+	assert.Equal(t, errDeregister, s.DeRegister(func() error {
+		record("deregister")
+		return errDeregister
+	}))
+	assert.Nil(t, s.DeRegister(func() error { return nil }))
+	assert.Equal(t, StateDeregistered, s.State())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{StateRegistered.String(), "keepAlive", "stopped", "deregister", StateDeregistered.String()}, events)
+}
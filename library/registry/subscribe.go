@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type NodeEventType uint8
+
+const (
+	NodeAdd NodeEventType = iota + 1
+	NodeUpdate
+	NodeDelete
+)
+
+func (t NodeEventType) String() string {
+	switch t {
+	case NodeAdd:
+		return "add"
+	case NodeUpdate:
+		return "update"
+	case NodeDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// NodeEvent is the change of one node, identified by address
+type NodeEvent struct {
+	Type NodeEventType
+	Node servicer.Node
+}
+
+// Subscribers keep the latest nodes and push the deltas to subscribers,
+// it helps Discovery implement Subscribe by calling Update after every change.
+type Subscribers struct {
+	lock  sync.Mutex
+	nodes map[string]servicer.Node
+	subs  map[int]func([]NodeEvent)
+	next  int
+}
+
+func NewSubscribers() *Subscribers {
+	return &Subscribers{
+		nodes: make(map[string]servicer.Node),
+		subs:  make(map[int]func([]NodeEvent)),
+	}
+}
+
+func (s *Subscribers) Subscribe(f func(events []NodeEvent)) (unsubscribe func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id := s.next
+	s.next++
+	s.subs[id] = f
+
+	if len(s.nodes) > 0 {
+		events := make([]NodeEvent, 0, len(s.nodes))
+		for _, node := range s.nodes {
+			events = append(events, NodeEvent{Type: NodeAdd, Node: node})
+		}
+		sortEvents(events)
+		f(events)
+	}
+
+	return func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.subs, id)
+	}
+}
+
+// Update replace all nodes and push the deltas if any.
+func (s *Subscribers) Update(nodes []servicer.Node) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		events = make([]NodeEvent, 0)
+		now    = make(map[string]servicer.Node, len(nodes))
+	)
+	for _, node := range nodes {
+		address := node.Address()
+		now[address] = node
+
+		old, ok := s.nodes[address]
+		switch {
+		case !ok:
+			events = append(events, NodeEvent{Type: NodeAdd, Node: node})
+		case !servicer.SameNode(old, node):
+			events = append(events, NodeEvent{Type: NodeUpdate, Node: node})
+		}
+	}
+	for address, node := range s.nodes {
+		if _, ok := now[address]; !ok {
+			events = append(events, NodeEvent{Type: NodeDelete, Node: node})
+		}
+	}
+	s.nodes = now
+
+	if len(events) == 0 {
+		return
+	}
+
+	sortEvents(events)
+	for _, f := range s.subs {
+		f(events)
+	}
+}
+
+func sortEvents(events []NodeEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Node.Address() < events[j].Node.Address()
+	})
+}
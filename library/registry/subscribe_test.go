@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type recordSubscriber struct {
+	events [][]string
+}
+
+func (r *recordSubscriber) on(events []NodeEvent) {
+	res := make([]string, 0, len(events))
+	for _, ev := range events {
+		res = append(res, ev.Type.String()+" "+ev.Node.Address())
+	}
+	r.events = append(r.events, res)
+}
+
+func TestSubscribers(t *testing.T) {
+	s := NewSubscribers()
+	s.Update([]servicer.Node{
+		servicer.NewNode("127.0.0.2", 80),
+		servicer.NewNode("127.0.0.1", 80),
+	})
+
+	// current nodes are pushed as add when subscribe
+	r := &recordSubscriber{}
+	unsubscribe := s.Subscribe(r.on)
+	assert.Equal(t, [][]string{{"add 127.0.0.1:80", "add 127.0.0.2:80"}}, r.events)
+
+	// nothing changed
+	s.Update([]servicer.Node{
+		servicer.NewNode("127.0.0.1", 80),
+		servicer.NewNode("127.0.0.2", 80),
+	})
+	assert.Equal(t, 1, len(r.events))
+
+	s.Update([]servicer.Node{
+		servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(10)),
+		servicer.NewNode("127.0.0.3", 80),
+	})
+	assert.Equal(t, []string{"update 127.0.0.1:80", "delete 127.0.0.2:80", "add 127.0.0.3:80"}, r.events[1])
+
+	s.Update([]servicer.Node{
+		servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(10), servicer.WithMeta(map[string]string{"lane": "blue"})),
+		servicer.NewNode("127.0.0.3", 80),
+	})
+	assert.Equal(t, []string{"update 127.0.0.1:80"}, r.events[2])
+
+	unsubscribe()
+	s.Update(nil)
+	assert.Equal(t, 3, len(r.events))
+
+	// subscribe with empty nodes is not pushed
+	r = &recordSubscriber{}
+	s.Subscribe(r.on)
+	assert.Equal(t, 0, len(r.events))
+}
@@ -0,0 +1,406 @@
+// xds is service discovery from the control plane by the aggregated discovery service of xDS,
+// the Cluster of service is watched by CDS and its endpoints by EDS.
+package xds
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/pkg/errors"
+	"github.com/why444216978/go-util/nopanic"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/air-go/rpc/library/app"
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/registry"
+)
+
+// The type urls of resources subscribed
+const (
+	TypeCluster  = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	TypeEndpoint = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+)
+
+type clientOptions struct {
+	logger        logger.Logger
+	node          *corev3.Node
+	retryInterval time.Duration
+}
+
+type ClientOption func(*clientOptions)
+
+func WithLogger(l logger.Logger) ClientOption {
+	return func(o *clientOptions) { o.logger = l }
+}
+
+// WithNode set the node identifying the client to control plane, default id is app name/local ip
+func WithNode(node *corev3.Node) ClientOption {
+	return func(o *clientOptions) { o.node = node }
+}
+
+// WithRetryInterval set the interval between reconnecting after the stream broken, default is 1s
+func WithRetryInterval(d time.Duration) ClientOption {
+	return func(o *clientOptions) { o.retryInterval = d }
+}
+
+// typeState is the subscription state of type in current stream
+type typeState struct {
+	version string   // the last accepted version, kept across streams
+	nonce   string   // the nonce of last response
+	names   []string // the resource names last requested
+}
+
+// cluster is the accepted Cluster, the endpoints are from EDS by edsName, or inline nodes of static cluster
+type cluster struct {
+	edsName string
+	nodes   []*registry.Node
+}
+
+// Client is the ADS client shared by discoveries, the responses are ACKed if accepted, otherwise NACKed
+// with the previous version kept. All resources of the stream are sent again after reconnected.
+type Client struct {
+	*clientOptions
+	setup.SetupLogger
+	ads         discoveryv3.AggregatedDiscoveryServiceClient
+	lock        sync.Mutex
+	stream      discoveryv3.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	states      map[string]*typeState
+	watches     map[string]*XDSDiscovery // cluster name to discovery
+	clusters    map[string]*cluster      // the accepted watched clusters
+	assignments map[string][]*registry.Node
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// NewClient start the stream to control plane in the background
+func NewClient(cc grpc.ClientConnInterface, opts ...ClientOption) (*Client, error) {
+	if cc == nil {
+		return nil, errors.New("cc is nil")
+	}
+
+	opt := &clientOptions{
+		node: &corev3.Node{
+			Id:       app.Name() + "/" + app.LocalIP(),
+			Cluster:  app.Name(),
+			Locality: &corev3.Locality{Zone: app.Zone()},
+		},
+		retryInterval: time.Second,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	c := &Client{
+		clientOptions: opt,
+		ads:           discoveryv3.NewAggregatedDiscoveryServiceClient(cc),
+		states: map[string]*typeState{
+			TypeCluster:  {},
+			TypeEndpoint: {},
+		},
+		watches:     make(map[string]*XDSDiscovery),
+		clusters:    make(map[string]*cluster),
+		assignments: make(map[string][]*registry.Node),
+		done:        make(chan struct{}),
+	}
+	c.SetupLogger.SetLogger(opt.logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.run(ctx)
+
+	return c, nil
+}
+
+// Close stop the stream and wait it exit, the discoveries keep the last nodes.
+func (c *Client) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+func (c *Client) run(ctx context.Context) {
+	go nopanic.GoVoid(ctx, func() {
+		defer close(c.done)
+
+		for {
+			err := c.runStream(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			c.AutoLogger().Warn(ctx, "xdsClientStreamErr", logger.Error(err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.retryInterval):
+			}
+		}
+	})
+}
+
+func (c *Client) runStream(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.ads.StreamAggregatedResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.stream = stream
+	// the nonce is scoped in stream, all names are requested again
+	for _, st := range c.states {
+		st.nonce, st.names = "", nil
+	}
+	err = c.subscribe()
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		c.stream = nil
+		c.lock.Unlock()
+	}()
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		updates, err := c.handle(ctx, resp)
+		for d, nodes := range updates {
+			d.update(nodes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle apply the response and send ACK or NACK, return the nodes updated of discoveries
+func (c *Client) handle(ctx context.Context, resp *discoveryv3.DiscoveryResponse) (map[*XDSDiscovery][]*registry.Node, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var (
+		updates map[*XDSDiscovery][]*registry.Node
+		err     error
+	)
+	switch resp.GetTypeUrl() {
+	case TypeCluster:
+		updates, err = c.handleClusters(resp)
+	case TypeEndpoint:
+		updates, err = c.handleEndpoints(resp)
+	default:
+		err = errors.Errorf("unexpected type %s", resp.GetTypeUrl())
+	}
+
+	st, ok := c.states[resp.GetTypeUrl()]
+	if !ok {
+		st = &typeState{}
+	}
+	st.nonce = resp.GetNonce()
+
+	req := &discoveryv3.DiscoveryRequest{
+		Node:          c.node,
+		TypeUrl:       resp.GetTypeUrl(),
+		ResourceNames: st.names,
+		ResponseNonce: st.nonce,
+	}
+	if err == nil {
+		st.version = resp.GetVersionInfo()
+	} else {
+		c.AutoLogger().Warn(ctx, "xdsClientNACK",
+			logger.Reflect("type", resp.GetTypeUrl()),
+			logger.Reflect("version", resp.GetVersionInfo()),
+			logger.Error(err),
+		)
+		req.ErrorDetail = &status.Status{Code: int32(codes.InvalidArgument), Message: err.Error()}
+	}
+	req.VersionInfo = st.version
+
+	if err = c.stream.Send(req); err != nil {
+		return updates, err
+	}
+	// the EDS names may be changed by clusters
+	return updates, c.subscribe()
+}
+
+// handleClusters must be called with lock, the clusters are state of the world, the watched one absent is removed.
+func (c *Client) handleClusters(resp *discoveryv3.DiscoveryResponse) (map[*XDSDiscovery][]*registry.Node, error) {
+	clusters := make(map[string]*cluster)
+	for _, res := range resp.GetResources() {
+		cl := &clusterv3.Cluster{}
+		if err := res.UnmarshalTo(cl); err != nil {
+			return nil, errors.Wrap(err, "unmarshal cluster")
+		}
+		if _, ok := c.watches[cl.GetName()]; !ok {
+			continue
+		}
+
+		switch {
+		case cl.GetType() == clusterv3.Cluster_EDS:
+			name := cl.GetEdsClusterConfig().GetServiceName()
+			if name == "" {
+				name = cl.GetName()
+			}
+			clusters[cl.GetName()] = &cluster{edsName: name}
+		case cl.GetLoadAssignment() != nil:
+			nodes, err := toNodes(cl.GetLoadAssignment())
+			if err != nil {
+				return nil, err
+			}
+			clusters[cl.GetName()] = &cluster{nodes: nodes}
+		default:
+			return nil, errors.Errorf("cluster %s: unsupported discovery type %s", cl.GetName(), cl.GetType())
+		}
+	}
+	c.clusters = clusters
+
+	updates := make(map[*XDSDiscovery][]*registry.Node)
+	for name, d := range c.watches {
+		cl, ok := clusters[name]
+		switch {
+		case !ok:
+			updates[d] = nil
+		case cl.edsName == "":
+			updates[d] = cl.nodes
+		default:
+			if nodes, ok := c.assignments[cl.edsName]; ok {
+				updates[d] = nodes
+			}
+		}
+	}
+	return updates, nil
+}
+
+// handleEndpoints must be called with lock, the assignment absent is not removed as EDS is not state of the world.
+func (c *Client) handleEndpoints(resp *discoveryv3.DiscoveryResponse) (map[*XDSDiscovery][]*registry.Node, error) {
+	assignments := make(map[string][]*registry.Node)
+	for _, res := range resp.GetResources() {
+		cla := &endpointv3.ClusterLoadAssignment{}
+		if err := res.UnmarshalTo(cla); err != nil {
+			return nil, errors.Wrap(err, "unmarshal cluster load assignment")
+		}
+		nodes, err := toNodes(cla)
+		if err != nil {
+			return nil, err
+		}
+		assignments[cla.GetClusterName()] = nodes
+	}
+
+	updates := make(map[*XDSDiscovery][]*registry.Node)
+	for name, nodes := range assignments {
+		c.assignments[name] = nodes
+		for clusterName, cl := range c.clusters {
+			if d, ok := c.watches[clusterName]; ok && cl.edsName == name {
+				updates[d] = nodes
+			}
+		}
+	}
+	return updates, nil
+}
+
+// subscribe must be called with lock, request the names changed of each type.
+// The empty names is not requested because it means all resources.
+func (c *Client) subscribe() error {
+	if c.stream == nil {
+		return nil
+	}
+
+	clusterNames := make([]string, 0, len(c.watches))
+	for name := range c.watches {
+		clusterNames = append(clusterNames, name)
+	}
+
+	edsNames := make([]string, 0, len(c.clusters))
+	seen := make(map[string]struct{})
+	for name, cl := range c.clusters {
+		if _, ok := c.watches[name]; !ok || cl.edsName == "" {
+			continue
+		}
+		if _, ok := seen[cl.edsName]; ok {
+			continue
+		}
+		seen[cl.edsName] = struct{}{}
+		edsNames = append(edsNames, cl.edsName)
+	}
+
+	for _, sub := range []struct {
+		typeURL string
+		names   []string
+	}{
+		{TypeCluster, clusterNames},
+		{TypeEndpoint, edsNames},
+	} {
+		st := c.states[sub.typeURL]
+		sort.Strings(sub.names)
+		if len(sub.names) == 0 || equal(sub.names, st.names) {
+			continue
+		}
+		st.names = sub.names
+
+		if err := c.stream.Send(&discoveryv3.DiscoveryRequest{
+			Node:          c.node,
+			TypeUrl:       sub.typeURL,
+			VersionInfo:   st.version,
+			ResourceNames: st.names,
+			ResponseNonce: st.nonce,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) watch(d *XDSDiscovery) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.watches[d.name]; ok {
+		return errors.Errorf("cluster %s already watched", d.name)
+	}
+	c.watches[d.name] = d
+
+	// the stream error is handled by the stream loop
+	_ = c.subscribe()
+	return nil
+}
+
+// unwatch stop delivering to discovery, the cluster is requested until the next names change.
+func (c *Client) unwatch(d *XDSDiscovery) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.watches[d.name] == d {
+		delete(c.watches, d.name)
+		delete(c.clusters, d.name)
+		_ = c.subscribe()
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
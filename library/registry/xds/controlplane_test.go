@@ -0,0 +1,157 @@
+package xds
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const testNodeID = "test"
+
+// controlPlane is the in-process go-control-plane server, the requests are recorded to check ACK and NACK.
+type controlPlane struct {
+	t        *testing.T
+	cache    cache.SnapshotCache
+	server   *grpc.Server
+	addr     string
+	lock     sync.Mutex
+	requests []*discoveryv3.DiscoveryRequest
+	onNACK   func()
+}
+
+func newControlPlane(t *testing.T) *controlPlane {
+	cp := &controlPlane{
+		t:     t,
+		cache: cache.NewSnapshotCache(false, cache.IDHash{}, nil),
+	}
+	cp.start("127.0.0.1:0")
+	t.Cleanup(cp.stop)
+	return cp
+}
+
+func (cp *controlPlane) start(addr string) {
+	lis, err := net.Listen("tcp", addr)
+	assert.Nil(cp.t, err)
+	cp.addr = lis.Addr().String()
+
+	srv := server.NewServer(context.Background(), cp.cache, server.CallbackFuncs{
+		StreamRequestFunc: func(_ int64, req *discoveryv3.DiscoveryRequest) error {
+			cp.lock.Lock()
+			cp.requests = append(cp.requests, req)
+			onNACK := cp.onNACK
+			if req.GetErrorDetail() != nil {
+				cp.onNACK = nil
+			}
+			cp.lock.Unlock()
+
+			if req.GetErrorDetail() != nil && onNACK != nil {
+				onNACK()
+			}
+			return nil
+		},
+	})
+	cp.server = grpc.NewServer()
+	discoveryv3.RegisterAggregatedDiscoveryServiceServer(cp.server, srv)
+	go func() { _ = cp.server.Serve(lis) }()
+}
+
+func (cp *controlPlane) stop() {
+	cp.server.Stop()
+}
+
+// restart break the streams and serve on the same address
+func (cp *controlPlane) restart() {
+	cp.server.Stop()
+	cp.start(cp.addr)
+}
+
+func (cp *controlPlane) set(version string, clusters []*clusterv3.Cluster, assignments []*endpointv3.ClusterLoadAssignment) {
+	resources := map[resource.Type][]types.Resource{
+		resource.ClusterType:  {},
+		resource.EndpointType: {},
+	}
+	for _, c := range clusters {
+		resources[resource.ClusterType] = append(resources[resource.ClusterType], c)
+	}
+	for _, a := range assignments {
+		resources[resource.EndpointType] = append(resources[resource.EndpointType], a)
+	}
+	snapshot, err := cache.NewSnapshot(version, resources)
+	assert.Nil(cp.t, err)
+	assert.Nil(cp.t, cp.cache.SetSnapshot(context.Background(), testNodeID, snapshot))
+}
+
+// find return the last request matched
+func (cp *controlPlane) find(f func(*discoveryv3.DiscoveryRequest) bool) *discoveryv3.DiscoveryRequest {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	for i := len(cp.requests) - 1; i >= 0; i-- {
+		if f(cp.requests[i]) {
+			return cp.requests[i]
+		}
+	}
+	return nil
+}
+
+func edsCluster(name, serviceName string) *clusterv3.Cluster {
+	return &clusterv3.Cluster{
+		Name:                 name,
+		ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_EDS},
+		EdsClusterConfig: &clusterv3.Cluster_EdsClusterConfig{
+			ServiceName: serviceName,
+			EdsConfig: &corev3.ConfigSource{
+				ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+			},
+		},
+	}
+}
+
+func staticCluster(name string, cla *endpointv3.ClusterLoadAssignment) *clusterv3.Cluster {
+	return &clusterv3.Cluster{
+		Name:                 name,
+		ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STATIC},
+		LoadAssignment:       cla,
+	}
+}
+
+func assignment(name string, localities ...*endpointv3.LocalityLbEndpoints) *endpointv3.ClusterLoadAssignment {
+	return &endpointv3.ClusterLoadAssignment{ClusterName: name, Endpoints: localities}
+}
+
+func locality(zone string, priority uint32, endpoints ...*endpointv3.LbEndpoint) *endpointv3.LocalityLbEndpoints {
+	return &endpointv3.LocalityLbEndpoints{
+		Locality:    &corev3.Locality{Region: "cn", Zone: zone},
+		Priority:    priority,
+		LbEndpoints: endpoints,
+	}
+}
+
+// endpoint of weight 0 has no weight set
+func endpoint(host string, port uint32, health corev3.HealthStatus, weight uint32) *endpointv3.LbEndpoint {
+	ep := &endpointv3.LbEndpoint{
+		HostIdentifier: &endpointv3.LbEndpoint_Endpoint{Endpoint: &endpointv3.Endpoint{
+			Address: &corev3.Address{Address: &corev3.Address_SocketAddress{SocketAddress: &corev3.SocketAddress{
+				Address:       host,
+				PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: port},
+			}}},
+		}},
+		HealthStatus: health,
+	}
+	if weight > 0 {
+		ep.LoadBalancingWeight = &wrapperspb.UInt32Value{Value: weight}
+	}
+	return ep
+}
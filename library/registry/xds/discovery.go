@@ -0,0 +1,117 @@
+package xds
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type options struct {
+	initTimeout time.Duration
+}
+
+type Option func(*options)
+
+// WithInitTimeout set the max wait of the first resource, default is 5s
+func WithInitTimeout(d time.Duration) Option {
+	return func(o *options) { o.initTimeout = d }
+}
+
+// XDSDiscovery is the nodes of Cluster from control plane
+type XDSDiscovery struct {
+	*options
+	cli         *Client
+	name        string
+	lock        sync.RWMutex
+	nodes       []*registry.Node
+	updateTime  time.Time
+	subscribers *registry.Subscribers
+	readyOnce   sync.Once
+	ready       chan struct{}
+}
+
+var _ registry.Discovery = (*XDSDiscovery)(nil)
+
+// NewDiscovery watch the Cluster named name, return error if the first resource not received in init timeout.
+// The Cluster absent from control plane has no nodes.
+func NewDiscovery(cli *Client, name string, opts ...Option) (*XDSDiscovery, error) {
+	if cli == nil {
+		return nil, errors.New("cli is nil")
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return nil, errors.New("serviceName is nil")
+	}
+
+	opt := &options{
+		initTimeout: 5 * time.Second,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	xd := &XDSDiscovery{
+		options:     opt,
+		cli:         cli,
+		name:        name,
+		subscribers: registry.NewSubscribers(),
+		ready:       make(chan struct{}),
+	}
+	if err := cli.watch(xd); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-xd.ready:
+	case <-time.After(opt.initTimeout):
+		cli.unwatch(xd)
+		return nil, errors.Errorf("cluster %s not received in %s", name, opt.initTimeout)
+	}
+
+	return xd, nil
+}
+
+func (xd *XDSDiscovery) GetNodes() []servicer.Node {
+	xd.lock.RLock()
+	defer xd.lock.RUnlock()
+
+	nodes := make([]servicer.Node, 0, len(xd.nodes))
+	for _, node := range xd.nodes {
+		nodes = append(nodes, servicer.NewNode(node.Host, node.Port,
+			servicer.WithWeight(node.Weight),
+			servicer.WithZone(node.Zone),
+			servicer.WithMeta(node.Meta)))
+	}
+	return nodes
+}
+
+func (xd *XDSDiscovery) GetUpdateTime() time.Time {
+	xd.lock.RLock()
+	defer xd.lock.RUnlock()
+	return xd.updateTime
+}
+
+func (xd *XDSDiscovery) Subscribe(f func(events []registry.NodeEvent)) (unsubscribe func()) {
+	return xd.subscribers.Subscribe(f)
+}
+
+// Close stop watching, the client is not closed as it is shared.
+func (xd *XDSDiscovery) Close() error {
+	xd.cli.unwatch(xd)
+	return nil
+}
+
+func (xd *XDSDiscovery) update(nodes []*registry.Node) {
+	xd.lock.Lock()
+	xd.nodes = nodes
+	xd.updateTime = time.Now()
+	xd.lock.Unlock()
+
+	xd.subscribers.Update(xd.GetNodes())
+	xd.readyOnce.Do(func() { close(xd.ready) })
+}
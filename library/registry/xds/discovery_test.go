@@ -0,0 +1,215 @@
+package xds
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func addresses(nodes []servicer.Node) []string {
+	res := []string{}
+	for _, n := range nodes {
+		res = append(res, n.Address())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func newTestClient(t *testing.T, cp *controlPlane) *Client {
+	cc, err := grpc.Dial(cp.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+
+	cli, err := NewClient(cc, WithNode(&corev3.Node{Id: testNodeID}), WithRetryInterval(time.Millisecond*10))
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func TestXDSDiscovery(t *testing.T) {
+	cp := newControlPlane(t)
+	static := assignment("static", locality("bj", 0, endpoint("10.0.1.1", 80, corev3.HealthStatus_HEALTHY, 0)))
+	cp.set("1",
+		[]*clusterv3.Cluster{edsCluster("user", "user-eds"), staticCluster("static", static)},
+		[]*endpointv3.ClusterLoadAssignment{assignment("user-eds",
+			locality("bj", 0,
+				endpoint("10.0.0.1", 80, corev3.HealthStatus_HEALTHY, 10),
+				endpoint("10.0.0.2", 80, corev3.HealthStatus_UNHEALTHY, 10),
+				endpoint("10.0.0.4", 80, corev3.HealthStatus_DRAINING, 10),
+			),
+			locality("sh", 1,
+				endpoint("10.0.0.3", 80, corev3.HealthStatus_UNKNOWN, 0),
+				// the endpoint in multiple localities is kept once
+				endpoint("10.0.0.1", 80, corev3.HealthStatus_HEALTHY, 10),
+			),
+		)},
+	)
+
+	_, err := NewClient(nil)
+	assert.NotNil(t, err)
+
+	cli := newTestClient(t, cp)
+	_, err = NewDiscovery(nil, "user")
+	assert.NotNil(t, err)
+	_, err = NewDiscovery(cli, " ")
+	assert.NotNil(t, err)
+
+	d, err := NewDiscovery(cli, "user")
+	assert.Nil(t, err)
+	defer d.Close()
+	_, err = NewDiscovery(cli, "user")
+	assert.NotNil(t, err)
+
+	nodes := d.GetNodes()
+	assert.Equal(t, []string{"10.0.0.1:80", "10.0.0.3:80"}, addresses(nodes))
+	assert.Equal(t, 10, nodes[0].Weight())
+	assert.Equal(t, "bj", nodes[0].Zone())
+	assert.Equal(t, map[string]string{MetaRegion: "cn", MetaZone: "bj", MetaPriority: "0"}, nodes[0].Meta())
+	assert.Equal(t, 1, nodes[1].Weight())
+	assert.Equal(t, map[string]string{MetaRegion: "cn", MetaZone: "sh", MetaPriority: "1"}, nodes[1].Meta())
+	assert.False(t, d.GetUpdateTime().IsZero())
+
+	sd, err := NewDiscovery(cli, "static")
+	assert.Nil(t, err)
+	defer sd.Close()
+	assert.Equal(t, []string{"10.0.1.1:80"}, addresses(sd.GetNodes()))
+
+	// the cluster not exist is not responded
+	_, err = NewDiscovery(cli, "none", WithInitTimeout(time.Millisecond*200))
+	assert.NotNil(t, err)
+
+	// ACK
+	for _, typeURL := range []string{TypeCluster, TypeEndpoint} {
+		typeURL := typeURL
+		assert.Eventually(t, func() bool {
+			return cp.find(func(req *discoveryv3.DiscoveryRequest) bool {
+				return req.GetTypeUrl() == typeURL && req.GetVersionInfo() == "1" && req.GetResponseNonce() != ""
+			}) != nil
+		}, time.Second, time.Millisecond*10)
+	}
+	assert.Nil(t, cp.find(func(req *discoveryv3.DiscoveryRequest) bool { return req.GetErrorDetail() != nil }))
+	assert.Equal(t, []string{"static", "user"}, cp.find(func(req *discoveryv3.DiscoveryRequest) bool {
+		return req.GetTypeUrl() == TypeCluster
+	}).GetResourceNames())
+	assert.Equal(t, []string{"user-eds"}, cp.find(func(req *discoveryv3.DiscoveryRequest) bool {
+		return req.GetTypeUrl() == TypeEndpoint
+	}).GetResourceNames())
+
+	var (
+		lock   sync.Mutex
+		events []string
+	)
+	d.Subscribe(func(evs []registry.NodeEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, ev := range evs {
+			events = append(events, ev.Type.String()+" "+ev.Node.Address())
+		}
+	})
+	getEvents := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, events...)
+	}
+
+	// the endpoints changed and the static cluster removed
+	cp.set("2",
+		[]*clusterv3.Cluster{edsCluster("user", "user-eds")},
+		[]*endpointv3.ClusterLoadAssignment{assignment("user-eds",
+			locality("bj", 0,
+				endpoint("10.0.0.1", 80, corev3.HealthStatus_HEALTHY, 10),
+				endpoint("10.0.0.2", 80, corev3.HealthStatus_HEALTHY, 10),
+			),
+		)},
+	)
+	assert.Eventually(t, func() bool {
+		return len(getEvents()) == 4
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, []string{"add 10.0.0.1:80", "add 10.0.0.3:80", "add 10.0.0.2:80", "delete 10.0.0.3:80"}, getEvents())
+	assert.Eventually(t, func() bool {
+		return len(sd.GetNodes()) == 0
+	}, time.Second*3, time.Millisecond*10)
+
+	// NACK the invalid endpoint and keep the nodes, the next valid version is accepted
+	cp.lock.Lock()
+	cp.onNACK = func() {
+		cp.set("4",
+			[]*clusterv3.Cluster{edsCluster("user", "user-eds")},
+			[]*endpointv3.ClusterLoadAssignment{assignment("user-eds",
+				locality("bj", 0, endpoint("10.0.0.5", 80, corev3.HealthStatus_HEALTHY, 10)),
+			)},
+		)
+	}
+	cp.lock.Unlock()
+	cp.set("3",
+		[]*clusterv3.Cluster{edsCluster("user", "user-eds")},
+		[]*endpointv3.ClusterLoadAssignment{assignment("user-eds",
+			locality("bj", 0, endpoint("10.0.0.1", 0, corev3.HealthStatus_HEALTHY, 10)),
+		)},
+	)
+	assert.Eventually(t, func() bool {
+		return cp.find(func(req *discoveryv3.DiscoveryRequest) bool { return req.GetErrorDetail() != nil }) != nil
+	}, time.Second*3, time.Millisecond*10)
+	nack := cp.find(func(req *discoveryv3.DiscoveryRequest) bool { return req.GetErrorDetail() != nil })
+	assert.Equal(t, TypeEndpoint, nack.GetTypeUrl())
+	assert.Equal(t, "2", nack.GetVersionInfo())
+	assert.NotEqual(t, "", nack.GetResponseNonce())
+	assert.Contains(t, nack.GetErrorDetail().GetMessage(), "user-eds")
+
+	assert.Eventually(t, func() bool {
+		return len(d.GetNodes()) == 1
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, []string{"10.0.0.5:80"}, addresses(d.GetNodes()))
+	assert.Equal(t, []string{
+		"add 10.0.0.1:80", "add 10.0.0.3:80", "add 10.0.0.2:80", "delete 10.0.0.3:80",
+		"delete 10.0.0.1:80", "delete 10.0.0.2:80", "add 10.0.0.5:80",
+	}, getEvents())
+}
+
+func TestXDSDiscovery_Reconnect(t *testing.T) {
+	cp := newControlPlane(t)
+	cp.set("1",
+		[]*clusterv3.Cluster{edsCluster("user", "")},
+		[]*endpointv3.ClusterLoadAssignment{assignment("user",
+			locality("bj", 0, endpoint("10.0.0.1", 80, corev3.HealthStatus_HEALTHY, 10)),
+		)},
+	)
+
+	cli := newTestClient(t, cp)
+	d, err := NewDiscovery(cli, "user")
+	assert.Nil(t, err)
+	defer d.Close()
+	assert.Equal(t, []string{"10.0.0.1:80"}, addresses(d.GetNodes()))
+
+	// the nodes are kept while the stream broken, and resumed from the accepted version after reconnected
+	cp.restart()
+	assert.Equal(t, []string{"10.0.0.1:80"}, addresses(d.GetNodes()))
+	assert.Eventually(t, func() bool {
+		return cp.find(func(req *discoveryv3.DiscoveryRequest) bool {
+			return req.GetTypeUrl() == TypeEndpoint && req.GetVersionInfo() == "1" && req.GetResponseNonce() == ""
+		}) != nil
+	}, time.Second*5, time.Millisecond*10)
+
+	cp.set("2",
+		[]*clusterv3.Cluster{edsCluster("user", "")},
+		[]*endpointv3.ClusterLoadAssignment{assignment("user",
+			locality("bj", 0, endpoint("10.0.0.2", 80, corev3.HealthStatus_HEALTHY, 10)),
+		)},
+	)
+	assert.Eventually(t, func() bool {
+		nodes := d.GetNodes()
+		return len(nodes) == 1 && nodes[0].Address() == "10.0.0.2:80"
+	}, time.Second*3, time.Millisecond*10)
+}
@@ -0,0 +1,82 @@
+package xds
+
+import (
+	"sort"
+	"strconv"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"github.com/pkg/errors"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// The locality and priority of endpoint carried in node meta, the zone is set to node zone too
+const (
+	MetaRegion   = "region"
+	MetaZone     = "zone"
+	MetaSubZone  = "sub_zone"
+	MetaPriority = "priority"
+)
+
+// toNodes map the healthy endpoints of assignment to nodes, the endpoint of unknown health is regarded as healthy.
+// The endpoint in multiple localities is kept once, error is returned if any endpoint is invalid so the assignment is rejected.
+func toNodes(cla *endpointv3.ClusterLoadAssignment) ([]*registry.Node, error) {
+	seen := make(map[string]struct{})
+	nodes := make([]*registry.Node, 0)
+	for _, locality := range cla.GetEndpoints() {
+		for _, lbEndpoint := range locality.GetLbEndpoints() {
+			socket := lbEndpoint.GetEndpoint().GetAddress().GetSocketAddress()
+			if socket.GetAddress() == "" || socket.GetPortValue() == 0 {
+				return nil, errors.Errorf("cluster %s: endpoint without socket address and port", cla.GetClusterName())
+			}
+
+			switch lbEndpoint.GetHealthStatus() {
+			case corev3.HealthStatus_HEALTHY, corev3.HealthStatus_UNKNOWN:
+			default:
+				continue
+			}
+
+			address := servicer.GenerateAddress(socket.GetAddress(), int(socket.GetPortValue()))
+			if _, ok := seen[address]; ok {
+				continue
+			}
+			seen[address] = struct{}{}
+
+			nodes = append(nodes, toNode(locality, lbEndpoint))
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return servicer.GenerateAddress(nodes[i].Host, nodes[i].Port) < servicer.GenerateAddress(nodes[j].Host, nodes[j].Port)
+	})
+	return nodes, nil
+}
+
+func toNode(locality *endpointv3.LocalityLbEndpoints, lbEndpoint *endpointv3.LbEndpoint) *registry.Node {
+	socket := lbEndpoint.GetEndpoint().GetAddress().GetSocketAddress()
+	node := &registry.Node{
+		Host:     socket.GetAddress(),
+		Port:     int(socket.GetPortValue()),
+		Weight:   1,
+		Priority: int(locality.GetPriority()),
+		Zone:     locality.GetLocality().GetZone(),
+		Meta: map[string]string{
+			MetaPriority: strconv.Itoa(int(locality.GetPriority())),
+		},
+	}
+	// the weight is equal if not set
+	if w := lbEndpoint.GetLoadBalancingWeight(); w != nil {
+		node.Weight = int(w.GetValue())
+	}
+	for k, v := range map[string]string{
+		MetaRegion:  locality.GetLocality().GetRegion(),
+		MetaZone:    locality.GetLocality().GetZone(),
+		MetaSubZone: locality.GetLocality().GetSubZone(),
+	} {
+		if v != "" {
+			node.Meta[k] = v
+		}
+	}
+	return node
+}
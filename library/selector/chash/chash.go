@@ -0,0 +1,270 @@
+// chash is Consistent Hashing with virtual nodes
+// the hash key is extracted from context, inject it by context.WithHashKey
+package chash
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+
+	lc "github.com/air-go/rpc/library/context"
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const (
+	defaultReplicas        = 100
+	defaultMaxVirtualNodes = 1000
+)
+
+// Hash maps bytes to uint32
+type Hash func(data []byte) uint32
+
+type Selector struct {
+	lock        sync.RWMutex
+	nodes       map[string]servicer.Node
+	list        []servicer.Node
+	ring        []uint32            // sorted virtual node hashes
+	owners      map[uint32][]string // virtual node hash to the sorted addresses hashed to it, the first owns it
+	replicas    int
+	maxVirtual  int
+	hash        Hash
+	serviceName string
+}
+
+var _ selector.Selector = (*Selector)(nil)
+
+type SelectorOption func(*Selector)
+
+// WithReplicas set the virtual node count of per weight
+func WithReplicas(replicas int) SelectorOption {
+	return func(s *Selector) { s.replicas = replicas }
+}
+
+// WithMaxVirtualNodes limit the virtual node count of per node, so a large weight does not blow up the ring,
+// the weight ratio is kept up to max/replicas, default is 1000, which keeps the ratio of weight 1 to 10.
+func WithMaxVirtualNodes(max int) SelectorOption {
+	return func(s *Selector) { s.maxVirtual = max }
+}
+
+// WithHash set the hash func, default crc32.ChecksumIEEE
+func WithHash(hash Hash) SelectorOption {
+	return func(s *Selector) { s.hash = hash }
+}
+
+func NewSelector(serviceName string, opts ...SelectorOption) *Selector {
+	s := &Selector{
+		nodes:       make(map[string]servicer.Node),
+		list:        make([]servicer.Node, 0),
+		ring:        make([]uint32, 0),
+		owners:      make(map[uint32][]string),
+		replicas:    defaultReplicas,
+		maxVirtual:  defaultMaxVirtualNodes,
+		hash:        crc32.ChecksumIEEE,
+		serviceName: serviceName,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	if s.replicas <= 0 {
+		s.replicas = defaultReplicas
+	}
+	if s.maxVirtual < s.replicas {
+		s.maxVirtual = s.replicas
+	}
+	if s.hash == nil {
+		s.hash = crc32.ChecksumIEEE
+	}
+
+	return s
+}
+
+func (s *Selector) ServiceName() string {
+	return s.serviceName
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; ok {
+		return
+	}
+
+	s.nodes[address] = node
+	s.list = append(s.list, node)
+
+	added := make([]uint32, 0)
+	for _, h := range s.virtualHashes(node) {
+		owners := s.owners[h]
+		if len(owners) == 0 {
+			added = append(added, h)
+		}
+		// the smallest address wins on collision, so the ring is independent of the order of adding and deleting
+		idx := sort.SearchStrings(owners, address)
+		if idx < len(owners) && owners[idx] == address {
+			continue
+		}
+		owners = append(owners, "")
+		copy(owners[idx+1:], owners[idx:])
+		owners[idx] = address
+		s.owners[h] = owners
+	}
+	s.ring = merge(s.ring, added)
+
+	return
+}
+
+// merge the unsorted added into the sorted ring, only the added are sorted
+func merge(ring, added []uint32) []uint32 {
+	if len(added) == 0 {
+		return ring
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i] < added[j] })
+
+	res := make([]uint32, 0, len(ring)+len(added))
+	i, j := 0, 0
+	for i < len(ring) && j < len(added) {
+		if ring[i] < added[j] {
+			res = append(res, ring[i])
+			i++
+		} else {
+			res = append(res, added[j])
+			j++
+		}
+	}
+	res = append(res, ring[i:]...)
+	return append(res, added[j:]...)
+}
+
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	old, ok := s.nodes[address]
+	if !ok {
+		return
+	}
+
+	delete(s.nodes, address)
+
+	for idx, n := range s.list {
+		if n.Address() != address {
+			continue
+		}
+		s.list = append(s.list[:idx], s.list[idx+1:]...)
+		break
+	}
+
+	// the hash is given back to the other owner if collided, and removed if no owner left
+	removed := false
+	for _, h := range s.virtualHashes(old) {
+		owners := s.owners[h]
+		idx := sort.SearchStrings(owners, address)
+		if idx == len(owners) || owners[idx] != address {
+			continue
+		}
+		if len(owners) == 1 {
+			delete(s.owners, h)
+			removed = true
+			continue
+		}
+		s.owners[h] = append(owners[:idx:idx], owners[idx+1:]...)
+	}
+	if !removed {
+		return
+	}
+
+	ring := make([]uint32, 0, len(s.ring))
+	for _, h := range s.ring {
+		if _, ok := s.owners[h]; ok {
+			ring = append(ring, h)
+		}
+	}
+	s.ring = ring
+
+	return
+}
+
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	nodes = make([]servicer.Node, len(s.list))
+	copy(nodes, s.list)
+
+	return nodes, nil
+}
+
+// Select pick the first virtual node clockwise from the hash of key,
+// random pick one if the key is absent from context.
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.list) == 0 {
+		return nil, errors.New("node is nil")
+	}
+
+	key := lc.ValueHashKey(ctx)
+	if key == "" {
+		return s.list[rand.Intn(len(s.list))], nil
+	}
+
+	h := s.hash([]byte(key))
+	idx := sort.Search(len(s.ring), func(i int) bool { return s.ring[i] >= h })
+	if idx == len(s.ring) {
+		idx = 0
+	}
+
+	return s.nodes[s.owners[s.ring[idx]][0]], nil
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	node, ok := s.nodes[info.Node.Address()]
+	if !ok {
+		return
+	}
+
+	if info.NotSent {
+		return
+	}
+	if info.Err != nil {
+		node.IncrFail()
+		return
+	}
+	node.IncrSuccess()
+}
+
+// virtualHashes node without weight is regarded as weight 1, the count is limited by max virtual nodes
+func (s *Selector) virtualHashes(node servicer.Node) []uint32 {
+	weight := node.Weight()
+	if weight <= 0 {
+		weight = 1
+	}
+
+	count := s.maxVirtual
+	if weight <= s.maxVirtual/s.replicas {
+		count = s.replicas * weight
+	}
+	hashes := make([]uint32, count)
+	for i := 0; i < count; i++ {
+		hashes[i] = s.hash([]byte(node.Address() + "#" + strconv.Itoa(i)))
+	}
+	return hashes
+}
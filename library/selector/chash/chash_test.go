@@ -0,0 +1,178 @@
+package chash
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	lc "github.com/air-go/rpc/library/context"
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func TestSelector_ServiceName(t *testing.T) {
+	s := NewSelector("test_service")
+	assert.Equal(t, "test_service", s.ServiceName())
+}
+
+func TestSelector_Select(t *testing.T) {
+	s := NewSelector("test_service")
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+
+	for i := 1; i <= 3; i++ {
+		_ = s.AddNode(servicer.NewNode("127.0.0."+strconv.Itoa(i), 80))
+	}
+
+	// without key
+	node, err = s.Select(context.Background())
+	assert.Nil(t, err)
+	assert.NotNil(t, node)
+
+	// same key same node
+	ctx := lc.WithHashKey(context.Background(), "user_1")
+	first, err := s.Select(ctx)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		node, _ := s.Select(ctx)
+		assert.Equal(t, first.Address(), node.Address())
+	}
+
+	// keys spread over all nodes
+	count := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		node, _ := s.Select(lc.WithHashKey(context.Background(), "user_"+strconv.Itoa(i)))
+		count[node.Address()]++
+	}
+	assert.Equal(t, 3, len(count))
+	for _, c := range count {
+		assert.Greater(t, c, 500)
+	}
+}
+
+func TestSelector_Affinity(t *testing.T) {
+	s := NewSelector("test_service")
+	nodes := []servicer.Node{}
+	for i := 1; i <= 5; i++ {
+		node := servicer.NewNode("127.0.0."+strconv.Itoa(i), 80)
+		nodes = append(nodes, node)
+		_ = s.AddNode(node)
+	}
+
+	before := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		key := "user_" + strconv.Itoa(i)
+		node, _ := s.Select(lc.WithHashKey(context.Background(), key))
+		before[key] = node.Address()
+	}
+
+	// only keys of deleted node move
+	del := nodes[2]
+	_ = s.DeleteNode(del)
+	for key, address := range before {
+		node, _ := s.Select(lc.WithHashKey(context.Background(), key))
+		if address != del.Address() {
+			assert.Equal(t, address, node.Address())
+			continue
+		}
+		assert.NotEqual(t, del.Address(), node.Address())
+	}
+
+	// keys come back after the node is added again
+	_ = s.AddNode(del)
+	for key, address := range before {
+		node, _ := s.Select(lc.WithHashKey(context.Background(), key))
+		assert.Equal(t, address, node.Address())
+	}
+
+	n, _ := s.GetNodes()
+	assert.Equal(t, 5, len(n))
+}
+
+func TestSelector_AfterHandle(t *testing.T) {
+	s := NewSelector("test_service", WithReplicas(10))
+	node := servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(2))
+	_ = s.AddNode(node)
+	_ = s.AddNode(node)
+	assert.Equal(t, 20, len(s.ring))
+
+	s.AfterHandle(selector.HandleInfo{Node: node})
+	s.AfterHandle(selector.HandleInfo{Node: node, Err: errors.New("error")})
+	s.AfterHandle(selector.HandleInfo{Node: servicer.NewNode("127.0.0.2", 80)})
+	s.AfterHandle(selector.HandleInfo{})
+	assert.Equal(t, servicer.Statistics{Success: 1, Fail: 1}, node.Statistics())
+
+	_ = s.DeleteNode(node)
+	_ = s.DeleteNode(node)
+	assert.Equal(t, 0, len(s.ring))
+	assert.Equal(t, 0, len(s.owners))
+}
+
+func TestSelector_Churn(t *testing.T) {
+	// the small hash space makes a lot of collisions
+	hash := func(data []byte) uint32 { return crc32.ChecksumIEEE(data) % 64 }
+	nodes := make([]servicer.Node, 0)
+	for i := 0; i < 10; i++ {
+		nodes = append(nodes, servicer.NewNode("127.0.0."+strconv.Itoa(i), 80))
+	}
+
+	s := NewSelector("test_service", WithReplicas(10), WithHash(hash))
+	for _, n := range nodes {
+		_ = s.AddNode(n)
+	}
+	for _, n := range nodes[:5] {
+		_ = s.DeleteNode(n)
+	}
+
+	// the fresh ring added in reverse order
+	fresh := NewSelector("test_service", WithReplicas(10), WithHash(hash))
+	for i := len(nodes) - 1; i >= 5; i-- {
+		_ = fresh.AddNode(nodes[i])
+	}
+	assert.Equal(t, fresh.ring, s.ring)
+	assert.Equal(t, fresh.owners, s.owners)
+
+	for i := 0; i < 100; i++ {
+		ctx := lc.WithHashKey(context.Background(), strconv.Itoa(i))
+		a, _ := s.Select(ctx)
+		b, _ := fresh.Select(ctx)
+		assert.Equal(t, b.Address(), a.Address())
+	}
+
+	for _, n := range nodes[5:] {
+		_ = s.DeleteNode(n)
+	}
+	assert.Equal(t, 0, len(s.ring))
+	assert.Equal(t, 0, len(s.owners))
+}
+
+func TestSelector_MaxVirtualNodes(t *testing.T) {
+	s := NewSelector("test_service")
+	_ = s.AddNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(100)))
+	assert.LessOrEqual(t, len(s.ring), defaultMaxVirtualNodes)
+
+	// the weight ratio is kept under the limit
+	s = NewSelector("test_service", WithReplicas(10), WithMaxVirtualNodes(40))
+	_ = s.AddNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(1)))
+	_ = s.AddNode(servicer.NewNode("127.0.0.2", 80, servicer.WithWeight(3)))
+	_ = s.AddNode(servicer.NewNode("127.0.0.3", 80, servicer.WithWeight(100)))
+	assert.Equal(t, 10, len(s.virtualHashes(s.nodes["127.0.0.1:80"])))
+	assert.Equal(t, 30, len(s.virtualHashes(s.nodes["127.0.0.2:80"])))
+	assert.Equal(t, 40, len(s.virtualHashes(s.nodes["127.0.0.3:80"])))
+
+	// the limit is at least replicas
+	s = NewSelector("test_service", WithReplicas(10), WithMaxVirtualNodes(1))
+	assert.Equal(t, 10, len(s.virtualHashes(servicer.NewNode("127.0.0.1", 80))))
+}
+
+func TestMerge(t *testing.T) {
+	assert.Equal(t, []uint32{1, 3}, merge([]uint32{1, 3}, nil))
+	assert.Equal(t, []uint32{1, 2, 3, 4, 5}, merge([]uint32{2, 4}, []uint32{5, 1, 3}))
+	assert.Equal(t, []uint32{1, 2}, merge(nil, []uint32{2, 1}))
+}
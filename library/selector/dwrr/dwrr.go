@@ -1,100 +1,252 @@
 // dwrr is Dynamic Weighted Round Robin
 package dwrr
 
-// import (
-// 	"math"
-// 	"sync"
-
-// 	"github.com/air-go/rpc/library/selector"
-// )
-
-// const defaultStep float64 = 0.1
-
-// type Node struct {
-// 	lock          sync.RWMutex
-// 	address       string
-// 	weight        float64
-// 	currentWeight float64
-// 	meta          selector.Meta
-// 	statistics    selector.Statistics
-// }
-
-// var _ selector.Node = (*Node)(nil)
-
-// func (n *Node) Address() string {
-// 	return n.address
-// }
-
-// func (n *Node) Meta() selector.Meta {
-// 	return n.meta
-// }
-
-// func (n *Node) Statistics() selector.Statistics {
-// 	n.lock.RLock()
-// 	defer n.lock.RUnlock()
-// 	return n.statistics
-// }
-
-// func (n *Node) Weight() float64 {
-// 	return n.weight
-// }
-
-// type Selector struct {
-// 	lock        sync.RWMutex
-// 	nodeCount   int
-// 	nodes       map[string]*Node
-// 	list        []*Node
-// 	step        float64
-// 	serviceName string
-// }
-
-// var _ selector.Selector = (*Selector)(nil)
-
-// type SelectorOption func(*Selector)
-
-// func WithServiceName(name string) SelectorOption {
-// 	return func(s *Selector) { s.serviceName = name }
-// }
-
-// func WithStep(step float64) SelectorOption {
-// 	return func(s *Selector) { s.step = step }
-// }
-
-// func NewSelector(opts ...SelectorOption) *Selector {
-// 	s := &Selector{
-// 		nodes: make(map[string]*Node),
-// 		list:  make([]*Node, 0),
-// 	}
-
-// 	for _, o := range opts {
-// 		o(s)
-// 	}
-
-// 	if s.step <= 0 {
-// 		s.step = defaultStep
-// 	}
-
-// 	return s
-// }
-
-// func (s *Selector) incrWeight(n *Node) {
-// 	n.lock.Lock()
-// 	defer n.lock.Unlock()
-
-// 	n.currentWeight = math.Trunc(n.currentWeight*(1+s.step)*1e2+0.5) * 1e-2
-
-// 	if n.currentWeight > n.weight {
-// 		n.currentWeight = n.weight
-// 	}
-
-// 	return
-// }
-
-// func (s *Selector) decreaseWeight(n *Node) {
-// 	n.lock.Lock()
-// 	defer n.lock.Unlock()
-
-// 	n.currentWeight = math.Trunc(n.currentWeight*(1-s.step)*1e2+0.5) * 1e-2
-
-// 	return
-// }
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const (
+	defaultStep float64 = 0.1
+	// minRatio is the lower limit of currentWeight/weight,
+	// make sure failed node can still be picked to recover.
+	minRatio float64 = 0.01
+)
+
+type dwrrNode struct {
+	node          servicer.Node
+	weight        float64 // registered weight
+	currentWeight float64 // dynamic weight, adjusted by handle result
+	smoothWeight  float64 // smooth weighted round robin state
+}
+
+type Selector struct {
+	lock        sync.Mutex
+	nodes       map[string]*dwrrNode
+	list        []*dwrrNode
+	step        float64
+	serviceName string
+	slowStart   *selector.SlowStart
+}
+
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
+
+type SelectorOption func(*Selector)
+
+// WithStep set the ratio of weight adjusted by each handle result, 0 < step <= 1
+func WithStep(step float64) SelectorOption {
+	return func(s *Selector) { s.step = step }
+}
+
+// WithSlowStart ramp the weight of new node from minRatio to full in window
+func WithSlowStart(window time.Duration, minRatio float64) SelectorOption {
+	return func(s *Selector) { s.slowStart = selector.NewSlowStart(window, minRatio) }
+}
+
+func NewSelector(serviceName string, opts ...SelectorOption) *Selector {
+	s := &Selector{
+		nodes:       make(map[string]*dwrrNode),
+		list:        make([]*dwrrNode, 0),
+		serviceName: serviceName,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	if s.step <= 0 || s.step > 1 {
+		s.step = defaultStep
+	}
+
+	return s
+}
+
+func (s *Selector) ServiceName() string {
+	return s.serviceName
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; ok {
+		return
+	}
+
+	weight := nodeWeight(node)
+	n := &dwrrNode{
+		node:          node,
+		weight:        weight,
+		currentWeight: weight,
+	}
+	s.nodes[address] = n
+	s.list = append(s.list, n)
+
+	s.resetSmoothWeight()
+
+	s.slowStart.Add(address)
+
+	return
+}
+
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; !ok {
+		return
+	}
+
+	delete(s.nodes, address)
+
+	for idx, n := range s.list {
+		if n.node.Address() != address {
+			continue
+		}
+		s.list = append(s.list[:idx], s.list[idx+1:]...)
+		break
+	}
+
+	s.resetSmoothWeight()
+
+	s.slowStart.Delete(address)
+
+	return
+}
+
+// UpdateNode replace the node and its weight,
+// the dynamic weight keeps its ratio to the registered weight, the slow start is kept.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[node.Address()]
+	if !ok {
+		return
+	}
+
+	weight := nodeWeight(node)
+	n.node = node
+	if n.weight != weight {
+		n.currentWeight = n.currentWeight / n.weight * weight
+		n.weight = weight
+		s.resetSmoothWeight()
+	}
+
+	return
+}
+
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nodes = make([]servicer.Node, len(s.list))
+	for idx, n := range s.list {
+		nodes[idx] = n.node
+	}
+
+	return nodes, nil
+}
+
+// Select is smooth weighted round robin by currentWeight.
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		now     = time.Now()
+		warming = s.slowStart.Warming(now)
+		best    *dwrrNode
+		total   float64
+	)
+	for _, n := range s.list {
+		weight := n.currentWeight
+		if warming {
+			weight = weight * s.slowStart.Factor(n.node.Address(), now)
+		}
+
+		n.smoothWeight = n.smoothWeight + weight
+		total = total + weight
+		if best == nil || n.smoothWeight > best.smoothWeight {
+			best = n
+		}
+	}
+
+	if best == nil {
+		return nil, errors.New("node is nil")
+	}
+
+	best.smoothWeight = best.smoothWeight - total
+
+	return best.node, nil
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[info.Node.Address()]
+	if !ok {
+		return
+	}
+
+	if info.NotSent {
+		return
+	}
+	if info.Err != nil {
+		n.node.IncrFail()
+		s.decreaseWeight(n)
+		return
+	}
+	n.node.IncrSuccess()
+	s.incrWeight(n)
+}
+
+// incrWeight recover currentWeight linearly until registered weight.
+func (s *Selector) incrWeight(n *dwrrNode) {
+	n.currentWeight = n.currentWeight + n.weight*s.step
+
+	if n.currentWeight > n.weight {
+		n.currentWeight = n.weight
+	}
+}
+
+// decreaseWeight reduce currentWeight exponentially until weight*minRatio.
+func (s *Selector) decreaseWeight(n *dwrrNode) {
+	n.currentWeight = n.currentWeight * (1 - s.step)
+
+	if min := n.weight * minRatio; n.currentWeight < min {
+		n.currentWeight = min
+	}
+}
+
+func (s *Selector) resetSmoothWeight() {
+	for _, n := range s.list {
+		n.smoothWeight = 0
+	}
+}
+
+// nodeWeight prefer FloatWeight, node without weight is regarded as weight 1
+func nodeWeight(node servicer.Node) float64 {
+	if w := node.FloatWeight(); w > 0 {
+		return w
+	}
+	if w := node.Weight(); w > 0 {
+		return float64(w)
+	}
+	return 1
+}
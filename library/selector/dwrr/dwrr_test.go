@@ -0,0 +1,173 @@
+package dwrr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func TestSelector_ServiceName(t *testing.T) {
+	s := NewSelector("test_service")
+	assert.Equal(t, "test_service", s.ServiceName())
+}
+
+func TestSelector_Select(t *testing.T) {
+	s := NewSelector("test_service")
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+
+	a := servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(2))
+	b := servicer.NewNode("127.0.0.2", 80, servicer.WithFloatWeight(1))
+	_ = s.AddNode(a)
+	_ = s.AddNode(b)
+
+	count := map[string]int{}
+	for i := 0; i < 300; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		count[node.Address()]++
+	}
+	assert.Equal(t, 200, count[a.Address()])
+	assert.Equal(t, 100, count[b.Address()])
+}
+
+func TestSelector_AfterHandle(t *testing.T) {
+	s := NewSelector("test_service", WithStep(0.5))
+
+	node := servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(10))
+	_ = s.AddNode(node)
+	n := s.nodes[node.Address()]
+
+	fail := selector.HandleInfo{Node: node, Err: errors.New("error")}
+	success := selector.HandleInfo{Node: node}
+
+	s.AfterHandle(fail)
+	assert.Equal(t, float64(5), n.currentWeight)
+	for i := 0; i < 20; i++ {
+		s.AfterHandle(fail)
+	}
+	assert.Equal(t, 10*minRatio, n.currentWeight)
+
+	s.AfterHandle(success)
+	assert.Equal(t, 10*minRatio+5, n.currentWeight)
+	s.AfterHandle(success)
+	s.AfterHandle(success)
+	assert.Equal(t, float64(10), n.currentWeight)
+
+	assert.Equal(t, servicer.Statistics{Success: 3, Fail: 21}, node.Statistics())
+
+	// unknown node is ignored
+	s.AfterHandle(selector.HandleInfo{Node: servicer.NewNode("127.0.0.2", 80)})
+	s.AfterHandle(selector.HandleInfo{})
+}
+
+func TestSelector_FailNode(t *testing.T) {
+	s := NewSelector("test_service")
+
+	good := servicer.NewNode("127.0.0.1", 80)
+	bad := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(good)
+	_ = s.AddNode(bad)
+
+	count := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		node, _ := s.Select(context.Background())
+		count[node.Address()]++
+
+		var err error
+		if node.Address() == bad.Address() {
+			err = errors.New("error")
+		}
+		s.AfterHandle(selector.HandleInfo{Node: node, Err: err})
+	}
+	assert.Greater(t, count[good.Address()], count[bad.Address()]*5)
+	assert.Greater(t, count[bad.Address()], 0)
+}
+
+func TestSelector_DeleteNode(t *testing.T) {
+	s := NewSelector("test_service")
+	nodes := []servicer.Node{
+		servicer.NewNode("127.0.0.1", 80),
+		servicer.NewNode("127.0.0.2", 80),
+		servicer.NewNode("127.0.0.3", 80),
+	}
+	for _, node := range nodes {
+		_ = s.AddNode(node)
+	}
+	_ = s.AddNode(nodes[0])
+
+	n, _ := s.GetNodes()
+	assert.Equal(t, 3, len(n))
+
+	_ = s.DeleteNode(nodes[0])
+	n, _ = s.GetNodes()
+	assert.Equal(t, 2, len(n))
+
+	for i := 0; i < 100; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		assert.NotEqual(t, nodes[0].Address(), node.Address())
+	}
+}
+
+func TestSelector_SlowStart(t *testing.T) {
+	window := time.Millisecond * 100
+	s := NewSelector("test_service", WithSlowStart(window, 0.1))
+
+	old := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(old)
+	time.Sleep(window + time.Millisecond*20)
+
+	// the new node only gets about minRatio/(1+minRatio) of traffic at the beginning
+	fresh := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(fresh)
+
+	count := 0
+	for i := 0; i < 1000; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		if node.Address() == fresh.Address() {
+			count++
+		}
+	}
+	assert.Greater(t, count, 0)
+	assert.Less(t, count, 300)
+
+	// full weight after window
+	time.Sleep(window)
+	count = 0
+	for i := 0; i < 1000; i++ {
+		node, _ := s.Select(context.Background())
+		if node.Address() == fresh.Address() {
+			count++
+		}
+	}
+	assert.Greater(t, count, 300)
+}
+
+func TestSelector_UpdateNode(t *testing.T) {
+	s := NewSelector("test_service", WithStep(0.5))
+	a := servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(2))
+	_ = s.AddNode(a)
+	s.AfterHandle(selector.HandleInfo{Node: a, Err: errors.New("err")})
+	assert.Equal(t, float64(1), s.nodes[a.Address()].currentWeight)
+
+	// the node not added is ignored
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.2", 80)))
+	assert.Equal(t, 1, len(s.list))
+
+	// the dynamic weight keeps its ratio
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(4))))
+	n := s.nodes[a.Address()]
+	assert.Equal(t, float64(4), n.weight)
+	assert.Equal(t, float64(2), n.currentWeight)
+	assert.Equal(t, 4, n.node.Weight())
+}
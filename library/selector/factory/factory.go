@@ -1,14 +1,51 @@
 package factory
 
 import (
+	"time"
+
 	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/selector/chash"
+	"github.com/air-go/rpc/library/selector/dwrr"
+	"github.com/air-go/rpc/library/selector/icmp"
+	"github.com/air-go/rpc/library/selector/p2c"
 	"github.com/air-go/rpc/library/selector/wr"
+	"github.com/air-go/rpc/library/selector/wrr"
 )
 
-func New(serviceName, t string) selector.Selector {
+type options struct {
+	slowStart         time.Duration
+	slowStartMinRatio float64
+}
+
+type Option func(*options)
+
+// WithSlowStart ramp the weight of new node in window, only for wr, wrr and dwrr
+func WithSlowStart(window time.Duration, minRatio float64) Option {
+	return func(o *options) {
+		o.slowStart = window
+		o.slowStartMinRatio = minRatio
+	}
+}
+
+func New(serviceName, t string, opts ...Option) selector.Selector {
+	opt := &options{}
+	for _, o := range opts {
+		o(opt)
+	}
+
 	switch t {
 	case selector.TypeWR:
-		return wr.NewSelector(serviceName)
+		return wr.NewSelector(serviceName, wr.WithSlowStart(opt.slowStart, opt.slowStartMinRatio))
+	case selector.TypeWrr:
+		return wrr.NewSelector(serviceName, wrr.WithSlowStart(opt.slowStart, opt.slowStartMinRatio))
+	case selector.TypeDwrr:
+		return dwrr.NewSelector(serviceName, dwrr.WithSlowStart(opt.slowStart, opt.slowStartMinRatio))
+	case selector.TypeP2C:
+		return p2c.NewSelector(serviceName)
+	case selector.TypeICMP:
+		return icmp.NewSelector(serviceName)
+	case selector.TypeHash:
+		return chash.NewSelector(serviceName)
 	}
-	return wr.NewSelector(serviceName)
+	return wr.NewSelector(serviceName, wr.WithSlowStart(opt.slowStart, opt.slowStartMinRatio))
 }
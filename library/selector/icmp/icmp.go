@@ -1,2 +1,337 @@
 // icmp is load balance by ping rtt
+// rtt is measured by tcp connect in the background, so raw socket privileges are not required.
 package icmp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/why444216978/go-util/nopanic"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const (
+	defaultInterval  = time.Second * 5
+	defaultTimeout   = time.Second
+	defaultJitter    = time.Second
+	defaultTolerance = time.Millisecond
+)
+
+// Prober measure the rtt of address, the return error means address unreachable.
+type Prober func(ctx context.Context, address string) error
+
+// TCPProber dial the address with tcp and close immediately.
+func TCPProber(ctx context.Context, address string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+type icmpNode struct {
+	node    servicer.Node
+	address string // probed by the goroutine, node is replaced by UpdateNode
+	lock    sync.RWMutex
+	rtt     time.Duration
+	probed  bool // at least one probe finished
+	failed  bool // last probe failed
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func (n *icmpNode) RTT() (rtt time.Duration, ok bool) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.rtt, n.probed && !n.failed
+}
+
+func (n *icmpNode) setRTT(rtt time.Duration, err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if err != nil {
+		n.failed = true
+		return
+	}
+
+	// smooth the rtt, avoid jitter of a single probe
+	if !n.probed || n.failed {
+		n.rtt = rtt
+	} else {
+		n.rtt = (n.rtt + rtt) / 2
+	}
+	n.probed = true
+	n.failed = false
+}
+
+type Selector struct {
+	lock        sync.RWMutex
+	nodes       map[string]*icmpNode
+	list        []*icmpNode
+	interval    time.Duration
+	timeout     time.Duration
+	jitter      time.Duration
+	tolerance   time.Duration
+	prober      Prober
+	serviceName string
+}
+
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
+
+type SelectorOption func(*Selector)
+
+// WithInterval set the interval between two probes of one node
+func WithInterval(d time.Duration) SelectorOption {
+	return func(s *Selector) { s.interval = d }
+}
+
+// WithTimeout set the timeout of one probe
+func WithTimeout(d time.Duration) SelectorOption {
+	return func(s *Selector) { s.timeout = d }
+}
+
+// WithJitter set the max random duration added to interval, avoid all nodes probe at the same time
+func WithJitter(d time.Duration) SelectorOption {
+	return func(s *Selector) { s.jitter = d }
+}
+
+// WithTolerance set the rtt range regarded as fastest, nodes in range are picked randomly
+func WithTolerance(d time.Duration) SelectorOption {
+	return func(s *Selector) { s.tolerance = d }
+}
+
+// WithProber set the prober, such as application ping
+func WithProber(p Prober) SelectorOption {
+	return func(s *Selector) { s.prober = p }
+}
+
+func NewSelector(serviceName string, opts ...SelectorOption) *Selector {
+	s := &Selector{
+		nodes:       make(map[string]*icmpNode),
+		list:        make([]*icmpNode, 0),
+		interval:    defaultInterval,
+		timeout:     defaultTimeout,
+		jitter:      defaultJitter,
+		tolerance:   defaultTolerance,
+		prober:      TCPProber,
+		serviceName: serviceName,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	if s.interval <= 0 {
+		s.interval = defaultInterval
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultTimeout
+	}
+	if s.prober == nil {
+		s.prober = TCPProber
+	}
+
+	return s
+}
+
+func (s *Selector) ServiceName() string {
+	return s.serviceName
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &icmpNode{
+		node:    node,
+		address: address,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	s.nodes[address] = n
+	s.list = append(s.list, n)
+
+	s.probe(ctx, n)
+
+	return
+}
+
+// DeleteNode stop the probe goroutine of node and wait it exit.
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+
+	address := node.Address()
+	n, ok := s.nodes[address]
+	if !ok {
+		s.lock.Unlock()
+		return
+	}
+
+	delete(s.nodes, address)
+
+	for idx, item := range s.list {
+		if item.node.Address() != address {
+			continue
+		}
+		s.list = append(s.list[:idx], s.list[idx+1:]...)
+		break
+	}
+
+	s.lock.Unlock()
+
+	n.cancel()
+	<-n.done
+
+	return
+}
+
+// Close stop all probe goroutines.
+func (s *Selector) Close() error {
+	nodes, _ := s.GetNodes()
+	for _, n := range nodes {
+		_ = s.DeleteNode(n)
+	}
+	return nil
+}
+
+// UpdateNode replace the node, the rtt and the probe goroutine are kept.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if n, ok := s.nodes[node.Address()]; ok {
+		n.node = node
+	}
+
+	return
+}
+
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	nodes = make([]servicer.Node, len(s.list))
+	for idx, n := range s.list {
+		nodes[idx] = n.node
+	}
+
+	return nodes, nil
+}
+
+// Select random pick one of the nodes whose rtt is within tolerance of the fastest,
+// if no node has been probed successfully, random pick one of all.
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.list) == 0 {
+		return nil, errors.New("node is nil")
+	}
+
+	var (
+		min  time.Duration = -1
+		rtts               = make([]time.Duration, len(s.list))
+	)
+	for idx, n := range s.list {
+		rtt, ok := n.RTT()
+		if !ok {
+			rtts[idx] = -1
+			continue
+		}
+		rtts[idx] = rtt
+		if min == -1 || rtt < min {
+			min = rtt
+		}
+	}
+
+	if min == -1 {
+		return s.list[rand.Intn(len(s.list))].node, nil
+	}
+
+	candidates := make([]servicer.Node, 0, len(s.list))
+	for idx, rtt := range rtts {
+		if rtt == -1 || rtt > min+s.tolerance {
+			continue
+		}
+		candidates = append(candidates, s.list[idx].node)
+	}
+
+	return candidates[rand.Intn(len(candidates))], nil
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	n, ok := s.nodes[info.Node.Address()]
+	if !ok {
+		return
+	}
+
+	if info.NotSent {
+		return
+	}
+	if info.Err != nil {
+		n.node.IncrFail()
+		return
+	}
+	n.node.IncrSuccess()
+}
+
+func (s *Selector) probe(ctx context.Context, n *icmpNode) {
+	go nopanic.GoVoid(ctx, func() {
+		defer close(n.done)
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				s.probeOnce(ctx, n)
+				timer.Reset(s.nextInterval())
+			}
+		}
+	})
+}
+
+func (s *Selector) probeOnce(ctx context.Context, n *icmpNode) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := s.prober(ctx, n.address)
+	if ctx.Err() == context.Canceled {
+		return
+	}
+	n.setRTT(time.Since(start), err)
+}
+
+func (s *Selector) nextInterval() time.Duration {
+	if s.jitter <= 0 {
+		return s.interval
+	}
+	return s.interval + time.Duration(rand.Int63n(int64(s.jitter)))
+}
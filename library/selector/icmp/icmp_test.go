@@ -0,0 +1,123 @@
+package icmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func stubProber(rtts map[string]time.Duration) Prober {
+	return func(ctx context.Context, address string) error {
+		rtt, ok := rtts[address]
+		if !ok {
+			return errors.New("unreachable")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rtt):
+			return nil
+		}
+	}
+}
+
+func TestSelector_ServiceName(t *testing.T) {
+	s := NewSelector("test_service")
+	assert.Equal(t, "test_service", s.ServiceName())
+}
+
+func TestSelector_Select(t *testing.T) {
+	fast := servicer.NewNode("127.0.0.1", 80)
+	slow := servicer.NewNode("127.0.0.2", 80)
+	down := servicer.NewNode("127.0.0.3", 80)
+
+	s := NewSelector("test_service",
+		WithInterval(time.Millisecond*10),
+		WithJitter(time.Millisecond),
+		WithTolerance(time.Millisecond*5),
+		WithProber(stubProber(map[string]time.Duration{
+			fast.Address(): time.Millisecond,
+			slow.Address(): time.Millisecond * 50,
+		})),
+	)
+	defer s.Close()
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+
+	_ = s.AddNode(fast)
+	_ = s.AddNode(slow)
+	_ = s.AddNode(down)
+	time.Sleep(time.Millisecond * 100)
+
+	for i := 0; i < 100; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, fast.Address(), node.Address())
+	}
+
+	s.AfterHandle(selector.HandleInfo{Node: fast})
+	s.AfterHandle(selector.HandleInfo{Node: fast, Err: errors.New("error")})
+	assert.Equal(t, servicer.Statistics{Success: 1, Fail: 1}, fast.Statistics())
+}
+
+func TestSelector_SelectWithoutRTT(t *testing.T) {
+	s := NewSelector("test_service", WithProber(stubProber(nil)))
+	defer s.Close()
+
+	node := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(node)
+	time.Sleep(time.Millisecond * 10)
+
+	picked, err := s.Select(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, node.Address(), picked.Address())
+}
+
+func TestSelector_DeleteNode(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	s := NewSelector("test_service", WithInterval(time.Millisecond), WithProber(stubProber(nil)))
+	nodes := []servicer.Node{
+		servicer.NewNode("127.0.0.1", 80),
+		servicer.NewNode("127.0.0.2", 80),
+	}
+	for _, node := range nodes {
+		_ = s.AddNode(node)
+	}
+	_ = s.AddNode(nodes[0])
+
+	n, _ := s.GetNodes()
+	assert.Equal(t, 2, len(n))
+
+	_ = s.DeleteNode(nodes[0])
+	_ = s.DeleteNode(nodes[0])
+	n, _ = s.GetNodes()
+	assert.Equal(t, 1, len(n))
+
+	_ = s.Close()
+	n, _ = s.GetNodes()
+	assert.Equal(t, 0, len(n))
+	assert.Equal(t, before, runtime.NumGoroutine())
+}
+
+func TestTCPProber(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	assert.Nil(t, TCPProber(context.Background(), l.Addr().String()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NotNil(t, TCPProber(ctx, l.Addr().String()))
+}
@@ -0,0 +1,263 @@
+// locality is zone aware load balance
+// nodes in the zone of caller are preferred, traffic spills over to other zones
+// only when the healthy share of local zone drops below threshold.
+package locality
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/why444216978/go-util/assert"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const (
+	defaultThreshold   = 0.7
+	defaultMaxFails    = 3
+	defaultFailTimeout = time.Second * 10
+)
+
+type health struct {
+	local    bool
+	fails    int
+	lastFail time.Time
+}
+
+type Selector struct {
+	lock        sync.RWMutex
+	zone        string
+	local       selector.Selector
+	remote      selector.Selector
+	health      map[string]*health
+	threshold   float64
+	maxFails    int
+	failTimeout time.Duration
+	serviceName string
+}
+
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
+
+type SelectorOption func(*Selector)
+
+// WithThreshold set the healthy share of local zone below which traffic spills over, 0 < threshold <= 1
+func WithThreshold(threshold float64) SelectorOption {
+	return func(s *Selector) { s.threshold = threshold }
+}
+
+// WithMaxFails set the consecutive failures after which node is regarded as unhealthy
+func WithMaxFails(maxFails int) SelectorOption {
+	return func(s *Selector) { s.maxFails = maxFails }
+}
+
+// WithFailTimeout set the duration node is regarded as unhealthy after the last failure
+func WithFailTimeout(d time.Duration) SelectorOption {
+	return func(s *Selector) { s.failTimeout = d }
+}
+
+// NewSelector zone is the zone of caller, newSelector creates the selectors used inside local and remote zones.
+func NewSelector(serviceName, zone string, newSelector func() selector.Selector, opts ...SelectorOption) (*Selector, error) {
+	if newSelector == nil {
+		return nil, errors.New("newSelector is nil")
+	}
+
+	s := &Selector{
+		zone:        zone,
+		local:       newSelector(),
+		remote:      newSelector(),
+		health:      make(map[string]*health),
+		threshold:   defaultThreshold,
+		maxFails:    defaultMaxFails,
+		failTimeout: defaultFailTimeout,
+		serviceName: serviceName,
+	}
+
+	if assert.IsNil(s.local) || assert.IsNil(s.remote) {
+		return nil, errors.New("newSelector return nil")
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	if s.threshold <= 0 || s.threshold > 1 {
+		s.threshold = defaultThreshold
+	}
+	if s.maxFails <= 0 {
+		s.maxFails = defaultMaxFails
+	}
+	if s.failTimeout <= 0 {
+		s.failTimeout = defaultFailTimeout
+	}
+
+	return s, nil
+}
+
+func (s *Selector) ServiceName() string {
+	return s.serviceName
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.health[address]; ok {
+		return
+	}
+
+	local := node.Zone() == s.zone
+	if err = s.inner(local).AddNode(node); err != nil {
+		return
+	}
+	s.health[address] = &health{local: local}
+
+	return
+}
+
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	h, ok := s.health[address]
+	if !ok {
+		return
+	}
+
+	if err = s.inner(h.local).DeleteNode(node); err != nil {
+		return
+	}
+	delete(s.health, address)
+
+	return
+}
+
+// UpdateNode replace the node, the health is kept,
+// the node is moved between local and remote selector if its zone changed.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	h, ok := s.health[address]
+	if !ok {
+		return
+	}
+
+	local := node.Zone() == s.zone
+	if local == h.local {
+		return selector.UpdateNode(s.inner(local), node)
+	}
+
+	if err = s.inner(h.local).DeleteNode(node); err != nil {
+		return
+	}
+	if err = s.inner(local).AddNode(node); err != nil {
+		delete(s.health, address)
+		return
+	}
+	h.local = local
+
+	return
+}
+
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	local, err := s.local.GetNodes()
+	if err != nil {
+		return
+	}
+	remote, err := s.remote.GetNodes()
+	if err != nil {
+		return
+	}
+
+	return append(local, remote...), nil
+}
+
+// Select all traffic goes to local zone when its healthy share reaches threshold,
+// otherwise the traffic to local zone is in proportion to share/threshold.
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	s.lock.RLock()
+	var (
+		now                        = time.Now()
+		localTotal, localHealthy   int
+		remoteTotal, remoteHealthy int
+	)
+	for _, h := range s.health {
+		healthy := h.fails < s.maxFails || now.Sub(h.lastFail) >= s.failTimeout
+		switch {
+		case h.local:
+			localTotal++
+			if healthy {
+				localHealthy++
+			}
+		default:
+			remoteTotal++
+			if healthy {
+				remoteHealthy++
+			}
+		}
+	}
+	s.lock.RUnlock()
+
+	if localTotal == 0 {
+		return s.remote.Select(ctx)
+	}
+	if remoteTotal == 0 || remoteHealthy == 0 {
+		return s.local.Select(ctx)
+	}
+
+	share := float64(localHealthy) / float64(localTotal)
+	if share >= s.threshold || rand.Float64() < share/s.threshold {
+		return s.local.Select(ctx)
+	}
+	return s.remote.Select(ctx)
+}
+
+// Close close the selectors of local and remote zones
+func (s *Selector) Close() error {
+	err := selector.Close(s.local)
+	if e := selector.Close(s.remote); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.lock.Lock()
+	h, ok := s.health[info.Node.Address()]
+	if ok && !info.NotSent {
+		if info.Err != nil {
+			h.fails++
+			h.lastFail = time.Now()
+		} else {
+			h.fails = 0
+		}
+	}
+	s.lock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	s.inner(h.local).AfterHandle(info)
+}
+
+func (s *Selector) inner(local bool) selector.Selector {
+	if local {
+		return s.local
+	}
+	return s.remote
+}
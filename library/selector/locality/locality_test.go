@@ -0,0 +1,170 @@
+package locality
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/selector/wr"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func newWR() selector.Selector {
+	return wr.NewSelector("test_service")
+}
+
+func TestNewSelector(t *testing.T) {
+	s, err := NewSelector("test_service", "bj", nil)
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+
+	s, err = NewSelector("test_service", "bj", func() selector.Selector { return nil })
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+
+	s, err = NewSelector("test_service", "bj", newWR)
+	assert.Nil(t, err)
+	assert.Equal(t, "test_service", s.ServiceName())
+}
+
+func TestSelector_Select(t *testing.T) {
+	s, _ := NewSelector("test_service", "bj", newWR, WithThreshold(0.5), WithMaxFails(1), WithFailTimeout(time.Hour))
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+
+	local1 := servicer.NewNode("127.0.0.1", 80, servicer.WithZone("bj"))
+	local2 := servicer.NewNode("127.0.0.2", 80, servicer.WithZone("bj"))
+	remote := servicer.NewNode("127.0.0.3", 80, servicer.WithZone("sh"))
+
+	// only remote
+	_ = s.AddNode(remote)
+	node, err = s.Select(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, remote.Address(), node.Address())
+
+	// prefer local
+	_ = s.AddNode(local1)
+	_ = s.AddNode(local2)
+	_ = s.AddNode(local2)
+	nodes, _ := s.GetNodes()
+	assert.Equal(t, 3, len(nodes))
+	for i := 0; i < 100; i++ {
+		node, _ := s.Select(context.Background())
+		assert.NotEqual(t, remote.Address(), node.Address())
+	}
+
+	// share 0.5 still local
+	s.AfterHandle(selector.HandleInfo{Node: local1, Err: errors.New("error")})
+	for i := 0; i < 100; i++ {
+		node, _ := s.Select(context.Background())
+		assert.NotEqual(t, remote.Address(), node.Address())
+	}
+
+	// share 0 spill over
+	s.AfterHandle(selector.HandleInfo{Node: local2, Err: errors.New("error")})
+	for i := 0; i < 100; i++ {
+		node, _ := s.Select(context.Background())
+		assert.Equal(t, remote.Address(), node.Address())
+	}
+
+	// recover
+	s.AfterHandle(selector.HandleInfo{Node: local1})
+	s.AfterHandle(selector.HandleInfo{Node: local2})
+	for i := 0; i < 100; i++ {
+		node, _ := s.Select(context.Background())
+		assert.NotEqual(t, remote.Address(), node.Address())
+	}
+	assert.Equal(t, servicer.Statistics{Success: 1, Fail: 1}, local1.Statistics())
+}
+
+func TestSelector_Spill(t *testing.T) {
+	s, _ := NewSelector("test_service", "bj", newWR, WithThreshold(1), WithMaxFails(1), WithFailTimeout(time.Hour))
+
+	local1 := servicer.NewNode("127.0.0.1", 80, servicer.WithZone("bj"))
+	local2 := servicer.NewNode("127.0.0.2", 80, servicer.WithZone("bj"))
+	remote := servicer.NewNode("127.0.0.3", 80, servicer.WithZone("sh"))
+	_ = s.AddNode(local1)
+	_ = s.AddNode(local2)
+	_ = s.AddNode(remote)
+
+	// half of local unhealthy, about half of traffic spills over
+	s.AfterHandle(selector.HandleInfo{Node: local1, Err: errors.New("error")})
+	count := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		node, _ := s.Select(context.Background())
+		count[node.Address()]++
+	}
+	assert.InDelta(t, 500, count[remote.Address()], 100)
+}
+
+func TestSelector_FailTimeout(t *testing.T) {
+	s, _ := NewSelector("test_service", "bj", newWR, WithMaxFails(1), WithFailTimeout(time.Millisecond*10))
+
+	local := servicer.NewNode("127.0.0.1", 80, servicer.WithZone("bj"))
+	remote := servicer.NewNode("127.0.0.2", 80, servicer.WithZone("sh"))
+	_ = s.AddNode(local)
+	_ = s.AddNode(remote)
+
+	s.AfterHandle(selector.HandleInfo{Node: local, Err: errors.New("error")})
+	node, _ := s.Select(context.Background())
+	assert.Equal(t, remote.Address(), node.Address())
+
+	time.Sleep(time.Millisecond * 20)
+	node, _ = s.Select(context.Background())
+	assert.Equal(t, local.Address(), node.Address())
+}
+
+func TestSelector_DeleteNode(t *testing.T) {
+	s, _ := NewSelector("test_service", "bj", newWR)
+
+	local := servicer.NewNode("127.0.0.1", 80, servicer.WithZone("bj"))
+	remote := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(local)
+	_ = s.AddNode(remote)
+
+	_ = s.DeleteNode(local)
+	_ = s.DeleteNode(local)
+	nodes, _ := s.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+
+	node, _ := s.Select(context.Background())
+	assert.Equal(t, remote.Address(), node.Address())
+
+	// feedback of deleted node is ignored
+	s.AfterHandle(selector.HandleInfo{Node: local})
+	s.AfterHandle(selector.HandleInfo{})
+	assert.Equal(t, servicer.Statistics{}, local.Statistics())
+}
+
+func TestSelector_UpdateNode(t *testing.T) {
+	s, _ := NewSelector("test_service", "bj", newWR, WithMaxFails(1), WithFailTimeout(time.Hour))
+
+	node := servicer.NewNode("127.0.0.1", 80, servicer.WithZone("bj"))
+	_ = s.AddNode(node)
+	s.AfterHandle(selector.HandleInfo{Node: node, Err: errors.New("error")})
+
+	// the node not added is ignored
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.2", 80)))
+	nodes, _ := s.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+
+	// same zone
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithZone("bj"), servicer.WithWeight(3))))
+	nodes, _ = s.local.GetNodes()
+	assert.Equal(t, 3, nodes[0].Weight())
+
+	// moved to remote, the health is kept
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithZone("sh"))))
+	nodes, _ = s.local.GetNodes()
+	assert.Equal(t, 0, len(nodes))
+	nodes, _ = s.remote.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+	assert.False(t, s.health[node.Address()].local)
+	assert.Equal(t, 1, s.health[node.Address()].fails)
+}
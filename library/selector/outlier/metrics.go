@@ -0,0 +1,25 @@
+package outlier
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Registration is required before use.
+// metrics.Register(EjectCollector, EjectedCollector)
+var (
+	EjectCollector = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "selector_outlier",
+			Name:      "eject_count",
+			Help:      "node ejection count",
+		},
+		[]string{"service_name", "node", "reason"},
+	)
+
+	EjectedCollector = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "selector_outlier",
+			Name:      "ejected",
+			Help:      "current ejected node count",
+		},
+		[]string{"service_name"},
+	)
+)
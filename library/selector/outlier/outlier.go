@@ -0,0 +1,372 @@
+// outlier is passive outlier detection, it wraps any selector
+// node is ejected from the inner selector after consecutive failures or high error rate,
+// and returns after an exponentially growing cooldown.
+package outlier
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/why444216978/go-util/assert"
+
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/setup"
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const (
+	ReasonConsecutive = "consecutive_failures"
+	ReasonErrorRate   = "error_rate"
+)
+
+type options struct {
+	logger              logger.Logger
+	consecutiveFailures int
+	errorRate           float64
+	minRequests         int
+	window              time.Duration
+	baseEjectionTime    time.Duration
+	maxEjectionTime     time.Duration
+	maxEjectionPercent  int
+}
+
+func defaultOptions() *options {
+	return &options{
+		consecutiveFailures: 5,
+		errorRate:           0.5,
+		minRequests:         10,
+		window:              time.Second * 10,
+		baseEjectionTime:    time.Second * 30,
+		maxEjectionTime:     time.Minute * 5,
+		maxEjectionPercent:  10,
+	}
+}
+
+type SelectorOption func(*options)
+
+func WithLogger(l logger.Logger) SelectorOption {
+	return func(o *options) { o.logger = l }
+}
+
+// WithConsecutiveFailures set the consecutive failures to eject node, 0 means disable
+func WithConsecutiveFailures(n int) SelectorOption {
+	return func(o *options) { o.consecutiveFailures = n }
+}
+
+// WithErrorRate set the error rate in window to eject node, 0 means disable
+func WithErrorRate(rate float64, minRequests int) SelectorOption {
+	return func(o *options) {
+		o.errorRate = rate
+		o.minRequests = minRequests
+	}
+}
+
+// WithWindow set the sliding window of error rate
+func WithWindow(d time.Duration) SelectorOption {
+	return func(o *options) { o.window = d }
+}
+
+// WithEjectionTime set the first cooldown and the max cooldown,
+// the cooldown doubles each time the node is ejected again.
+func WithEjectionTime(base, max time.Duration) SelectorOption {
+	return func(o *options) {
+		o.baseEjectionTime = base
+		o.maxEjectionTime = max
+	}
+}
+
+// WithMaxEjectionPercent set the max percent of nodes can be ejected, one node can always be ejected
+func WithMaxEjectionPercent(percent int) SelectorOption {
+	return func(o *options) { o.maxEjectionPercent = percent }
+}
+
+type outlierNode struct {
+	node         servicer.Node
+	consecutive  int
+	window       *window
+	ejected      bool
+	ejectedUntil time.Time
+	ejectCount   int
+	returnTime   time.Time
+}
+
+type Selector struct {
+	*options
+	setup.SetupLogger
+	lock    sync.Mutex
+	inner   selector.Selector
+	nodes   map[string]*outlierNode
+	list    []*outlierNode
+	ejected int
+}
+
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
+
+func NewSelector(inner selector.Selector, opts ...SelectorOption) (*Selector, error) {
+	if assert.IsNil(inner) {
+		return nil, errors.New("inner selector is nil")
+	}
+
+	opt := defaultOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+
+	if opt.window <= 0 {
+		opt.window = defaultOptions().window
+	}
+	if opt.baseEjectionTime <= 0 {
+		opt.baseEjectionTime = defaultOptions().baseEjectionTime
+	}
+	if opt.maxEjectionTime < opt.baseEjectionTime {
+		opt.maxEjectionTime = opt.baseEjectionTime
+	}
+
+	s := &Selector{
+		options: opt,
+		inner:   inner,
+		nodes:   make(map[string]*outlierNode),
+		list:    make([]*outlierNode, 0),
+	}
+
+	s.SetupLogger.SetLogger(opt.logger)
+
+	return s, nil
+}
+
+func (s *Selector) ServiceName() string {
+	return s.inner.ServiceName()
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; ok {
+		return
+	}
+
+	if err = s.inner.AddNode(node); err != nil {
+		return
+	}
+
+	n := &outlierNode{
+		node:   node,
+		window: newWindow(s.window),
+	}
+	s.nodes[address] = n
+	s.list = append(s.list, n)
+
+	return
+}
+
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	n, ok := s.nodes[address]
+	if !ok {
+		return
+	}
+
+	if n.ejected {
+		s.ejected--
+		EjectedCollector.WithLabelValues(s.ServiceName()).Set(float64(s.ejected))
+	} else if err = s.inner.DeleteNode(node); err != nil {
+		return
+	}
+
+	delete(s.nodes, address)
+
+	for idx, item := range s.list {
+		if item.node.Address() != address {
+			continue
+		}
+		s.list = append(s.list[:idx], s.list[idx+1:]...)
+		break
+	}
+
+	return
+}
+
+// UpdateNode replace the node, the failures and ejection are kept,
+// the ejected node is replaced in the inner selector when it returns.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[node.Address()]
+	if !ok {
+		return
+	}
+
+	if !n.ejected {
+		if err = selector.UpdateNode(s.inner, node); err != nil {
+			return
+		}
+	}
+	n.node = node
+
+	return
+}
+
+// GetNodes return all nodes include ejected.
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nodes = make([]servicer.Node, len(s.list))
+	for idx, n := range s.list {
+		nodes[idx] = n.node
+	}
+
+	return nodes, nil
+}
+
+// Select return the nodes whose cooldown is over to the inner selector, then select by it.
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	s.lock.Lock()
+	if s.ejected > 0 {
+		s.recover(ctx, time.Now())
+	}
+	s.lock.Unlock()
+
+	return s.inner.Select(ctx)
+}
+
+// Close close the inner selector
+func (s *Selector) Close() error {
+	return selector.Close(s.inner)
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.inner.AfterHandle(info)
+	if info.NotSent {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[info.Node.Address()]
+	if !ok || n.ejected {
+		return
+	}
+
+	now := time.Now()
+	n.window.add(now, info.Err != nil)
+	if info.Err == nil {
+		n.consecutive = 0
+		return
+	}
+	n.consecutive++
+
+	if reason := s.check(n, now); reason != "" {
+		s.eject(context.Background(), n, now, reason)
+	}
+}
+
+// check return the reason if node should be ejected
+func (s *Selector) check(n *outlierNode, now time.Time) string {
+	if s.consecutiveFailures > 0 && n.consecutive >= s.consecutiveFailures {
+		return ReasonConsecutive
+	}
+
+	if s.errorRate > 0 {
+		total, fail := n.window.sum(now)
+		if total > 0 && total >= s.minRequests && float64(fail)/float64(total) >= s.errorRate {
+			return ReasonErrorRate
+		}
+	}
+
+	return ""
+}
+
+func (s *Selector) eject(ctx context.Context, n *outlierNode, now time.Time, reason string) {
+	// never eject the last node in rotation, at least one node can be ejected as envoy,
+	// otherwise the default 10% would eject nothing from less than 10 nodes.
+	if s.ejected+1 >= len(s.list) || (s.ejected > 0 && (s.ejected+1)*100 > s.maxEjectionPercent*len(s.list)) {
+		return
+	}
+
+	if err := s.inner.DeleteNode(n.node); err != nil {
+		s.AutoLogger().Error(ctx, "outlierEjectErr",
+			logger.Reflect(logger.ServiceName, s.ServiceName()),
+			logger.Reflect(logger.ServerIP, n.node.Host()),
+			logger.Reflect(logger.ServerPort, n.node.Port()),
+			logger.Error(err),
+		)
+		return
+	}
+
+	// the node has kept healthy long enough since last return, forget the history
+	if !n.returnTime.IsZero() && now.Sub(n.returnTime) > s.maxEjectionTime {
+		n.ejectCount = 0
+	}
+	n.ejectCount++
+
+	cooldown := time.Duration(float64(s.baseEjectionTime) * math.Pow(2, float64(n.ejectCount-1)))
+	if cooldown > s.maxEjectionTime || cooldown <= 0 {
+		cooldown = s.maxEjectionTime
+	}
+
+	n.ejected = true
+	n.ejectedUntil = now.Add(cooldown)
+	s.ejected++
+
+	EjectCollector.WithLabelValues(s.ServiceName(), n.node.Address(), reason).Inc()
+	EjectedCollector.WithLabelValues(s.ServiceName()).Set(float64(s.ejected))
+
+	s.AutoLogger().Warn(ctx, "outlierEject",
+		logger.Reflect(logger.ServiceName, s.ServiceName()),
+		logger.Reflect(logger.ServerIP, n.node.Host()),
+		logger.Reflect(logger.ServerPort, n.node.Port()),
+		logger.Reflect("reason", reason),
+		logger.Reflect("cooldown", cooldown.String()),
+	)
+}
+
+func (s *Selector) recover(ctx context.Context, now time.Time) {
+	for _, n := range s.list {
+		if !n.ejected || now.Before(n.ejectedUntil) {
+			continue
+		}
+
+		if err := s.inner.AddNode(n.node); err != nil {
+			s.AutoLogger().Error(ctx, "outlierReturnErr",
+				logger.Reflect(logger.ServiceName, s.ServiceName()),
+				logger.Reflect(logger.ServerIP, n.node.Host()),
+				logger.Reflect(logger.ServerPort, n.node.Port()),
+				logger.Error(err),
+			)
+			continue
+		}
+
+		n.ejected = false
+		n.returnTime = now
+		n.consecutive = 0
+		n.window.reset()
+		s.ejected--
+
+		EjectedCollector.WithLabelValues(s.ServiceName()).Set(float64(s.ejected))
+
+		s.AutoLogger().Info(ctx, "outlierReturn",
+			logger.Reflect(logger.ServiceName, s.ServiceName()),
+			logger.Reflect(logger.ServerIP, n.node.Host()),
+			logger.Reflect(logger.ServerPort, n.node.Port()),
+		)
+	}
+}
@@ -0,0 +1,288 @@
+package outlier
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/logger/nop"
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/selector/wrr"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+var errTest = errors.New("error")
+
+func newNodes(count int) []servicer.Node {
+	nodes := make([]servicer.Node, count)
+	for i := 0; i < count; i++ {
+		nodes[i] = servicer.NewNode("127.0.0."+strconv.Itoa(i+1), 80)
+	}
+	return nodes
+}
+
+func TestNewSelector(t *testing.T) {
+	s, err := NewSelector(nil)
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+
+	s, err = NewSelector(wrr.NewSelector("test_service"), WithLogger(nop.Logger))
+	assert.Nil(t, err)
+	assert.Equal(t, "test_service", s.ServiceName())
+}
+
+func TestSelector_Consecutive(t *testing.T) {
+	s, _ := NewSelector(wrr.NewSelector("test_consecutive"),
+		WithConsecutiveFailures(3),
+		WithErrorRate(0, 0),
+		WithMaxEjectionPercent(50),
+		WithEjectionTime(time.Millisecond*20, time.Second),
+	)
+	nodes := newNodes(2)
+	for _, n := range nodes {
+		_ = s.AddNode(n)
+	}
+	ejectCount := EjectCollector.WithLabelValues("test_consecutive", nodes[0].Address(), ReasonConsecutive)
+	before := testutil.ToFloat64(ejectCount)
+
+	// success resets consecutive
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0]})
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	assert.False(t, s.nodes[nodes[0].Address()].ejected)
+
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	assert.True(t, s.nodes[nodes[0].Address()].ejected)
+	assert.Equal(t, float64(1), testutil.ToFloat64(EjectedCollector.WithLabelValues("test_consecutive")))
+	assert.Equal(t, before+1, testutil.ToFloat64(ejectCount))
+
+	// ejected node is still returned by GetNodes
+	all, _ := s.GetNodes()
+	assert.Equal(t, 2, len(all))
+
+	for i := 0; i < 10; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, nodes[1].Address(), node.Address())
+	}
+
+	// max ejection percent
+	for i := 0; i < 3; i++ {
+		s.AfterHandle(selector.HandleInfo{Node: nodes[1], Err: errTest})
+	}
+	assert.False(t, s.nodes[nodes[1].Address()].ejected)
+
+	// return after cooldown
+	time.Sleep(time.Millisecond * 30)
+	count := map[string]int{}
+	for i := 0; i < 10; i++ {
+		node, _ := s.Select(context.Background())
+		count[node.Address()]++
+	}
+	assert.Equal(t, 5, count[nodes[0].Address()])
+	assert.Equal(t, float64(0), testutil.ToFloat64(EjectedCollector.WithLabelValues("test_consecutive")))
+}
+
+func TestSelector_ErrorRate(t *testing.T) {
+	s, _ := NewSelector(wrr.NewSelector("test_error_rate"),
+		WithConsecutiveFailures(0),
+		WithErrorRate(0.5, 10),
+		WithWindow(time.Second),
+		WithMaxEjectionPercent(100),
+	)
+	nodes := newNodes(2)
+	for _, n := range nodes {
+		_ = s.AddNode(n)
+	}
+
+	for i := 0; i < 9; i++ {
+		var err error
+		if i%2 == 0 {
+			err = errTest
+		}
+		s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: err})
+	}
+	// min requests not reached
+	assert.False(t, s.nodes[nodes[0].Address()].ejected)
+
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	assert.True(t, s.nodes[nodes[0].Address()].ejected)
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, nodes[1].Address(), node.Address())
+}
+
+func TestSelector_Cooldown(t *testing.T) {
+	s, _ := NewSelector(wrr.NewSelector("test_cooldown"),
+		WithConsecutiveFailures(1),
+		WithMaxEjectionPercent(100),
+		WithEjectionTime(time.Second, time.Second*3),
+	)
+	nodes := newNodes(2)
+	for _, n := range nodes {
+		_ = s.AddNode(n)
+	}
+	n := s.nodes[nodes[0].Address()]
+
+	expect := []time.Duration{time.Second, time.Second * 2, time.Second * 3, time.Second * 3}
+	for _, cooldown := range expect {
+		now := time.Now()
+		s.eject(context.Background(), n, now, ReasonConsecutive)
+		assert.Equal(t, now.Add(cooldown), n.ejectedUntil)
+		s.recover(context.Background(), n.ejectedUntil)
+		assert.False(t, n.ejected)
+	}
+
+	// healthy long enough, forget the history
+	n.returnTime = time.Now().Add(-time.Second * 4)
+	now := time.Now()
+	s.eject(context.Background(), n, now, ReasonConsecutive)
+	assert.Equal(t, now.Add(time.Second), n.ejectedUntil)
+}
+
+func TestSelector_SingleNode(t *testing.T) {
+	s, _ := NewSelector(wrr.NewSelector("test_single"), WithConsecutiveFailures(1), WithMaxEjectionPercent(100))
+	nodes := newNodes(1)
+	_ = s.AddNode(nodes[0])
+
+	// the last node in rotation is never ejected
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	assert.False(t, s.nodes[nodes[0].Address()].ejected)
+	assert.Equal(t, 0, s.ejected)
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, nodes[0].Address(), node.Address())
+}
+
+func TestSelector_TwoNodes(t *testing.T) {
+	nodes := newNodes(2)
+
+	// 0%, 10%, 50% and 100% of 2 nodes eject only one
+	for _, percent := range []int{0, 10, 50, 100} {
+		s, _ := NewSelector(wrr.NewSelector("test_two"), WithConsecutiveFailures(1), WithMaxEjectionPercent(percent))
+		for _, n := range nodes {
+			_ = s.AddNode(n)
+		}
+		s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+		s.AfterHandle(selector.HandleInfo{Node: nodes[1], Err: errTest})
+		assert.Equal(t, 1, s.ejected)
+		assert.True(t, s.nodes[nodes[0].Address()].ejected)
+		assert.False(t, s.nodes[nodes[1].Address()].ejected)
+	}
+}
+
+func TestSelector_DefaultOptions(t *testing.T) {
+	s, _ := NewSelector(wrr.NewSelector("test_default"))
+	nodes := newNodes(3)
+	for _, n := range nodes {
+		_ = s.AddNode(n)
+	}
+
+	// the default 10% of 3 nodes still ejects one after 5 consecutive failures
+	for i := 0; i < 4; i++ {
+		s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	}
+	assert.Equal(t, 0, s.ejected)
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	assert.Equal(t, 1, s.ejected)
+	assert.True(t, s.nodes[nodes[0].Address()].ejected)
+
+	// but not the second
+	for i := 0; i < 5; i++ {
+		s.AfterHandle(selector.HandleInfo{Node: nodes[1], Err: errTest})
+	}
+	assert.Equal(t, 1, s.ejected)
+	assert.False(t, s.nodes[nodes[1].Address()].ejected)
+}
+
+func TestSelector_DeleteNode(t *testing.T) {
+	s, _ := NewSelector(wrr.NewSelector("test_delete"), WithConsecutiveFailures(1), WithMaxEjectionPercent(100))
+	nodes := newNodes(2)
+	for _, n := range nodes {
+		_ = s.AddNode(n)
+		_ = s.AddNode(n)
+	}
+
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	assert.Equal(t, 1, s.ejected)
+
+	_ = s.DeleteNode(nodes[0])
+	_ = s.DeleteNode(nodes[1])
+	_ = s.DeleteNode(nodes[1])
+	assert.Equal(t, 0, s.ejected)
+
+	all, _ := s.GetNodes()
+	assert.Equal(t, 0, len(all))
+
+	// feedback of deleted node is ignored
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	s.AfterHandle(selector.HandleInfo{})
+}
+
+func TestWindow(t *testing.T) {
+	w := newWindow(time.Second)
+	now := time.Now()
+
+	w.add(now, true)
+	w.add(now, false)
+	total, fail := w.sum(now)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 1, fail)
+
+	// expired
+	total, fail = w.sum(now.Add(time.Second))
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 0, fail)
+
+	w.add(now.Add(time.Second), false)
+	total, _ = w.sum(now.Add(time.Second))
+	assert.Equal(t, 1, total)
+
+	w.reset()
+	total, _ = w.sum(now.Add(time.Second))
+	assert.Equal(t, 0, total)
+}
+
+func TestSelector_UpdateNode(t *testing.T) {
+	inner := wrr.NewSelector("test_update")
+	s, _ := NewSelector(inner, WithConsecutiveFailures(1), WithMaxEjectionPercent(50), WithEjectionTime(time.Second, time.Second))
+	nodes := newNodes(2)
+	for _, n := range nodes {
+		_ = s.AddNode(n)
+	}
+
+	// the failures are kept
+	s.consecutiveFailures = 2
+	s.AfterHandle(selector.HandleInfo{Node: nodes[1], Err: errTest})
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.2", 80, servicer.WithWeight(3))))
+	assert.Equal(t, 1, s.nodes[nodes[1].Address()].consecutive)
+	innerNodes, _ := inner.GetNodes()
+	assert.Equal(t, 3, innerNodes[1].Weight())
+	s.consecutiveFailures = 1
+
+	// the ejected node is still ejected, and replaced when it returns
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0], Err: errTest})
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(5))))
+	assert.True(t, s.nodes[nodes[0].Address()].ejected)
+	innerNodes, _ = inner.GetNodes()
+	assert.Equal(t, 1, len(innerNodes))
+
+	s.recover(context.Background(), time.Now().Add(time.Second))
+	innerNodes, _ = inner.GetNodes()
+	assert.Equal(t, 2, len(innerNodes))
+	assert.Equal(t, 5, innerNodes[1].Weight())
+
+	// the node not added is ignored
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.3", 80)))
+	all, _ := s.GetNodes()
+	assert.Equal(t, 2, len(all))
+}
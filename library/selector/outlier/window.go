@@ -0,0 +1,54 @@
+package outlier
+
+import "time"
+
+const bucketCount = 10
+
+type bucket struct {
+	start int64 // bucket start unix nano
+	total int
+	fail  int
+}
+
+// window is sliding window of request results, made up of bucketCount buckets.
+type window struct {
+	width   int64 // bucket width in nanosecond
+	buckets [bucketCount]bucket
+}
+
+func newWindow(d time.Duration) *window {
+	width := int64(d) / bucketCount
+	if width <= 0 {
+		width = 1
+	}
+	return &window{width: width}
+}
+
+func (w *window) add(now time.Time, fail bool) {
+	start := now.UnixNano() / w.width * w.width
+	b := &w.buckets[start/w.width%bucketCount]
+	if b.start != start {
+		*b = bucket{start: start}
+	}
+
+	b.total++
+	if fail {
+		b.fail++
+	}
+}
+
+func (w *window) sum(now time.Time) (total, fail int) {
+	earliest := now.UnixNano()/w.width*w.width - w.width*(bucketCount-1)
+	for _, b := range w.buckets {
+		if b.start < earliest {
+			continue
+		}
+		total += b.total
+		fail += b.fail
+	}
+	return
+}
+
+func (w *window) reset() {
+	w.buckets = [bucketCount]bucket{}
+}
@@ -1,2 +1,281 @@
 // p2c is reference https://exceting.github.io/2020/08/13/%E8%B4%9F%E8%BD%BD%E5%9D%87%E8%A1%A1-P2C%E7%AE%97%E6%B3%95/
 package p2c
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+const (
+	// defaultDecay is the time constant of ewma
+	defaultDecay = time.Second * 10
+	// defaultForcePick is the max duration a node can be left unpicked
+	defaultForcePick = time.Second * 3
+	// penalty is the latency of node without any response, make sure it to be tried
+	penalty = int64(time.Second * 10)
+)
+
+type p2cNode struct {
+	node servicer.Node
+
+	lock     sync.Mutex
+	lag      float64 // ewma latency in nanosecond
+	success  float64 // ewma success rate, 0~1
+	inflight int64
+	stamp    time.Time // last feedback time
+	pick     time.Time // last pick time
+	received bool
+}
+
+// load is the cost of node, the lower the better
+func (n *p2cNode) load() float64 {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	lag := n.lag
+	if !n.received && n.inflight > 0 {
+		lag = float64(penalty)
+	}
+
+	success := n.success
+	if success < 0.001 {
+		success = 0.001
+	}
+
+	return math.Sqrt(lag+1) * float64(n.inflight+1) / success
+}
+
+type Selector struct {
+	lock        sync.RWMutex
+	nodes       map[string]*p2cNode
+	list        []*p2cNode
+	decay       time.Duration
+	forcePick   time.Duration
+	serviceName string
+	rand        *rand.Rand
+}
+
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
+
+type SelectorOption func(*Selector)
+
+// WithDecay set the time constant of latency and success rate ewma
+func WithDecay(d time.Duration) SelectorOption {
+	return func(s *Selector) { s.decay = d }
+}
+
+// WithForcePick set the max duration a node can be left unpicked
+func WithForcePick(d time.Duration) SelectorOption {
+	return func(s *Selector) { s.forcePick = d }
+}
+
+func NewSelector(serviceName string, opts ...SelectorOption) *Selector {
+	s := &Selector{
+		nodes:       make(map[string]*p2cNode),
+		list:        make([]*p2cNode, 0),
+		decay:       defaultDecay,
+		forcePick:   defaultForcePick,
+		serviceName: serviceName,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	if s.decay <= 0 {
+		s.decay = defaultDecay
+	}
+
+	return s
+}
+
+func (s *Selector) ServiceName() string {
+	return s.serviceName
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; ok {
+		return
+	}
+
+	now := time.Now()
+	n := &p2cNode{
+		node:    node,
+		success: 1,
+		stamp:   now,
+		pick:    now,
+	}
+	s.nodes[address] = n
+	s.list = append(s.list, n)
+
+	return
+}
+
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; !ok {
+		return
+	}
+
+	delete(s.nodes, address)
+
+	for idx, n := range s.list {
+		if n.node.Address() != address {
+			continue
+		}
+		s.list = append(s.list[:idx], s.list[idx+1:]...)
+		break
+	}
+
+	return
+}
+
+// UpdateNode replace the node, the latency and success rate are kept.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[node.Address()]
+	if !ok {
+		return
+	}
+
+	n.lock.Lock()
+	n.node = node
+	n.lock.Unlock()
+
+	return
+}
+
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	nodes = make([]servicer.Node, len(s.list))
+	for idx, n := range s.list {
+		nodes[idx] = n.node
+	}
+
+	return nodes, nil
+}
+
+// Select random pick two nodes and choose the one with lower load,
+// the other one will be chosen if it has not been picked for forcePick.
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	// rand and list need to be protected together
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch len(s.list) {
+	case 0:
+		return nil, errors.New("node is nil")
+	case 1:
+		return s.pick(s.list[0]), nil
+	}
+
+	a := s.rand.Intn(len(s.list))
+	b := s.rand.Intn(len(s.list) - 1)
+	if b >= a {
+		b = b + 1
+	}
+
+	picked, unpicked := s.list[a], s.list[b]
+	if picked.load() > unpicked.load() {
+		picked, unpicked = unpicked, picked
+	}
+	if s.forcePick > 0 && unpicked.lastPick().Add(s.forcePick).Before(time.Now()) {
+		picked = unpicked
+	}
+
+	return s.pick(picked), nil
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.lock.RLock()
+	n, ok := s.nodes[info.Node.Address()]
+	s.lock.RUnlock()
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.inflight > 0 {
+		n.inflight = n.inflight - 1
+	}
+	if info.NotSent {
+		return
+	}
+
+	if info.Err != nil {
+		n.node.IncrFail()
+	} else {
+		n.node.IncrSuccess()
+	}
+
+	// the longer since last feedback, the less the history affects
+	td := now.Sub(n.stamp)
+	if td < 0 {
+		td = 0
+	}
+	w := math.Exp(-float64(td) / float64(s.decay))
+	n.stamp = now
+
+	lag := float64(info.Cost)
+	if lag < 0 {
+		lag = 0
+	}
+	if !n.received {
+		n.lag = lag
+		n.received = true
+	} else {
+		n.lag = n.lag*w + lag*(1-w)
+	}
+
+	success := 1.0
+	if info.Err != nil {
+		success = 0
+	}
+	n.success = n.success*w + success*(1-w)
+}
+
+func (s *Selector) pick(n *p2cNode) servicer.Node {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.inflight = n.inflight + 1
+	n.pick = time.Now()
+
+	return n.node
+}
+
+func (n *p2cNode) lastPick() time.Time {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.pick
+}
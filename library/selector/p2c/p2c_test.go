@@ -0,0 +1,198 @@
+package p2c
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func TestSelector_ServiceName(t *testing.T) {
+	s := NewSelector("test_service")
+	assert.Equal(t, "test_service", s.ServiceName())
+}
+
+func TestSelector_Select(t *testing.T) {
+	s := NewSelector("test_service")
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+
+	only := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(only)
+	node, err = s.Select(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, only.Address(), node.Address())
+	s.AfterHandle(selector.HandleInfo{Node: node, Cost: time.Millisecond})
+	assert.Equal(t, servicer.Statistics{Success: 1}, only.Statistics())
+}
+
+func TestSelector_SlowNode(t *testing.T) {
+	s := NewSelector("test_service", WithForcePick(time.Hour))
+
+	fast := servicer.NewNode("127.0.0.1", 80)
+	slow := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(fast)
+	_ = s.AddNode(slow)
+
+	count := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		count[node.Address()]++
+
+		cost := time.Millisecond
+		if node.Address() == slow.Address() {
+			cost = time.Millisecond * 100
+		}
+		s.AfterHandle(selector.HandleInfo{Node: node, Cost: cost})
+	}
+
+	assert.Greater(t, count[fast.Address()], count[slow.Address()]*5)
+}
+
+func TestSelector_ErrorNode(t *testing.T) {
+	s := NewSelector("test_service", WithForcePick(time.Hour), WithDecay(time.Millisecond))
+
+	good := servicer.NewNode("127.0.0.1", 80)
+	bad := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(good)
+	_ = s.AddNode(bad)
+
+	count := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		node, _ := s.Select(context.Background())
+		count[node.Address()]++
+
+		var err error
+		if node.Address() == bad.Address() {
+			err = errors.New("error")
+		}
+		time.Sleep(time.Microsecond * 10)
+		s.AfterHandle(selector.HandleInfo{Node: node, Err: err, Cost: time.Millisecond})
+	}
+
+	assert.Greater(t, count[good.Address()], count[bad.Address()]*5)
+}
+
+func TestSelector_Inflight(t *testing.T) {
+	s := NewSelector("test_service", WithForcePick(time.Hour))
+
+	a := servicer.NewNode("127.0.0.1", 80)
+	b := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(a)
+	_ = s.AddNode(b)
+
+	// requests never finish, picks should spread evenly by inflight
+	count := map[string]int{}
+	for i := 0; i < 100; i++ {
+		node, _ := s.Select(context.Background())
+		count[node.Address()]++
+	}
+	assert.InDelta(t, count[a.Address()], count[b.Address()], 2)
+}
+
+func TestSelector_NotSent(t *testing.T) {
+	s := NewSelector("test_service")
+	a := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(a)
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), s.nodes[a.Address()].inflight)
+
+	// the inflight is released, the result is not counted
+	s.AfterHandle(selector.HandleInfo{Node: node, NotSent: true})
+	n := s.nodes[a.Address()]
+	assert.Equal(t, int64(0), n.inflight)
+	assert.False(t, n.received)
+	assert.Equal(t, uint64(0), n.node.Statistics().Fail)
+	assert.Equal(t, uint64(0), n.node.Statistics().Success)
+}
+
+func TestSelector_DeleteNode(t *testing.T) {
+	s := NewSelector("test_service")
+	nodes := []servicer.Node{
+		servicer.NewNode("127.0.0.1", 80),
+		servicer.NewNode("127.0.0.2", 80),
+		servicer.NewNode("127.0.0.3", 80),
+	}
+	for _, node := range nodes {
+		_ = s.AddNode(node)
+	}
+	_ = s.AddNode(nodes[0])
+
+	n, _ := s.GetNodes()
+	assert.Equal(t, 3, len(n))
+
+	_ = s.DeleteNode(nodes[0])
+	n, _ = s.GetNodes()
+	assert.Equal(t, 2, len(n))
+
+	for i := 0; i < 100; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		assert.NotEqual(t, nodes[0].Address(), node.Address())
+	}
+
+	// feedback of deleted node is ignored
+	s.AfterHandle(selector.HandleInfo{Node: nodes[0]})
+	assert.Equal(t, servicer.Statistics{}, nodes[0].Statistics())
+}
+
+func TestSelector_Concurrent(t *testing.T) {
+	s := NewSelector("test_service")
+	nodes := []servicer.Node{
+		servicer.NewNode("127.0.0.1", 80),
+		servicer.NewNode("127.0.0.2", 80),
+		servicer.NewNode("127.0.0.3", 80),
+	}
+	_ = s.AddNode(nodes[0])
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				node, err := s.Select(context.Background())
+				assert.Nil(t, err)
+				s.AfterHandle(selector.HandleInfo{Node: node, Cost: time.Millisecond})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = s.AddNode(nodes[1+j%2])
+				_ = s.DeleteNode(nodes[1+j%2])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSelector_UpdateNode(t *testing.T) {
+	s := NewSelector("test_service")
+	a := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(a)
+	node, _ := s.Select(context.Background())
+	s.AfterHandle(selector.HandleInfo{Node: node, Cost: time.Millisecond * 10, Err: errors.New("err")})
+	load := s.nodes[a.Address()].load()
+
+	// the node not added is ignored
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.2", 80)))
+	assert.Equal(t, 1, len(s.list))
+
+	// latency and success rate are kept
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(10))))
+	assert.Equal(t, load, s.nodes[a.Address()].load())
+	node, _ = s.Select(context.Background())
+	assert.Equal(t, 10, node.Weight())
+}
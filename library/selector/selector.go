@@ -1,6 +1,10 @@
 package selector
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"github.com/air-go/rpc/library/servicer"
 )
 
@@ -10,11 +14,16 @@ const (
 	TypeDwrr = "dwrr"
 	TypeP2C  = "p2c"
 	TypeICMP = "icmp"
+	TypeHash = "chash"
 )
 
 type HandleInfo struct {
 	Node servicer.Node
 	Err  error
+	Cost time.Duration
+	// NotSent means the request failed before sent to Node, the selector only release the node,
+	// such as the inflight count, and neither success nor failure is counted.
+	NotSent bool
 }
 
 type Selector interface {
@@ -22,6 +31,46 @@ type Selector interface {
 	AddNode(node servicer.Node) (err error)
 	DeleteNode(node servicer.Node) (err error)
 	GetNodes() (nodes []servicer.Node, err error)
-	Select() (node servicer.Node, err error)
+	Select(ctx context.Context) (node servicer.Node, err error)
 	AfterHandle(info HandleInfo)
 }
+
+// Updater is implemented by the selector which can replace a node by the one with the same address in place,
+// the runtime state of the address, such as slow start, latency and ejection, is kept.
+// The node not added is ignored.
+type Updater interface {
+	UpdateNode(node servicer.Node) (err error)
+}
+
+// UpdateNode replace the node in s by UpdateNode if s is Updater,
+// otherwise by DeleteNode and AddNode, which reset the runtime state of the node.
+func UpdateNode(s Selector, node servicer.Node) (err error) {
+	if u, ok := s.(Updater); ok {
+		return u.UpdateNode(node)
+	}
+
+	nodes, err := s.GetNodes()
+	if err != nil {
+		return
+	}
+	for _, n := range nodes {
+		if n.Address() != node.Address() {
+			continue
+		}
+		if err = s.DeleteNode(n); err != nil {
+			return
+		}
+		return s.AddNode(node)
+	}
+
+	return
+}
+
+// Close close s if it is io.Closer, such as icmp which stops probing,
+// the selector wrapping others close the inner ones by it too.
+func Close(s Selector) error {
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
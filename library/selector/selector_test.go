@@ -0,0 +1,85 @@
+package selector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// listSelector is a Selector without UpdateNode, records the calls.
+type listSelector struct {
+	nodes []servicer.Node
+	calls []string
+}
+
+func (s *listSelector) ServiceName() string { return "test_service" }
+
+func (s *listSelector) AddNode(node servicer.Node) error {
+	s.calls = append(s.calls, "add")
+	s.nodes = append(s.nodes, node)
+	return nil
+}
+
+func (s *listSelector) DeleteNode(node servicer.Node) error {
+	s.calls = append(s.calls, "delete")
+	for idx, n := range s.nodes {
+		if n.Address() == node.Address() {
+			s.nodes = append(s.nodes[:idx], s.nodes[idx+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *listSelector) GetNodes() ([]servicer.Node, error) { return s.nodes, nil }
+
+func (s *listSelector) Select(ctx context.Context) (servicer.Node, error) { return s.nodes[0], nil }
+
+func (s *listSelector) AfterHandle(info HandleInfo) {}
+
+type updateSelector struct {
+	listSelector
+}
+
+func (s *updateSelector) UpdateNode(node servicer.Node) error {
+	s.calls = append(s.calls, "update")
+	return nil
+}
+
+func TestUpdateNode(t *testing.T) {
+	// fallback to delete and add
+	s := &listSelector{}
+	_ = s.AddNode(servicer.NewNode("127.0.0.1", 80))
+	assert.Nil(t, UpdateNode(s, servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(3))))
+	assert.Equal(t, []string{"add", "delete", "add"}, s.calls)
+	assert.Equal(t, 3, s.nodes[0].Weight())
+
+	// the node not added is ignored
+	assert.Nil(t, UpdateNode(s, servicer.NewNode("127.0.0.2", 80)))
+	assert.Equal(t, 3, len(s.calls))
+
+	u := &updateSelector{}
+	assert.Nil(t, UpdateNode(u, servicer.NewNode("127.0.0.1", 80)))
+	assert.Equal(t, []string{"update"}, u.calls)
+}
+
+type closeSelector struct {
+	listSelector
+}
+
+func (s *closeSelector) Close() error {
+	s.calls = append(s.calls, "close")
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	// not io.Closer
+	assert.Nil(t, Close(&listSelector{}))
+
+	s := &closeSelector{}
+	assert.Nil(t, Close(s))
+	assert.Equal(t, []string{"close"}, s.calls)
+}
@@ -0,0 +1,102 @@
+package selector
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultSlowStartMinRatio = 0.1
+
+// SlowStart ramps the effective weight of new node from minRatio to 1 in window,
+// measured from the first time the node is added, the ramp restarts if the node is deleted and added again.
+// nil SlowStart means disabled.
+type SlowStart struct {
+	lock     sync.RWMutex
+	window   time.Duration
+	minRatio float64
+	seen     map[string]time.Time
+	last     time.Time // the latest time of seen
+}
+
+// NewSlowStart return nil if window <= 0
+func NewSlowStart(window time.Duration, minRatio float64) *SlowStart {
+	if window <= 0 {
+		return nil
+	}
+
+	if minRatio <= 0 || minRatio > 1 {
+		minRatio = defaultSlowStartMinRatio
+	}
+
+	return &SlowStart{
+		window:   window,
+		minRatio: minRatio,
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// Add record the first seen time of address
+func (s *SlowStart) Add(address string) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.seen[address]; ok {
+		return
+	}
+
+	now := time.Now()
+	s.seen[address] = now
+	s.last = now
+}
+
+func (s *SlowStart) Delete(address string) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.seen, address)
+}
+
+// Warming return whether any node is in slow start, selector can skip Factor if false.
+func (s *SlowStart) Warming(now time.Time) bool {
+	if s == nil {
+		return false
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return now.Sub(s.last) < s.window
+}
+
+// Factor return the ratio of effective weight to registered weight, minRatio~1
+func (s *SlowStart) Factor(address string, now time.Time) float64 {
+	if s == nil {
+		return 1
+	}
+
+	s.lock.RLock()
+	seen, ok := s.seen[address]
+	s.lock.RUnlock()
+
+	if !ok {
+		return 1
+	}
+
+	elapsed := now.Sub(seen)
+	if elapsed >= s.window {
+		return 1
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return s.minRatio + (1-s.minRatio)*float64(elapsed)/float64(s.window)
+}
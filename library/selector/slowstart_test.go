@@ -0,0 +1,49 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSlowStart(t *testing.T) {
+	assert.Nil(t, NewSlowStart(0, 0.5))
+
+	s := NewSlowStart(time.Second, 0)
+	assert.Equal(t, defaultSlowStartMinRatio, s.minRatio)
+
+	// nil is disabled
+	var ss *SlowStart
+	ss.Add("127.0.0.1:80")
+	ss.Delete("127.0.0.1:80")
+	assert.False(t, ss.Warming(time.Now()))
+	assert.Equal(t, float64(1), ss.Factor("127.0.0.1:80", time.Now()))
+}
+
+func TestSlowStart_Factor(t *testing.T) {
+	s := NewSlowStart(time.Second*10, 0.1)
+	address := "127.0.0.1:80"
+
+	now := time.Now()
+	assert.False(t, s.Warming(now))
+	assert.Equal(t, float64(1), s.Factor(address, now))
+
+	s.Add(address)
+	seen := s.seen[address]
+	assert.True(t, s.Warming(seen))
+	assert.Equal(t, 0.1, s.Factor(address, seen))
+	assert.InDelta(t, 0.55, s.Factor(address, seen.Add(time.Second*5)), 1e-9)
+	assert.Equal(t, float64(1), s.Factor(address, seen.Add(time.Second*10)))
+	assert.False(t, s.Warming(seen.Add(time.Second*10)))
+
+	// repeat add don't restart
+	s.Add(address)
+	assert.Equal(t, seen, s.seen[address])
+
+	// flap restart
+	s.Delete(address)
+	s.Add(address)
+	assert.True(t, s.seen[address].After(seen) || s.seen[address].Equal(seen))
+	assert.Equal(t, 0.1, s.Factor(address, s.seen[address]))
+}
@@ -0,0 +1,193 @@
+// tag is route by node metadata, such as lane and version
+// nodes whose metadata of key equals the tag in context are picked,
+// fallback to untagged nodes when no node matches or the tag is absent.
+package tag
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/why444216978/go-util/assert"
+
+	lc "github.com/air-go/rpc/library/context"
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+type Selector struct {
+	lock        sync.RWMutex
+	key         string
+	newSelector func() selector.Selector
+	groups      map[string]selector.Selector // tag value to selector, "" is untagged
+	nodes       map[string]string            // address to tag value
+	serviceName string
+}
+
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
+
+// NewSelector key is the metadata key to route by, newSelector creates the selector used inside each tag.
+func NewSelector(serviceName, key string, newSelector func() selector.Selector) (*Selector, error) {
+	if key == "" {
+		return nil, errors.New("tag key is empty")
+	}
+
+	if newSelector == nil {
+		return nil, errors.New("newSelector is nil")
+	}
+
+	return &Selector{
+		key:         key,
+		newSelector: newSelector,
+		groups:      make(map[string]selector.Selector),
+		nodes:       make(map[string]string),
+		serviceName: serviceName,
+	}, nil
+}
+
+func (s *Selector) ServiceName() string {
+	return s.serviceName
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.nodes[node.Address()]; ok {
+		return
+	}
+
+	return s.addNode(node)
+}
+
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, ok := s.nodes[node.Address()]
+	if !ok {
+		return
+	}
+
+	return s.deleteNode(node, value)
+}
+
+// UpdateNode replace the node in its group,
+// the node is moved to another group if its tag changed, the state in the old group is lost.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, ok := s.nodes[node.Address()]
+	if !ok {
+		return
+	}
+
+	if value == node.Meta()[s.key] {
+		return selector.UpdateNode(s.groups[value], node)
+	}
+
+	if err = s.deleteNode(node, value); err != nil {
+		return
+	}
+	return s.addNode(node)
+}
+
+// addNode must be called with lock
+func (s *Selector) addNode(node servicer.Node) (err error) {
+	value := node.Meta()[s.key]
+	group, ok := s.groups[value]
+	if !ok {
+		if group = s.newSelector(); assert.IsNil(group) {
+			return errors.New("newSelector return nil")
+		}
+		s.groups[value] = group
+	}
+
+	if err = group.AddNode(node); err != nil {
+		return
+	}
+	s.nodes[node.Address()] = value
+
+	return
+}
+
+// deleteNode must be called with lock, value is the tag of node added
+func (s *Selector) deleteNode(node servicer.Node, value string) (err error) {
+	group := s.groups[value]
+	if err = group.DeleteNode(node); err != nil {
+		return
+	}
+	delete(s.nodes, node.Address())
+
+	// remove empty group, so existing group always has nodes
+	if nodes, _ := group.GetNodes(); len(nodes) == 0 {
+		delete(s.groups, value)
+		_ = selector.Close(group)
+	}
+
+	return
+}
+
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var groupNodes []servicer.Node
+	for _, group := range s.groups {
+		if groupNodes, err = group.GetNodes(); err != nil {
+			return
+		}
+		nodes = append(nodes, groupNodes...)
+	}
+
+	return
+}
+
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	s.lock.RLock()
+	group, ok := s.groups[lc.ValueTags(ctx)[s.key]]
+	if !ok {
+		group, ok = s.groups[""]
+	}
+	s.lock.RUnlock()
+
+	if !ok {
+		return nil, errors.New("node is nil")
+	}
+
+	return group.Select(ctx)
+}
+
+// Close close the selectors of all tags
+func (s *Selector) Close() (err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, group := range s.groups {
+		if e := selector.Close(group); err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.lock.RLock()
+	value, ok := s.nodes[info.Node.Address()]
+	group := s.groups[value]
+	s.lock.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	group.AfterHandle(info)
+}
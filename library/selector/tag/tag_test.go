@@ -0,0 +1,122 @@
+package tag
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	lc "github.com/air-go/rpc/library/context"
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/selector/wr"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func newWR() selector.Selector {
+	return wr.NewSelector("test_service")
+}
+
+func TestNewSelector(t *testing.T) {
+	s, err := NewSelector("test_service", "", newWR)
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+
+	s, err = NewSelector("test_service", "lane", nil)
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+
+	s, err = NewSelector("test_service", "lane", newWR)
+	assert.Nil(t, err)
+	assert.Equal(t, "test_service", s.ServiceName())
+
+	s, _ = NewSelector("test_service", "lane", func() selector.Selector { return nil })
+	assert.NotNil(t, s.AddNode(servicer.NewNode("127.0.0.1", 80)))
+}
+
+func TestSelector_Select(t *testing.T) {
+	s, _ := NewSelector("test_service", "lane", newWR)
+
+	node, err := s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+
+	dev := servicer.NewNode("127.0.0.1", 80, servicer.WithMeta(map[string]string{"lane": "dev"}))
+	_ = s.AddNode(dev)
+
+	// no untagged node
+	node, err = s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+
+	stable := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(stable)
+	_ = s.AddNode(stable)
+
+	nodes, _ := s.GetNodes()
+	assert.Equal(t, 2, len(nodes))
+
+	// match tag
+	ctx := lc.WithTags(context.Background(), map[string]string{"lane": "dev"})
+	for i := 0; i < 10; i++ {
+		node, err := s.Select(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, dev.Address(), node.Address())
+	}
+
+	// fallback to untagged
+	for _, ctx := range []context.Context{
+		context.Background(),
+		lc.WithTags(context.Background(), map[string]string{"lane": "test"}),
+		lc.WithTags(context.Background(), map[string]string{"version": "dev"}),
+	} {
+		node, err := s.Select(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, stable.Address(), node.Address())
+	}
+
+	// tagged node deleted
+	_ = s.DeleteNode(dev)
+	_ = s.DeleteNode(dev)
+	node, _ = s.Select(ctx)
+	assert.Equal(t, stable.Address(), node.Address())
+	assert.Equal(t, 1, len(s.groups))
+}
+
+func TestSelector_AfterHandle(t *testing.T) {
+	s, _ := NewSelector("test_service", "lane", newWR)
+
+	node := servicer.NewNode("127.0.0.1", 80, servicer.WithMeta(map[string]string{"lane": "dev"}))
+	_ = s.AddNode(node)
+
+	s.AfterHandle(selector.HandleInfo{Node: node})
+	s.AfterHandle(selector.HandleInfo{Node: node, Err: errors.New("error")})
+	s.AfterHandle(selector.HandleInfo{Node: servicer.NewNode("127.0.0.2", 80)})
+	s.AfterHandle(selector.HandleInfo{})
+	assert.Equal(t, servicer.Statistics{Success: 1, Fail: 1}, node.Statistics())
+}
+
+func TestSelector_UpdateNode(t *testing.T) {
+	s, _ := NewSelector("test_service", "lane", newWR)
+
+	dev := servicer.NewNode("127.0.0.1", 80, servicer.WithMeta(map[string]string{"lane": "dev"}))
+	_ = s.AddNode(dev)
+
+	// the node not added is ignored
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.2", 80)))
+	nodes, _ := s.GetNodes()
+	assert.Equal(t, 1, len(nodes))
+
+	// same tag
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(3), servicer.WithMeta(map[string]string{"lane": "dev"}))))
+	nodes, _ = s.groups["dev"].GetNodes()
+	assert.Equal(t, 3, nodes[0].Weight())
+
+	// moved to untagged, the empty group is removed
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80)))
+	assert.Equal(t, 1, len(s.groups))
+	node, err := s.Select(lc.WithTags(context.Background(), map[string]string{"lane": "dev"}))
+	assert.Nil(t, err)
+	assert.Equal(t, dev.Address(), node.Address())
+	assert.Equal(t, "", s.nodes[dev.Address()])
+}
@@ -2,10 +2,12 @@
 package wr
 
 import (
+	"context"
 	"errors"
 	"math/rand"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/air-go/rpc/library/selector"
 	"github.com/air-go/rpc/library/servicer"
@@ -27,12 +29,21 @@ type Selector struct {
 	sameWeight  bool
 	totalWeight int
 	serviceName string
+	slowStart   *selector.SlowStart
 }
 
-var _ selector.Selector = (*Selector)(nil)
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
 
 type SelectorOption func(*Selector)
 
+// WithSlowStart ramp the weight of new node from minRatio to full in window
+func WithSlowStart(window time.Duration, minRatio float64) SelectorOption {
+	return func(s *Selector) { s.slowStart = selector.NewSlowStart(window, minRatio) }
+}
+
 func NewSelector(serviceName string, opts ...SelectorOption) *Selector {
 	s := &Selector{
 		nodes:       make(map[string]servicer.Node),
@@ -86,6 +97,8 @@ func (s *Selector) AddNode(node servicer.Node) (err error) {
 	s.sortOffset()
 	s.checkSameWeight()
 
+	s.slowStart.Add(address)
+
 	return
 }
 
@@ -125,6 +138,50 @@ func (s *Selector) DeleteNode(node servicer.Node) (err error) {
 	s.sortOffset()
 	s.checkSameWeight()
 
+	s.slowStart.Delete(address)
+
+	return
+}
+
+// UpdateNode replace the node and its weight, the slow start is kept.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; !ok {
+		return
+	}
+
+	s.nodes[address] = node
+	for idx, n := range s.list {
+		if n.Address() == address {
+			s.list[idx] = node
+			break
+		}
+	}
+	for idx, n := range s.offsetList {
+		if n.Address == address {
+			s.offsetList[idx].Weight = node.Weight()
+			break
+		}
+	}
+
+	// the offsets after the updated one are moved
+	s.totalWeight = 0
+	for idx := range s.offsetList {
+		offset := &s.offsetList[idx]
+		offset.OffsetStart = 0
+		if idx > 0 {
+			offset.OffsetStart = s.totalWeight + 1
+		}
+		offset.OffsetEnd = s.totalWeight + offset.Weight
+		s.totalWeight = offset.OffsetEnd
+	}
+
+	s.sortOffset()
+	s.checkSameWeight()
+
 	return
 }
 
@@ -138,7 +195,7 @@ func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
 	return nodes, nil
 }
 
-func (s *Selector) Select() (node servicer.Node, err error) {
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
@@ -149,6 +206,15 @@ func (s *Selector) Select() (node servicer.Node, err error) {
 		err = errors.New("node is nil")
 	}()
 
+	if s.nodeCount == 0 {
+		return
+	}
+
+	if now := time.Now(); s.slowStart.Warming(now) {
+		node = s.selectWarming(now)
+		return
+	}
+
 	if s.sameWeight {
 		idx := rand.Intn(s.nodeCount)
 		node = s.list[idx]
@@ -175,6 +241,9 @@ func (s *Selector) AfterHandle(info selector.HandleInfo) {
 		return
 	}
 
+	if info.NotSent {
+		return
+	}
 	if info.Err != nil {
 		node.IncrFail()
 		return
@@ -182,6 +251,36 @@ func (s *Selector) AfterHandle(info selector.HandleInfo) {
 	node.IncrSuccess()
 }
 
+// selectWarming is weighted random with the effective weight of slow start
+func (s *Selector) selectWarming(now time.Time) servicer.Node {
+	var (
+		total   float64
+		weights = make([]float64, len(s.list))
+	)
+	for idx, n := range s.list {
+		weight := float64(n.Weight())
+		if s.sameWeight {
+			weight = 1
+		}
+		weights[idx] = weight * s.slowStart.Factor(n.Address(), now)
+		total = total + weights[idx]
+	}
+
+	if total <= 0 {
+		return nil
+	}
+
+	r := rand.Float64() * total
+	for idx, w := range weights {
+		if r < w {
+			return s.list[idx]
+		}
+		r = r - w
+	}
+
+	return s.list[len(s.list)-1]
+}
+
 func (s *Selector) checkSameWeight() {
 	s.sameWeight = true
 
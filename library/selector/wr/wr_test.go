@@ -2,10 +2,12 @@
 package wr
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/assert"
@@ -164,7 +166,7 @@ func testNoDeleteHandle(t *testing.T, nodes []servicer.Node) []servicer.Node {
 		if i > 10000 {
 			break
 		}
-		node, _ := s.Select()
+		node, _ := s.Select(context.Background())
 
 		random := rand.Intn(100)
 		err := errors.New("error")
@@ -191,7 +193,7 @@ func testDeleteHandle(t *testing.T, nodes []servicer.Node) []servicer.Node {
 		if i > 9000 {
 			break
 		}
-		node, _ := s.Select()
+		node, _ := s.Select(context.Background())
 
 		random := rand.Intn(100)
 		err := errors.New("error")
@@ -210,7 +212,7 @@ func testDeleteHandle(t *testing.T, nodes []servicer.Node) []servicer.Node {
 		if i > 1000 {
 			break
 		}
-		node, _ := s.Select()
+		node, _ := s.Select(context.Background())
 
 		random := rand.Intn(10)
 		err := errors.New("error")
@@ -226,3 +228,38 @@ func testDeleteHandle(t *testing.T, nodes []servicer.Node) []servicer.Node {
 	res, _ := s.GetNodes()
 	return res
 }
+
+func TestSelector_SlowStart(t *testing.T) {
+	window := time.Millisecond * 100
+	s := NewSelector("test_service", WithSlowStart(window, 0.1))
+
+	old := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(old)
+	time.Sleep(window + time.Millisecond*20)
+
+	// the new node only gets about minRatio/(1+minRatio) of traffic at the beginning
+	fresh := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(fresh)
+
+	count := 0
+	for i := 0; i < 1000; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		if node.Address() == fresh.Address() {
+			count++
+		}
+	}
+	assert.Greater(t, count, 0)
+	assert.Less(t, count, 300)
+
+	// full weight after window
+	time.Sleep(window)
+	count = 0
+	for i := 0; i < 1000; i++ {
+		node, _ := s.Select(context.Background())
+		if node.Address() == fresh.Address() {
+			count++
+		}
+	}
+	assert.Greater(t, count, 300)
+}
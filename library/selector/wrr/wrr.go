@@ -1,3 +1,218 @@
 // wrr is Weighted Round Robin
 // reference Nginx https://blog.csdn.net/zhangskd/article/details/50194069
 package wrr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// weightScale keep the precision of slow start weight
+const weightScale = 100
+
+type wrrNode struct {
+	node          servicer.Node
+	weight        int
+	currentWeight int
+}
+
+type Selector struct {
+	lock        sync.RWMutex
+	nodes       map[string]*wrrNode
+	list        []*wrrNode
+	serviceName string
+	slowStart   *selector.SlowStart
+}
+
+var (
+	_ selector.Selector = (*Selector)(nil)
+	_ selector.Updater  = (*Selector)(nil)
+)
+
+type SelectorOption func(*Selector)
+
+// WithSlowStart ramp the weight of new node from minRatio to full in window
+func WithSlowStart(window time.Duration, minRatio float64) SelectorOption {
+	return func(s *Selector) { s.slowStart = selector.NewSlowStart(window, minRatio) }
+}
+
+func NewSelector(serviceName string, opts ...SelectorOption) *Selector {
+	s := &Selector{
+		nodes:       make(map[string]*wrrNode),
+		list:        make([]*wrrNode, 0),
+		serviceName: serviceName,
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	return s
+}
+
+func (s *Selector) ServiceName() string {
+	return s.serviceName
+}
+
+func (s *Selector) AddNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; ok {
+		return
+	}
+
+	// node without weight is regarded as weight 1
+	weight := node.Weight()
+	if weight <= 0 {
+		weight = 1
+	}
+
+	n := &wrrNode{
+		node:   node,
+		weight: weight,
+	}
+
+	s.nodes[address] = n
+	s.list = append(s.list, n)
+
+	// restart the cycle, otherwise the new node will be starved or flood
+	s.resetCurrentWeight()
+
+	s.slowStart.Add(address)
+
+	return
+}
+
+func (s *Selector) DeleteNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	address := node.Address()
+	if _, ok := s.nodes[address]; !ok {
+		return
+	}
+
+	delete(s.nodes, address)
+
+	for idx, item := range s.list {
+		if item.node.Address() != address {
+			continue
+		}
+		s.list = append(s.list[:idx], s.list[idx+1:]...)
+		break
+	}
+
+	s.resetCurrentWeight()
+
+	s.slowStart.Delete(address)
+
+	return
+}
+
+// UpdateNode replace the node and its weight, the slow start is kept.
+func (s *Selector) UpdateNode(node servicer.Node) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[node.Address()]
+	if !ok {
+		return
+	}
+
+	weight := node.Weight()
+	if weight <= 0 {
+		weight = 1
+	}
+	n.node = node
+	if n.weight != weight {
+		n.weight = weight
+		s.resetCurrentWeight()
+	}
+
+	return
+}
+
+func (s *Selector) GetNodes() (nodes []servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	nodes = make([]servicer.Node, len(s.list))
+	for idx, n := range s.list {
+		nodes[idx] = n.node
+	}
+
+	return nodes, nil
+}
+
+// Select pick the node which has the largest current weight,
+// then subtract the total weight from it.
+func (s *Selector) Select(ctx context.Context) (node servicer.Node, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		now     = time.Now()
+		warming = s.slowStart.Warming(now)
+		best    *wrrNode
+		total   int
+	)
+	for _, n := range s.list {
+		weight := n.weight * weightScale
+		if warming {
+			weight = int(float64(weight) * s.slowStart.Factor(n.node.Address(), now))
+			if weight < 1 {
+				weight = 1
+			}
+		}
+
+		n.currentWeight = n.currentWeight + weight
+		total = total + weight
+		if best == nil || n.currentWeight > best.currentWeight {
+			best = n
+		}
+	}
+
+	if best == nil {
+		return nil, errors.New("node is nil")
+	}
+
+	best.currentWeight = best.currentWeight - total
+
+	return best.node, nil
+}
+
+func (s *Selector) AfterHandle(info selector.HandleInfo) {
+	if info.Node == nil {
+		return
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	n, ok := s.nodes[info.Node.Address()]
+	if !ok {
+		return
+	}
+
+	if info.NotSent {
+		return
+	}
+	if info.Err != nil {
+		n.node.IncrFail()
+		return
+	}
+	n.node.IncrSuccess()
+}
+
+func (s *Selector) resetCurrentWeight() {
+	for _, n := range s.list {
+		n.currentWeight = 0
+	}
+}
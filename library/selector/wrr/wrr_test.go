@@ -0,0 +1,185 @@
+package wrr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func TestSelector_ServiceName(t *testing.T) {
+	s := NewSelector("test_service")
+	assert.Equal(t, "test_service", s.ServiceName())
+}
+
+func TestSelector_Select(t *testing.T) {
+	// smooth sequence of nginx: a a b a c a a
+	s := NewSelector("test_service")
+	_ = s.AddNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(5)))
+	_ = s.AddNode(servicer.NewNode("127.0.0.2", 80, servicer.WithWeight(1)))
+	_ = s.AddNode(servicer.NewNode("127.0.0.3", 80, servicer.WithWeight(1)))
+
+	expect := []string{
+		"127.0.0.1:80", "127.0.0.1:80", "127.0.0.2:80", "127.0.0.1:80",
+		"127.0.0.3:80", "127.0.0.1:80", "127.0.0.1:80",
+	}
+	for i := 0; i < 2; i++ {
+		for _, address := range expect {
+			node, err := s.Select(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, address, node.Address())
+		}
+	}
+}
+
+func TestSelector_SelectEmpty(t *testing.T) {
+	s := NewSelector("test_service")
+	node, err := s.Select(context.Background())
+	assert.Nil(t, node)
+	assert.NotNil(t, err)
+}
+
+func TestSelector_DeleteNode(t *testing.T) {
+	s := NewSelector("test_service")
+	nodes := []servicer.Node{
+		servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(2)),
+		servicer.NewNode("127.0.0.2", 80, servicer.WithWeight(2)),
+		servicer.NewNode("127.0.0.3", 80),
+	}
+	for _, node := range nodes {
+		_ = s.AddNode(node)
+	}
+	// repeat add is ignored
+	_ = s.AddNode(nodes[0])
+
+	n, err := s.GetNodes()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(n))
+
+	_ = s.DeleteNode(nodes[0])
+	n, _ = s.GetNodes()
+	assert.Equal(t, 2, len(n))
+
+	for i := 0; i < 100; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		assert.NotEqual(t, nodes[0].Address(), node.Address())
+	}
+}
+
+func TestSelector_AfterHandle(t *testing.T) {
+	s := NewSelector("test_service")
+	node := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(node)
+
+	s.AfterHandle(selector.HandleInfo{Node: node})
+	s.AfterHandle(selector.HandleInfo{Node: node, Err: errors.New("error")})
+	s.AfterHandle(selector.HandleInfo{Node: servicer.NewNode("127.0.0.2", 80)})
+	s.AfterHandle(selector.HandleInfo{})
+
+	assert.Equal(t, servicer.Statistics{Success: 1, Fail: 1}, node.Statistics())
+}
+
+func TestSelector_Concurrent(t *testing.T) {
+	s := NewSelector("test_service")
+	nodes := []servicer.Node{
+		servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(3)),
+		servicer.NewNode("127.0.0.2", 80, servicer.WithWeight(2)),
+		servicer.NewNode("127.0.0.3", 80, servicer.WithWeight(1)),
+	}
+	_ = s.AddNode(nodes[0])
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				node, err := s.Select(context.Background())
+				assert.Nil(t, err)
+				s.AfterHandle(selector.HandleInfo{Node: node})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = s.AddNode(nodes[1+j%2])
+				_ = s.DeleteNode(nodes[1+j%2])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSelector_SlowStart(t *testing.T) {
+	window := time.Millisecond * 100
+	s := NewSelector("test_service", WithSlowStart(window, 0.1))
+
+	old := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(old)
+	time.Sleep(window + time.Millisecond*20)
+
+	// the new node only gets about minRatio/(1+minRatio) of traffic at the beginning
+	fresh := servicer.NewNode("127.0.0.2", 80)
+	_ = s.AddNode(fresh)
+
+	count := 0
+	for i := 0; i < 1000; i++ {
+		node, err := s.Select(context.Background())
+		assert.Nil(t, err)
+		if node.Address() == fresh.Address() {
+			count++
+		}
+	}
+	assert.Greater(t, count, 0)
+	assert.Less(t, count, 300)
+
+	// full weight after window
+	time.Sleep(window)
+	count = 0
+	for i := 0; i < 1000; i++ {
+		node, _ := s.Select(context.Background())
+		if node.Address() == fresh.Address() {
+			count++
+		}
+	}
+	assert.Greater(t, count, 300)
+}
+
+func TestSelector_UpdateNode(t *testing.T) {
+	s := NewSelector("test_service", WithSlowStart(time.Hour, 0.1))
+	a := servicer.NewNode("127.0.0.1", 80)
+	_ = s.AddNode(a)
+	_ = s.AddNode(servicer.NewNode("127.0.0.2", 80))
+
+	now := time.Now()
+	factor := s.slowStart.Factor(a.Address(), now)
+	time.Sleep(time.Millisecond)
+
+	// the node not added is ignored
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.3", 80)))
+	assert.Nil(t, s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(3))))
+	nodes, _ := s.GetNodes()
+	assert.Equal(t, 2, len(nodes))
+	assert.Equal(t, 3, nodes[0].Weight())
+
+	// slow start is not restarted
+	assert.Equal(t, factor, s.slowStart.Factor(a.Address(), now))
+
+	s = NewSelector("test_service")
+	_ = s.AddNode(a)
+	_ = s.AddNode(servicer.NewNode("127.0.0.2", 80))
+	_ = s.UpdateNode(servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(3)))
+	count := map[string]int{}
+	for i := 0; i < 400; i++ {
+		node, _ := s.Select(context.Background())
+		count[node.Address()]++
+	}
+	assert.Equal(t, 300, count[a.Address()])
+}
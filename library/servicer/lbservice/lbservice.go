@@ -0,0 +1,193 @@
+// lbservice is servicer.Servicer backed by discoverer.Discoverer and loadbalancer.LoadBalancer,
+// the discoverer refresh addrs in the background and the loadbalancer pick one of them.
+package lbservice
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/why444216978/go-util/assert"
+	"github.com/why444216978/go-util/validate"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/discoverer"
+	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/servicer"
+	"github.com/air-go/rpc/library/servicer/service"
+)
+
+// NewDiscoverer create the discoverer which set addrs to lb.
+type NewDiscoverer func(lb loadbalancer.LoadBalancer) (discoverer.Discoverer, error)
+
+type Service struct {
+	config     *service.Config
+	lb         loadbalancer.LoadBalancer
+	discoverer discoverer.Discoverer
+	lock       sync.RWMutex
+	addrs      []net.Addr
+	watchers   *servicer.Watchers
+	caCrt      []byte
+	clientPem  []byte
+	clientKey  []byte
+}
+
+var (
+	_ servicer.Servicer = (*Service)(nil)
+	_ servicer.Watcher  = (*Service)(nil)
+)
+
+// NewService the discoverer is created with a wrapper of lb,
+// so that the addrs can be recorded for All.
+func NewService(config *service.Config, lb loadbalancer.LoadBalancer, newDiscoverer NewDiscoverer) (s *Service, err error) {
+	if assert.IsNil(lb) {
+		return nil, errors.New("loadbalancer is nil")
+	}
+	if newDiscoverer == nil {
+		return nil, errors.New("newDiscoverer is nil")
+	}
+
+	if err = validate.Validate(config); err != nil {
+		return nil, err
+	}
+
+	s = &Service{
+		config:    config,
+		lb:        lb,
+		caCrt:     []byte(config.CaCrt),
+		clientPem: []byte(config.ClientPem),
+		clientKey: []byte(config.ClientKey),
+		watchers:  servicer.NewWatchers(),
+	}
+
+	if s.discoverer, err = newDiscoverer(&recorder{s: s}); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Start start the discoverer, return error if the first discovery failed.
+func (s *Service) Start(ctx context.Context) error {
+	return s.discoverer.Start(ctx)
+}
+
+func (s *Service) Stop() error {
+	return s.discoverer.Stop()
+}
+
+func (s *Service) Name() string {
+	return s.config.ServiceName
+}
+
+func (s *Service) RegistryName() string {
+	return s.config.RegistryName
+}
+
+func (s *Service) Pick(ctx context.Context) (node servicer.Node, err error) {
+	a, err := s.lb.Pick(ctx)
+	if err != nil {
+		return
+	}
+	return addrToNode(a)
+}
+
+func (s *Service) All(ctx context.Context) (nodes []servicer.Node, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	nodes = make([]servicer.Node, 0, len(s.addrs))
+	for _, a := range s.addrs {
+		node, err := addrToNode(a)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	return
+}
+
+// Watch call f with the nodes after each addrs set by discoverer.
+func (s *Service) Watch(f func(nodes []servicer.Node)) (cancel func()) {
+	return s.watchers.Watch(f)
+}
+
+// Done back to loadbalancer, which identify the addr by String, ErrNotSent is passed as is.
+func (s *Service) Done(ctx context.Context, node servicer.Node, err error, cost time.Duration) error {
+	if assert.IsNil(node) {
+		return errors.New("node is nil")
+	}
+	s.lb.Back(&addr.TCPAddr{IP: net.ParseIP(node.Host()), Port: node.Port()}, err)
+	return nil
+}
+
+func (s *Service) GetCaCrt() []byte {
+	return s.caCrt
+}
+
+func (s *Service) GetClientPem() []byte {
+	return s.clientPem
+}
+
+func (s *Service) GetClientKey() []byte {
+	return s.clientKey
+}
+
+// recorder record the addrs set by discoverer.
+type recorder struct {
+	s *Service
+}
+
+var _ loadbalancer.LoadBalancer = (*recorder)(nil)
+
+func (r *recorder) Strategy() string {
+	return r.s.lb.Strategy()
+}
+
+func (r *recorder) SetAddrs(addrs []net.Addr) error {
+	if err := r.s.lb.SetAddrs(addrs); err != nil {
+		return err
+	}
+
+	r.s.lock.Lock()
+	r.s.addrs = addrs
+	r.s.lock.Unlock()
+
+	nodes, err := r.s.All(context.Background())
+	if err != nil {
+		return err
+	}
+	r.s.watchers.Notify(nodes)
+
+	return nil
+}
+
+func (r *recorder) Pick(ctx context.Context) (net.Addr, error) {
+	return r.s.lb.Pick(ctx)
+}
+
+func (r *recorder) Back(a net.Addr, err error) {
+	r.s.lb.Back(a, err)
+}
+
+func addrToNode(a net.Addr) (servicer.Node, error) {
+	host, p, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid addr %s", a.String())
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid addr %s", a.String())
+	}
+
+	opts := []servicer.Option{}
+	if wa, ok := a.(addr.WeightedAddr); ok {
+		opts = append(opts, servicer.WithWeight(int(wa.Weight())))
+	}
+
+	return servicer.NewNode(host, port, opts...), nil
+}
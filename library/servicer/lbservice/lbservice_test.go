@@ -0,0 +1,124 @@
+package lbservice
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/addr"
+	"github.com/air-go/rpc/library/discoverer"
+	"github.com/air-go/rpc/library/loadbalancer"
+	"github.com/air-go/rpc/library/loadbalancer/leastrequest"
+	"github.com/air-go/rpc/library/loadbalancer/roundrobin"
+	"github.com/air-go/rpc/library/servicer"
+	"github.com/air-go/rpc/library/servicer/service"
+)
+
+type staticDiscoverer struct {
+	lb    loadbalancer.LoadBalancer
+	addrs []net.Addr
+}
+
+func (d *staticDiscoverer) Start(ctx context.Context) error {
+	return d.lb.SetAddrs(d.addrs)
+}
+
+func (d *staticDiscoverer) Stop() error {
+	return nil
+}
+
+func newStatic(addrs ...net.Addr) NewDiscoverer {
+	return func(lb loadbalancer.LoadBalancer) (discoverer.Discoverer, error) {
+		return &staticDiscoverer{lb: lb, addrs: addrs}, nil
+	}
+}
+
+func newConfig() *service.Config {
+	return &service.Config{
+		ServiceName: "test_service",
+		Type:        servicer.TypeDomain,
+		Host:        "localhost",
+		Port:        80,
+		Selector:    "wr",
+		CaCrt:       "ca",
+	}
+}
+
+func TestNewService(t *testing.T) {
+	_, err := NewService(newConfig(), nil, newStatic())
+	assert.NotNil(t, err)
+
+	_, err = NewService(newConfig(), roundrobin.New(), nil)
+	assert.NotNil(t, err)
+
+	cfg := newConfig()
+	cfg.Type = 4
+	_, err = NewService(cfg, roundrobin.New(), newStatic())
+	assert.NotNil(t, err)
+
+	_, err = NewService(newConfig(), roundrobin.New(), func(loadbalancer.LoadBalancer) (discoverer.Discoverer, error) {
+		return nil, errors.New("err")
+	})
+	assert.NotNil(t, err)
+
+	s, err := NewService(newConfig(), roundrobin.New(), newStatic())
+	assert.Nil(t, err)
+	assert.Equal(t, "test_service", s.Name())
+	assert.Equal(t, "", s.RegistryName())
+	assert.Equal(t, []byte("ca"), s.GetCaCrt())
+	assert.Equal(t, []byte{}, s.GetClientPem())
+	assert.Equal(t, []byte{}, s.GetClientKey())
+}
+
+func TestService_Pick(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewService(newConfig(), roundrobin.New(), newStatic(
+		&addr.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 80},
+		addr.NewWeightedTCPAddr(net.ParseIP("127.0.0.2"), 81, 0, 5),
+	))
+	assert.Nil(t, err)
+
+	// not started
+	_, err = s.Pick(ctx)
+	assert.Equal(t, loadbalancer.ErrAddrsEmpty, err)
+	nodes, err := s.All(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(nodes))
+
+	assert.Nil(t, s.Start(ctx))
+	defer s.Stop()
+
+	node, err := s.Pick(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:80", node.Address())
+	node, err = s.Pick(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.2:81", node.Address())
+	assert.Equal(t, 5, node.Weight())
+
+	nodes, err = s.All(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(nodes))
+	assert.Equal(t, "127.0.0.1:80", nodes[0].Address())
+}
+
+func TestService_Done(t *testing.T) {
+	ctx := context.Background()
+	lb := leastrequest.New()
+	a := &addr.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 80}
+	s, err := NewService(newConfig(), lb, newStatic(a))
+	assert.Nil(t, err)
+	assert.Nil(t, s.Start(ctx))
+
+	node, err := s.Pick(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), lb.Inflight(a))
+
+	assert.Nil(t, s.Done(ctx, node, nil, 0))
+	assert.Equal(t, int64(0), lb.Inflight(a))
+
+	assert.NotNil(t, s.Done(ctx, nil, nil, 0))
+}
@@ -1,28 +1,77 @@
 package load
 
 import (
+	"context"
 	"errors"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/why444216978/go-util/assert"
 	utilDir "github.com/why444216978/go-util/dir"
 
+	"github.com/air-go/rpc/library/app"
 	"github.com/air-go/rpc/library/config"
+	"github.com/air-go/rpc/library/discoverer"
+	"github.com/air-go/rpc/library/discoverer/dns"
 	"github.com/air-go/rpc/library/etcd"
+	"github.com/air-go/rpc/library/loadbalancer"
+	lbFactory "github.com/air-go/rpc/library/loadbalancer/factory"
+	"github.com/air-go/rpc/library/logger"
+	"github.com/air-go/rpc/library/logger/nop"
 	"github.com/air-go/rpc/library/registry"
+	registryConsul "github.com/air-go/rpc/library/registry/consul"
 	registryEtcd "github.com/air-go/rpc/library/registry/etcd"
+	registryFile "github.com/air-go/rpc/library/registry/file"
+	registryKubernetes "github.com/air-go/rpc/library/registry/kubernetes"
+	registryRedis "github.com/air-go/rpc/library/registry/redis"
+	registryXDS "github.com/air-go/rpc/library/registry/xds"
+	"github.com/air-go/rpc/library/selector"
 	"github.com/air-go/rpc/library/selector/factory"
+	"github.com/air-go/rpc/library/selector/locality"
+	"github.com/air-go/rpc/library/selector/outlier"
+	"github.com/air-go/rpc/library/selector/tag"
 	"github.com/air-go/rpc/library/servicer"
+	"github.com/air-go/rpc/library/servicer/lbservice"
 	"github.com/air-go/rpc/library/servicer/service"
+	"github.com/air-go/rpc/library/servicer/subset"
 )
 
-func LoadGlobPattern(path, suffix string, etcd *etcd.Etcd) (err error) {
+type options struct {
+	logger logger.Logger
+	redis  redis.UniversalClient
+	xds    *registryXDS.Client
+}
+
+type Option func(*options)
+
+// WithLogger set the logger of components created by service config, such as outlier detection
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithRedis set the client of redis registry, required by the service with RegistryType redis
+func WithRedis(cli redis.UniversalClient) Option {
+	return func(o *options) { o.redis = cli }
+}
+
+// WithXDS set the ADS client of control plane, required by the service with RegistryType xds
+func WithXDS(cli *registryXDS.Client) Option {
+	return func(o *options) { o.xds = cli }
+}
+
+func LoadGlobPattern(path, suffix string, etcd *etcd.Etcd, opts ...Option) (err error) {
 	var (
 		dir   string
 		files []string
 	)
 
+	opt := &options{}
+	for _, o := range opts {
+		o(opt)
+	}
+
 	if dir, err = config.Dir(); err != nil {
 		return
 	}
@@ -31,10 +80,13 @@ func LoadGlobPattern(path, suffix string, etcd *etcd.Etcd) (err error) {
 		return
 	}
 
-	var discover registry.Discovery
 	info := utilDir.FileInfo{}
-	cfg := &service.Config{}
 	for _, f := range files {
+		var (
+			discover registry.Discovery
+			sel      selector.Selector
+			cfg      = &service.Config{}
+		)
 		if info, err = utilDir.GetPathInfo(f); err != nil {
 			return
 		}
@@ -42,20 +94,126 @@ func LoadGlobPattern(path, suffix string, etcd *etcd.Etcd) (err error) {
 			return
 		}
 
-		if cfg.Type == servicer.TypeRegistry {
-			if assert.IsNil(etcd) {
-				return errors.New("LoadGlobPattern etcd nil")
-			}
-			if strings.TrimSpace(cfg.RegistryName) == "" {
-				return errors.New("service RegistryName is empty")
+		if cfg.Type == servicer.TypeDomain {
+			if err = loadDomainService(cfg, opt); err != nil {
+				return
 			}
+			continue
+		}
 
-			if discover, err = registryEtcd.NewDiscovery(etcd.Client, cfg.RegistryName); err != nil {
+		if cfg.Type == servicer.TypeRegistry {
+			if discover, err = newDiscovery(cfg, dir, etcd, opt); err != nil {
 				return
 			}
 		}
 
-		if err = LoadService(cfg, service.WithDiscovery(discover), service.WithSelector(factory.New(cfg.ServiceName, cfg.Selector))); err != nil {
+		if sel, err = newSelector(cfg, opt); err != nil {
+			return
+		}
+
+		if err = LoadService(cfg,
+			service.WithDiscovery(discover),
+			service.WithSelector(sel),
+			service.WithSubset(subset.NewRendezvous(app.Name()+"/"+app.LocalIP(), cfg.Subset)),
+		); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func newDiscovery(cfg *service.Config, dir string, etcd *etcd.Etcd, opt *options) (registry.Discovery, error) {
+	if strings.TrimSpace(cfg.RegistryName) == "" {
+		return nil, errors.New("service RegistryName is empty")
+	}
+
+	t := cfg.RegistryType
+	if t == "" {
+		t = registry.TypeEtcd
+		if cfg.RegistryFile != "" {
+			t = registry.TypeFile
+		}
+	}
+
+	switch t {
+	case registry.TypeFile:
+		return registryFile.NewDiscovery(filepath.Join(dir, cfg.RegistryFile), registryFile.WithLogger(opt.logger))
+	case registry.TypeConsul:
+		return registryConsul.NewDiscovery(registryConsul.NewClient(cfg.RegistryAddress), cfg.RegistryName,
+			registryConsul.WithLogger(opt.logger))
+	case registry.TypeKubernetes:
+		return newKubernetesDiscovery(cfg, opt)
+	case registry.TypeRedis:
+		if assert.IsNil(opt.redis) {
+			return nil, errors.New("LoadGlobPattern redis nil")
+		}
+		return registryRedis.NewDiscovery(opt.redis, cfg.RegistryName, registryRedis.WithLogger(opt.logger))
+	case registry.TypeXDS:
+		if opt.xds == nil {
+			return nil, errors.New("LoadGlobPattern xds nil")
+		}
+		return registryXDS.NewDiscovery(opt.xds, cfg.RegistryName)
+	}
+
+	if assert.IsNil(etcd) {
+		return nil, errors.New("LoadGlobPattern etcd nil")
+	}
+
+	return registryEtcd.NewDiscovery(etcd.Client, cfg.RegistryName,
+		registryEtcd.WithSnapshotDir(cfg.SnapshotDir),
+		registryEtcd.WithProtectThreshold(cfg.ProtectThreshold))
+}
+
+// newKubernetesDiscovery use the in cluster config, or kubeconfig out of cluster,
+// the namespace is default to the namespace of pod or kubeconfig context.
+func newKubernetesDiscovery(cfg *service.Config, opt *options) (registry.Discovery, error) {
+	kc, err := registryKubernetes.DefaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	cli, err := registryKubernetes.NewClient(kc)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, name := "", cfg.RegistryName
+	if idx := strings.Index(name, "/"); idx > -1 {
+		namespace, name = name[:idx], name[idx+1:]
+	}
+	return registryKubernetes.NewDiscovery(cli, namespace, name, registryKubernetes.WithLogger(opt.logger))
+}
+
+func newSelector(cfg *service.Config, opt *options) (sel selector.Selector, err error) {
+	newBase := func() selector.Selector {
+		return factory.New(cfg.ServiceName, cfg.Selector,
+			factory.WithSlowStart(time.Duration(cfg.SlowStart)*time.Millisecond, 0))
+	}
+
+	if cfg.Locality {
+		newFactory := newBase
+		// validate once, so the error is returned instead of a nil selector,
+		// the selectors created later by the same args never fail
+		if _, err = locality.NewSelector(cfg.ServiceName, app.Zone(), newFactory); err != nil {
+			return
+		}
+		newBase = func() selector.Selector {
+			s, err := locality.NewSelector(cfg.ServiceName, app.Zone(), newFactory)
+			if err != nil {
+				return nil
+			}
+			return s
+		}
+	}
+
+	if cfg.RouteTag == "" {
+		sel = newBase()
+	} else if sel, err = tag.NewSelector(cfg.ServiceName, cfg.RouteTag, newBase); err != nil {
+		return
+	}
+
+	if cfg.OutlierDetection {
+		if sel, err = outlier.NewSelector(sel, outlier.WithLogger(opt.logger)); err != nil {
 			return
 		}
 	}
@@ -63,6 +221,38 @@ func LoadGlobPattern(path, suffix string, etcd *etcd.Etcd) (err error) {
 	return
 }
 
+// loadDomainService resolve the domain in the background, instead of resolving on every Pick.
+func loadDomainService(cfg *service.Config, opt *options) (err error) {
+	s, err := lbservice.NewService(cfg, lbFactory.New(cfg.LoadBalancer), func(lb loadbalancer.LoadBalancer) (discoverer.Discoverer, error) {
+		return dns.NewDNSDiscoverer(cfg.ServiceName, lb, []dns.Node{
+			{Host: cfg.Host, Port: cfg.Port, Network: "ip"},
+		}, dns.WithLogger(opt.logger))
+	})
+	if err != nil {
+		return
+	}
+
+	// the discoverer keeps resolving in the background, a domain not resolvable now
+	// must not fail the loading of other services
+	if startErr := s.Start(context.Background()); startErr != nil {
+		l := opt.logger
+		if l == nil {
+			l = nop.Logger
+		}
+		l.Warn(context.Background(), "loadDomainServiceStartErr",
+			logger.Reflect(logger.ServiceName, cfg.ServiceName),
+			logger.Error(startErr),
+		)
+	}
+
+	if err = servicer.SetServicer(s); err != nil {
+		_ = s.Stop()
+		return
+	}
+
+	return
+}
+
 func LoadService(config *service.Config, opts ...service.Option) (err error) {
 	s, err := service.NewService(config, opts...)
 	if err != nil {
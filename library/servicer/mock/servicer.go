@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	servicer "github.com/air-go/rpc/library/servicer"
 	gomock "github.com/golang/mock/gomock"
@@ -51,17 +52,17 @@ func (mr *MockServicerMockRecorder) All(ctx interface{}) *gomock.Call {
 }
 
 // Done mocks base method.
-func (m *MockServicer) Done(ctx context.Context, node servicer.Node, err error) error {
+func (m *MockServicer) Done(ctx context.Context, node servicer.Node, err error, cost time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Done", ctx, node, err)
+	ret := m.ctrl.Call(m, "Done", ctx, node, err, cost)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Done indicates an expected call of Done.
-func (mr *MockServicerMockRecorder) Done(ctx, node, err interface{}) *gomock.Call {
+func (mr *MockServicerMockRecorder) Done(ctx, node, err, cost interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Done", reflect.TypeOf((*MockServicer)(nil).Done), ctx, node, err)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Done", reflect.TypeOf((*MockServicer)(nil).Done), ctx, node, err, cost)
 }
 
 // GetCaCrt mocks base method.
@@ -2,6 +2,7 @@ package servicer
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,8 @@ type Node interface {
 	Port() int
 	Weight() int
 	FloatWeight() float64
+	Zone() string
+	Meta() map[string]string
 	Statistics() Statistics
 	IncrSuccess()
 	IncrFail()
@@ -37,6 +40,14 @@ func ExtractAddress(address string) (string, int) {
 	return arr[0], port
 }
 
+// SameNode return whether the attributes of a and b are the same, the address is not compared.
+func SameNode(a, b Node) bool {
+	return a.Weight() == b.Weight() &&
+		a.FloatWeight() == b.FloatWeight() &&
+		a.Zone() == b.Zone() &&
+		reflect.DeepEqual(a.Meta(), b.Meta())
+}
+
 type Option func(*node)
 
 func WithWeight(w int) Option {
@@ -47,6 +58,14 @@ func WithFloatWeight(w float64) Option {
 	return func(n *node) { n.floatWeight = w }
 }
 
+func WithZone(zone string) Option {
+	return func(n *node) { n.zone = zone }
+}
+
+func WithMeta(meta map[string]string) Option {
+	return func(n *node) { n.meta = meta }
+}
+
 type node struct {
 	lock        sync.RWMutex
 	address     string
@@ -54,6 +73,8 @@ type node struct {
 	port        int
 	weight      int
 	floatWeight float64
+	zone        string
+	meta        map[string]string
 	statistics  Statistics
 }
 
@@ -103,6 +124,15 @@ func (n *node) FloatWeight() float64 {
 	return n.floatWeight
 }
 
+func (n *node) Zone() string {
+	return n.zone
+}
+
+// Meta must not be modified
+func (n *node) Meta() map[string]string {
+	return n.meta
+}
+
 func (n *node) IncrSuccess() {
 	n.lock.Lock()
 	defer n.lock.Unlock()
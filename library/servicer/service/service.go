@@ -13,29 +13,44 @@ import (
 	"github.com/air-go/rpc/library/registry"
 	"github.com/air-go/rpc/library/selector"
 	"github.com/air-go/rpc/library/servicer"
+	"github.com/air-go/rpc/library/servicer/subset"
 )
 
 type Config struct {
-	ServiceName  string `validate:"required"`
-	RegistryName string
-	Type         uint8  `validate:"required,oneof=1 2"`
-	Host         string `validate:"required"`
-	Port         int    `validate:"required"`
-	Selector     string `validate:"required,oneof=wr"` // TODO support others
-	CaCrt        string
-	ClientPem    string
-	ClientKey    string
+	ServiceName      string  `validate:"required"`
+	RegistryName     string  // name of registry, kubernetes Service can be namespace/name
+	RegistryType     string  `validate:"omitempty,oneof=etcd consul file kubernetes redis xds"` // default is file if RegistryFile set, otherwise etcd
+	RegistryAddress  string  // address of consul agent, default is 127.0.0.1:8500
+	RegistryFile     string  // node list file relative to config dir
+	SnapshotDir      string  // dir to persist the last good nodes of etcd, loaded if etcd unavailable at start
+	ProtectThreshold float64 // refuse for at most one minute the etcd update which drop more than the ratio of nodes since the last full load, 0 means never
+	Type             uint8   `validate:"required,oneof=1 2 3"`
+	Host             string  `validate:"required"`
+	Port             int     `validate:"required"`
+	Selector         string  `validate:"required,oneof=wr wrr dwrr p2c icmp chash"`
+	Locality         bool    // prefer nodes in the same zone as app.Zone
+	OutlierDetection bool    // eject failing nodes for a cooldown period
+	RouteTag         string  // node metadata key to route by, such as lane
+	SlowStart        int     // warm up window of new node in millisecond, only for wr wrr dwrr
+	Subset           int     // max nodes connected by each client, 0 means all
+	LoadBalancer     string  `validate:"omitempty,oneof=round_robin priority least_request"` // only for TypeDomain
+	CaCrt            string
+	ClientPem        string
+	ClientKey        string
 }
 
 type Service struct {
 	sync.RWMutex
-	selector   selector.Selector
-	updateTime time.Time
-	discovery  registry.Discovery
-	caCrt      []byte
-	clientPem  []byte
-	clientKey  []byte
-	config     *Config
+	selector    selector.Selector
+	discovery   registry.Discovery
+	subset      subset.Subsetter
+	nodes       map[string]servicer.Node // all discovered nodes before subset
+	watchers    *servicer.Watchers
+	unsubscribe func()
+	caCrt       []byte
+	clientPem   []byte
+	clientKey   []byte
+	config      *Config
 }
 
 type Option func(*Service)
@@ -48,7 +63,15 @@ func WithSelector(selector selector.Selector) Option {
 	return func(s *Service) { s.selector = selector }
 }
 
-var _ servicer.Servicer = (*Service)(nil)
+// WithSubset set the subsetter applied to discovered nodes before adding them to selector
+func WithSubset(subset subset.Subsetter) Option {
+	return func(s *Service) { s.subset = subset }
+}
+
+var (
+	_ servicer.Servicer = (*Service)(nil)
+	_ servicer.Watcher  = (*Service)(nil)
+)
 
 func NewService(config *Config, opts ...Option) (*Service, error) {
 	s := &Service{
@@ -56,6 +79,8 @@ func NewService(config *Config, opts ...Option) (*Service, error) {
 		caCrt:     []byte(config.CaCrt),
 		clientPem: []byte(config.ClientPem),
 		clientKey: []byte(config.ClientKey),
+		nodes:     make(map[string]servicer.Node),
+		watchers:  servicer.NewWatchers(),
 	}
 
 	for _, o := range opts {
@@ -99,8 +124,7 @@ func (s *Service) Pick(ctx context.Context) (node servicer.Node, err error) {
 		node = servicer.NewNode(host.IP.String(), s.config.Port)
 		return
 	case servicer.TypeRegistry:
-		s.adjustSelectorNode()
-		return s.selector.Select()
+		return s.selector.Select(ctx)
 	}
 
 	return nil, errors.New("config type not support")
@@ -118,13 +142,29 @@ func (s *Service) All(ctx context.Context) (node []servicer.Node, err error) {
 		}
 		return []servicer.Node{servicer.NewNode(host.IP.String(), s.config.Port)}, nil
 	case servicer.TypeRegistry:
-		s.adjustSelectorNode()
 		return s.selector.GetNodes()
 	}
 
 	return nil, errors.New("config type not support")
 }
 
+// Watch call f with the nodes of selector after each change pushed by discovery,
+// only TypeRegistry service is pushed.
+func (s *Service) Watch(f func(nodes []servicer.Node)) (cancel func()) {
+	return s.watchers.Watch(f)
+}
+
+// Close unsubscribe the discovery and close the selector, such as stop the probing of icmp.
+func (s *Service) Close() error {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+	if assert.IsNil(s.selector) {
+		return nil
+	}
+	return selector.Close(s.selector)
+}
+
 func (s *Service) initSelector() (err error) {
 	if s.config.Type != servicer.TypeRegistry {
 		return nil
@@ -138,56 +178,74 @@ func (s *Service) initSelector() (err error) {
 		return errors.New("selector is nil")
 	}
 
-	s.adjustSelectorNode()
+	s.unsubscribe = s.discovery.Subscribe(s.onEvents)
 
 	return nil
 }
 
-func (s *Service) adjustSelectorNode() {
-	if s.config.Type != servicer.TypeRegistry {
-		return
-	}
+// onEvents apply the deltas to all nodes, then sync the subset of them to selector.
+func (s *Service) onEvents(events []registry.NodeEvent) {
+	s.Lock()
 
-	if s.discovery.GetUpdateTime().Before(s.updateTime) {
-		return
+	updated := make(map[string]struct{})
+	for _, ev := range events {
+		address := ev.Node.Address()
+		switch ev.Type {
+		case registry.NodeAdd:
+			s.nodes[address] = ev.Node
+		case registry.NodeUpdate:
+			s.nodes[address] = ev.Node
+			updated[address] = struct{}{}
+		case registry.NodeDelete:
+			delete(s.nodes, address)
+		}
 	}
 
-	s.Lock()
-	defer s.Unlock()
-
-	var (
-		address     string
-		nowNodes    = s.discovery.GetNodes()
-		nowMap      = make(map[string]struct{})
-		selectorMap = make(map[string]servicer.Node)
-	)
+	nowNodes := make([]servicer.Node, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nowNodes = append(nowNodes, node)
+	}
+	if !assert.IsNil(s.subset) {
+		nowNodes = s.subset.Subset(nowNodes)
+	}
 
-	// selector add new nodes
+	nowMap := make(map[string]struct{}, len(nowNodes))
 	for _, node := range nowNodes {
-		address = node.Address()
-		nowMap[address] = struct{}{}
-		selectorMap[address] = node
-
-		_ = s.selector.AddNode(node)
+		nowMap[node.Address()] = struct{}{}
 	}
 
-	// selector delete non-existent nodes
+	// selector delete non-existent nodes and update the changed ones in place,
+	// so their runtime state such as slow start is kept, then add the new ones
 	selectorNodes, _ := s.selector.GetNodes()
 	for _, n := range selectorNodes {
-		if _, ok := nowMap[n.Address()]; ok {
+		address := n.Address()
+		if _, ok := nowMap[address]; !ok {
+			_ = s.selector.DeleteNode(n)
 			continue
 		}
-		_ = s.selector.DeleteNode(n)
+		if _, ok := updated[address]; ok {
+			_ = selector.UpdateNode(s.selector, s.nodes[address])
+		}
+	}
+	for _, node := range nowNodes {
+		_ = s.selector.AddNode(node)
 	}
 
-	s.updateTime = time.Now()
+	nodes, _ := s.selector.GetNodes()
+	s.Unlock()
+
+	s.watchers.Notify(nodes)
 }
 
-func (s *Service) Done(ctx context.Context, node servicer.Node, err error) error {
+func (s *Service) Done(ctx context.Context, node servicer.Node, err error, cost time.Duration) error {
 	if assert.IsNil(s.selector) {
 		return errors.New("selector is nil")
 	}
-	s.selector.AfterHandle(selector.HandleInfo{Node: node, Err: err})
+	if errors.Is(err, servicer.ErrNotSent) {
+		s.selector.AfterHandle(selector.HandleInfo{Node: node, NotSent: true})
+		return nil
+	}
+	s.selector.AfterHandle(selector.HandleInfo{Node: node, Err: err, Cost: cost})
 	return nil
 }
 
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/registry"
+	"github.com/air-go/rpc/library/selector"
+	"github.com/air-go/rpc/library/selector/icmp"
+	"github.com/air-go/rpc/library/selector/outlier"
+	"github.com/air-go/rpc/library/selector/wr"
+	"github.com/air-go/rpc/library/servicer"
+	"github.com/air-go/rpc/library/servicer/subset"
+)
+
+type fakeDiscovery struct {
+	subscribers *registry.Subscribers
+}
+
+func newFakeDiscovery(nodes ...servicer.Node) *fakeDiscovery {
+	d := &fakeDiscovery{subscribers: registry.NewSubscribers()}
+	d.subscribers.Update(nodes)
+	return d
+}
+
+func (d *fakeDiscovery) GetNodes() []servicer.Node { return nil }
+
+func (d *fakeDiscovery) GetUpdateTime() time.Time { return time.Time{} }
+
+func (d *fakeDiscovery) Subscribe(f func(events []registry.NodeEvent)) (unsubscribe func()) {
+	return d.subscribers.Subscribe(f)
+}
+
+func (d *fakeDiscovery) Close() error { return nil }
+
+func addresses(nodes []servicer.Node) []string {
+	res := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, n.Address())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func newConfig() *Config {
+	return &Config{
+		ServiceName:  "svc",
+		RegistryName: "svc",
+		Type:         servicer.TypeRegistry,
+		Host:         "127.0.0.1",
+		Port:         80,
+		Selector:     "wr",
+	}
+}
+
+func TestService_Subscribe(t *testing.T) {
+	ctx := context.Background()
+	d := newFakeDiscovery(servicer.NewNode("127.0.0.1", 80), servicer.NewNode("127.0.0.2", 80))
+	sel := wr.NewSelector("svc")
+
+	s, err := NewService(newConfig(), WithDiscovery(d), WithSelector(sel))
+	assert.Nil(t, err)
+
+	all, err := s.All(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80"}, addresses(all))
+
+	var watched []string
+	cancel := s.Watch(func(nodes []servicer.Node) { watched = addresses(nodes) })
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.2:80"}, watched)
+
+	// updated node is replaced in selector
+	d.subscribers.Update([]servicer.Node{servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(10)), servicer.NewNode("127.0.0.3", 80)})
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.3:80"}, watched)
+	nodes, _ := sel.GetNodes()
+	for _, n := range nodes {
+		if n.Address() == "127.0.0.1:80" {
+			assert.Equal(t, 10, n.Weight())
+		}
+	}
+
+	node, err := s.Pick(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, []string{"127.0.0.1:80", "127.0.0.3:80"}, node.Address())
+
+	cancel()
+	assert.Nil(t, s.Close())
+	d.subscribers.Update([]servicer.Node{servicer.NewNode("127.0.0.4", 80)})
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.3:80"}, watched)
+	all, _ = s.All(ctx)
+	assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.3:80"}, addresses(all))
+}
+
+func TestService_Update(t *testing.T) {
+	d := newFakeDiscovery(servicer.NewNode("127.0.0.1", 80), servicer.NewNode("127.0.0.2", 80))
+	inner := wr.NewSelector("svc")
+	sel, _ := outlier.NewSelector(inner, outlier.WithConsecutiveFailures(1), outlier.WithMaxEjectionPercent(50))
+
+	s, err := NewService(newConfig(), WithDiscovery(d), WithSelector(sel))
+	assert.Nil(t, err)
+	defer s.Close()
+
+	sel.AfterHandle(selector.HandleInfo{Node: servicer.NewNode("127.0.0.1", 80), Err: errors.New("error")})
+	nodes, _ := inner.GetNodes()
+	assert.Equal(t, []string{"127.0.0.2:80"}, addresses(nodes))
+
+	// updated in place, the ejected node is not returned by the update
+	d.subscribers.Update([]servicer.Node{
+		servicer.NewNode("127.0.0.1", 80, servicer.WithWeight(10)),
+		servicer.NewNode("127.0.0.2", 80, servicer.WithWeight(10)),
+	})
+	nodes, _ = inner.GetNodes()
+	assert.Equal(t, []string{"127.0.0.2:80"}, addresses(nodes))
+	assert.Equal(t, 10, nodes[0].Weight())
+	nodes, _ = sel.GetNodes()
+	assert.Equal(t, 2, len(nodes))
+	for _, n := range nodes {
+		assert.Equal(t, 10, n.Weight())
+	}
+}
+
+func TestService_Close(t *testing.T) {
+	d := newFakeDiscovery(servicer.NewNode("127.0.0.1", 80), servicer.NewNode("127.0.0.2", 80))
+	var probes int64
+	sel := icmp.NewSelector("svc", icmp.WithInterval(time.Millisecond),
+		icmp.WithProber(func(ctx context.Context, address string) error {
+			atomic.AddInt64(&probes, 1)
+			return nil
+		}))
+
+	s, err := NewService(newConfig(), WithDiscovery(d), WithSelector(sel))
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&probes) > 0 }, time.Second, time.Millisecond)
+
+	// the probes are stopped with the service
+	assert.Nil(t, s.Close())
+	nodes, _ := sel.GetNodes()
+	assert.Equal(t, 0, len(nodes))
+	count := atomic.LoadInt64(&probes)
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, count, atomic.LoadInt64(&probes))
+}
+
+func TestService_Subset(t *testing.T) {
+	nodes := make([]servicer.Node, 0, 10)
+	for i := 0; i < 10; i++ {
+		nodes = append(nodes, servicer.NewNode("127.0.0.1", 8000+i))
+	}
+	d := newFakeDiscovery(nodes...)
+
+	s, err := NewService(newConfig(), WithDiscovery(d), WithSelector(wr.NewSelector("svc")), WithSubset(subset.NewRendezvous("client", 3)))
+	assert.Nil(t, err)
+
+	all, _ := s.All(context.Background())
+	assert.Equal(t, 3, len(all))
+
+	// removing a node out of subset does not change the subset
+	selected := make(map[string]struct{})
+	for _, n := range all {
+		selected[n.Address()] = struct{}{}
+	}
+	for idx, n := range nodes {
+		if _, ok := selected[n.Address()]; !ok {
+			d.subscribers.Update(append(append([]servicer.Node{}, nodes[:idx]...), nodes[idx+1:]...))
+			break
+		}
+	}
+	after, _ := s.All(context.Background())
+	assert.Equal(t, addresses(all), addresses(after))
+}
@@ -4,6 +4,7 @@ package servicer
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,6 +15,10 @@ const (
 	TypeDomain   uint8 = 3
 )
 
+// ErrNotSent is passed to Done if the request failed before sent to the node,
+// such as encode error or canceled by caller, the node is released without counting the result.
+var ErrNotSent = errors.New("request not sent")
+
 var (
 	lock      sync.RWMutex
 	servicers = make(map[string]Servicer)
@@ -55,7 +60,7 @@ type Servicer interface {
 	RegistryName() string
 	Pick(ctx context.Context) (Node, error)
 	All(ctx context.Context) ([]Node, error)
-	Done(ctx context.Context, node Node, err error) error
+	Done(ctx context.Context, node Node, err error, cost time.Duration) error
 	GetCaCrt() []byte
 	GetClientPem() []byte
 	GetClientKey() []byte
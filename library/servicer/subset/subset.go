@@ -0,0 +1,83 @@
+// subset is deterministic subsetting by rendezvous hashing,
+// each client only connect to size nodes of a large cluster.
+// reference https://sre.google/sre-book/load-balancing-datacenter/
+package subset
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/air-go/rpc/library/servicer"
+)
+
+// Subsetter pick part of discovered nodes before adding them to selector.
+type Subsetter interface {
+	Subset(nodes []servicer.Node) []servicer.Node
+}
+
+// Rendezvous score every node by hash(id, address) and pick the top size nodes.
+// The subset of one client is stable, and only the nodes joined or left are replaced when membership changes.
+// Different clients have different ids, so every node is picked by about size/len(nodes) of clients.
+type Rendezvous struct {
+	id   string
+	size int
+}
+
+var _ Subsetter = (*Rendezvous)(nil)
+
+// NewRendezvous id is the identity of client, such as app name and local ip,
+// size <= 0 means no subsetting.
+func NewRendezvous(id string, size int) *Rendezvous {
+	return &Rendezvous{
+		id:   id,
+		size: size,
+	}
+}
+
+func (r *Rendezvous) Subset(nodes []servicer.Node) []servicer.Node {
+	if r.size <= 0 || len(nodes) <= r.size {
+		return nodes
+	}
+
+	type scored struct {
+		node  servicer.Node
+		score uint64
+	}
+
+	list := make([]scored, len(nodes))
+	for idx, node := range nodes {
+		list[idx] = scored{node: node, score: r.score(node.Address())}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].score == list[j].score {
+			return list[i].node.Address() < list[j].node.Address()
+		}
+		return list[i].score > list[j].score
+	})
+
+	res := make([]servicer.Node, r.size)
+	for idx := range res {
+		res[idx] = list[idx].node
+	}
+
+	return res
+}
+
+func (r *Rendezvous) score(address string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(r.id))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(address))
+	return mix(h.Sum64())
+}
+
+// mix is the finalizer of murmur3, fnv alone is poorly distributed for similar addresses.
+func mix(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
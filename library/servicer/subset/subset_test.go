@@ -0,0 +1,100 @@
+package subset
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/air-go/rpc/library/servicer"
+)
+
+func newNodes(count int) []servicer.Node {
+	nodes := make([]servicer.Node, count)
+	for i := 0; i < count; i++ {
+		nodes[i] = servicer.NewNode(fmt.Sprintf("10.0.%d.%d", i/256, i%256), 80)
+	}
+	return nodes
+}
+
+func addresses(nodes []servicer.Node) map[string]struct{} {
+	res := make(map[string]struct{}, len(nodes))
+	for _, n := range nodes {
+		res[n.Address()] = struct{}{}
+	}
+	return res
+}
+
+func TestRendezvous_Subset(t *testing.T) {
+	nodes := newNodes(100)
+
+	// disabled or not enough nodes
+	assert.Equal(t, nodes, NewRendezvous("client", 0).Subset(nodes))
+	assert.Equal(t, nodes[:5], NewRendezvous("client", 10).Subset(nodes[:5]))
+
+	r := NewRendezvous("client", 10)
+	res := r.Subset(nodes)
+	assert.Equal(t, 10, len(res))
+	assert.Equal(t, 10, len(addresses(res)))
+
+	// stable regardless of order
+	reversed := make([]servicer.Node, len(nodes))
+	for idx, n := range nodes {
+		reversed[len(nodes)-1-idx] = n
+	}
+	assert.Equal(t, addresses(res), addresses(r.Subset(reversed)))
+}
+
+func TestRendezvous_MinimalChange(t *testing.T) {
+	nodes := newNodes(100)
+	r := NewRendezvous("client", 10)
+	before := addresses(r.Subset(nodes))
+
+	// remove one node of subset, only it is replaced
+	var removed string
+	remain := make([]servicer.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := before[n.Address()]; ok && removed == "" {
+			removed = n.Address()
+			continue
+		}
+		remain = append(remain, n)
+	}
+	after := addresses(r.Subset(remain))
+	diff := 0
+	for address := range before {
+		if _, ok := after[address]; !ok {
+			diff++
+		}
+	}
+	assert.Equal(t, 1, diff)
+	assert.NotContains(t, after, removed)
+
+	// add new nodes, at most the same count replaced
+	after = addresses(r.Subset(append(nodes, newNodes(103)[100:]...)))
+	diff = 0
+	for address := range before {
+		if _, ok := after[address]; !ok {
+			diff++
+		}
+	}
+	assert.LessOrEqual(t, diff, 3)
+}
+
+func TestRendezvous_Balance(t *testing.T) {
+	nodes := newNodes(50)
+	count := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		r := NewRendezvous(fmt.Sprintf("app/172.16.%d.%d", i/256, i%256), 10)
+		for _, n := range r.Subset(nodes) {
+			count[n.Address()]++
+		}
+	}
+
+	// every node is expected to be picked by 200*10/50 = 40 clients
+	assert.Equal(t, 50, len(count))
+	for _, c := range count {
+		assert.Greater(t, c, 15)
+		assert.Less(t, c, 70)
+	}
+}
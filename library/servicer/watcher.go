@@ -0,0 +1,51 @@
+package servicer
+
+import "sync"
+
+// Watcher is implemented by servicer which can push the node list when changed,
+// such as the gRPC resolver which should not poll.
+type Watcher interface {
+	// Watch call f with the current nodes if any, then with the whole nodes after each change.
+	Watch(f func(nodes []Node)) (cancel func())
+}
+
+// Watchers keep the latest nodes and call the watch funcs serially.
+type Watchers struct {
+	lock  sync.Mutex
+	nodes []Node
+	fs    map[int]func([]Node)
+	next  int
+}
+
+func NewWatchers() *Watchers {
+	return &Watchers{fs: make(map[int]func([]Node))}
+}
+
+func (w *Watchers) Watch(f func(nodes []Node)) (cancel func()) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	id := w.next
+	w.next++
+	w.fs[id] = f
+
+	if w.nodes != nil {
+		f(w.nodes)
+	}
+
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.fs, id)
+	}
+}
+
+func (w *Watchers) Notify(nodes []Node) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.nodes = nodes
+	for _, f := range w.fs {
+		f(nodes)
+	}
+}
@@ -0,0 +1,72 @@
+package embedetcd
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// NewServer start a single member embedded etcd in a temp dir, return the client endpoint,
+// the server is closed when the test finished.
+func NewServer(t testing.TB) string {
+	return NewServerAt(t, FreeURL(t))
+}
+
+// NewServerAt start the server listening on client, such as the client is created before the server.
+func NewServerAt(t testing.TB, client url.URL) string {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	peer := FreeURL(t)
+	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = []url.URL{client}, []url.URL{client}
+	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = []url.URL{peer}, []url.URL{peer}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("start etcd: %s", err)
+	}
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("start etcd timeout")
+	}
+
+	return client.Host
+}
+
+// NewClient connect to endpoint, the client is closed when the test finished.
+func NewClient(t testing.TB, endpoint string) *clientv3.Client {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{endpoint},
+		DialTimeout: 3 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("new etcd client: %s", err)
+	}
+	t.Cleanup(func() { _ = cli.Close() })
+
+	return cli
+}
+
+// Start start a server and return a client of it.
+func Start(t testing.TB) *clientv3.Client {
+	return NewClient(t, NewServer(t))
+}
+
+// FreeURL return the url of a free local port
+func FreeURL(t testing.TB) url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	defer l.Close()
+
+	return url.URL{Scheme: "http", Host: l.Addr().String()}
+}
@@ -0,0 +1,57 @@
+package tag
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	lc "github.com/air-go/rpc/library/context"
+)
+
+const (
+	headerPrefix = "X-"
+	LaneKey      = "lane"
+)
+
+// TagMiddleware is used to extract route tags from header X-{Key} to context, default key is lane
+func TagMiddleware(keys ...string) gin.HandlerFunc {
+	if len(keys) == 0 {
+		keys = []string{LaneKey}
+	}
+
+	return func(c *gin.Context) {
+		tags := make(map[string]string)
+		for k, v := range lc.ValueTags(c.Request.Context()) {
+			tags[k] = v
+		}
+
+		for _, key := range keys {
+			if val := c.Request.Header.Get(HeaderKey(key)); val != "" {
+				tags[key] = val
+			}
+		}
+
+		if len(tags) > 0 {
+			c.Request = c.Request.WithContext(lc.WithTags(c.Request.Context(), tags))
+		}
+
+		c.Next()
+	}
+}
+
+// SetHeader save route tags to http.Header, pass them to downstream
+func SetHeader(ctx context.Context, header http.Header) {
+	for key, val := range lc.ValueTags(ctx) {
+		if val == "" {
+			continue
+		}
+		header.Set(HeaderKey(key), val)
+	}
+}
+
+// HeaderKey return the header name of tag key, lane is X-Lane
+func HeaderKey(key string) string {
+	return http.CanonicalHeaderKey(headerPrefix + strings.ToLower(key))
+}
@@ -0,0 +1,44 @@
+package tag
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	lc "github.com/air-go/rpc/library/context"
+)
+
+func TestTagMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var tags map[string]string
+	r := gin.New()
+	r.Use(TagMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		tags = lc.ValueTags(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Lane", "dev")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, map[string]string{"lane": "dev"}, tags)
+
+	tags = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, tags)
+}
+
+func TestSetHeader(t *testing.T) {
+	header := http.Header{}
+	ctx := lc.WithTags(context.Background(), map[string]string{"lane": "dev", "version": "", "Canary": "1"})
+	SetHeader(ctx, header)
+
+	assert.Equal(t, "dev", header.Get("X-Lane"))
+	assert.Equal(t, "1", header.Get("X-Canary"))
+	_, ok := header["X-Version"]
+	assert.False(t, ok)
+}